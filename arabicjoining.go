@@ -0,0 +1,172 @@
+// Code generated by gen_joining.go from ArabicShaping.txt of Unicode 14.0.0; DO NOT EDIT.
+
+package gui
+
+// arabicJoining holds the ranges of runes whose joining types are not
+// joinTransparent for marks and format characters and joinNone for the rest.
+var arabicJoining = []struct {
+	lo, hi rune
+	typ    byte
+}{
+	{0x0600, 0x0605, joinNone},
+	{0x0620, 0x0620, joinDual},
+	{0x0622, 0x0625, joinRight},
+	{0x0626, 0x0626, joinDual},
+	{0x0627, 0x0627, joinRight},
+	{0x0628, 0x0628, joinDual},
+	{0x0629, 0x0629, joinRight},
+	{0x062A, 0x062E, joinDual},
+	{0x062F, 0x0632, joinRight},
+	{0x0633, 0x063F, joinDual},
+	{0x0640, 0x0640, joinCausing},
+	{0x0641, 0x0647, joinDual},
+	{0x0648, 0x0648, joinRight},
+	{0x0649, 0x064A, joinDual},
+	{0x066E, 0x066F, joinDual},
+	{0x0671, 0x0673, joinRight},
+	{0x0675, 0x0677, joinRight},
+	{0x0678, 0x0687, joinDual},
+	{0x0688, 0x0699, joinRight},
+	{0x069A, 0x06BF, joinDual},
+	{0x06C0, 0x06C0, joinRight},
+	{0x06C1, 0x06C2, joinDual},
+	{0x06C3, 0x06CB, joinRight},
+	{0x06CC, 0x06CC, joinDual},
+	{0x06CD, 0x06CD, joinRight},
+	{0x06CE, 0x06CE, joinDual},
+	{0x06CF, 0x06CF, joinRight},
+	{0x06D0, 0x06D1, joinDual},
+	{0x06D2, 0x06D3, joinRight},
+	{0x06D5, 0x06D5, joinRight},
+	{0x06DD, 0x06DD, joinNone},
+	{0x06EE, 0x06EF, joinRight},
+	{0x06FA, 0x06FC, joinDual},
+	{0x06FF, 0x06FF, joinDual},
+	{0x0710, 0x0710, joinAlaph},
+	{0x0712, 0x0714, joinDual},
+	{0x0715, 0x0716, joinDalathRish},
+	{0x0717, 0x0719, joinRight},
+	{0x071A, 0x071D, joinDual},
+	{0x071E, 0x071E, joinRight},
+	{0x071F, 0x0727, joinDual},
+	{0x0728, 0x0728, joinRight},
+	{0x0729, 0x0729, joinDual},
+	{0x072A, 0x072A, joinDalathRish},
+	{0x072B, 0x072B, joinDual},
+	{0x072C, 0x072C, joinRight},
+	{0x072D, 0x072E, joinDual},
+	{0x072F, 0x072F, joinDalathRish},
+	{0x074D, 0x074D, joinRight},
+	{0x074E, 0x0758, joinDual},
+	{0x0759, 0x075B, joinRight},
+	{0x075C, 0x076A, joinDual},
+	{0x076B, 0x076C, joinRight},
+	{0x076D, 0x0770, joinDual},
+	{0x0771, 0x0771, joinRight},
+	{0x0772, 0x0772, joinDual},
+	{0x0773, 0x0774, joinRight},
+	{0x0775, 0x0777, joinDual},
+	{0x0778, 0x0779, joinRight},
+	{0x077A, 0x077F, joinDual},
+	{0x07CA, 0x07EA, joinDual},
+	{0x07FA, 0x07FA, joinCausing},
+	{0x0840, 0x0840, joinRight},
+	{0x0841, 0x0845, joinDual},
+	{0x0846, 0x0847, joinRight},
+	{0x0848, 0x0848, joinDual},
+	{0x0849, 0x0849, joinRight},
+	{0x084A, 0x0853, joinDual},
+	{0x0854, 0x0854, joinRight},
+	{0x0855, 0x0855, joinDual},
+	{0x0856, 0x0858, joinRight},
+	{0x0860, 0x0860, joinDual},
+	{0x0862, 0x0865, joinDual},
+	{0x0867, 0x0867, joinRight},
+	{0x0868, 0x0868, joinDual},
+	{0x0869, 0x086A, joinRight},
+	{0x0870, 0x0882, joinRight},
+	{0x0883, 0x0885, joinCausing},
+	{0x0886, 0x0886, joinDual},
+	{0x0889, 0x088D, joinDual},
+	{0x088E, 0x088E, joinRight},
+	{0x0890, 0x0891, joinNone},
+	{0x08A0, 0x08A9, joinDual},
+	{0x08AA, 0x08AC, joinRight},
+	{0x08AE, 0x08AE, joinRight},
+	{0x08AF, 0x08B0, joinDual},
+	{0x08B1, 0x08B2, joinRight},
+	{0x08B3, 0x08B8, joinDual},
+	{0x08B9, 0x08B9, joinRight},
+	{0x08BA, 0x08C8, joinDual},
+	{0x08E2, 0x08E2, joinNone},
+	{0x1807, 0x1807, joinDual},
+	{0x180A, 0x180A, joinCausing},
+	{0x180E, 0x180E, joinNone},
+	{0x1820, 0x1878, joinDual},
+	{0x1887, 0x18A8, joinDual},
+	{0x18AA, 0x18AA, joinDual},
+	{0x200C, 0x200C, joinNone},
+	{0x200D, 0x200D, joinCausing},
+	{0x2066, 0x2069, joinNone},
+	{0xA840, 0xA871, joinDual},
+	{0xA872, 0xA872, joinLeft},
+	{0x10AC0, 0x10AC4, joinDual},
+	{0x10AC5, 0x10AC5, joinRight},
+	{0x10AC7, 0x10AC7, joinRight},
+	{0x10AC9, 0x10ACA, joinRight},
+	{0x10ACD, 0x10ACD, joinLeft},
+	{0x10ACE, 0x10AD2, joinRight},
+	{0x10AD3, 0x10AD6, joinDual},
+	{0x10AD7, 0x10AD7, joinLeft},
+	{0x10AD8, 0x10ADC, joinDual},
+	{0x10ADD, 0x10ADD, joinRight},
+	{0x10ADE, 0x10AE0, joinDual},
+	{0x10AE1, 0x10AE1, joinRight},
+	{0x10AE4, 0x10AE4, joinRight},
+	{0x10AEB, 0x10AEE, joinDual},
+	{0x10AEF, 0x10AEF, joinRight},
+	{0x10B80, 0x10B80, joinDual},
+	{0x10B81, 0x10B81, joinRight},
+	{0x10B82, 0x10B82, joinDual},
+	{0x10B83, 0x10B85, joinRight},
+	{0x10B86, 0x10B88, joinDual},
+	{0x10B89, 0x10B89, joinRight},
+	{0x10B8A, 0x10B8B, joinDual},
+	{0x10B8C, 0x10B8C, joinRight},
+	{0x10B8D, 0x10B8D, joinDual},
+	{0x10B8E, 0x10B8F, joinRight},
+	{0x10B90, 0x10B90, joinDual},
+	{0x10B91, 0x10B91, joinRight},
+	{0x10BA9, 0x10BAC, joinRight},
+	{0x10BAD, 0x10BAE, joinDual},
+	{0x10D00, 0x10D00, joinLeft},
+	{0x10D01, 0x10D21, joinDual},
+	{0x10D22, 0x10D22, joinRight},
+	{0x10D23, 0x10D23, joinDual},
+	{0x10F30, 0x10F32, joinDual},
+	{0x10F33, 0x10F33, joinRight},
+	{0x10F34, 0x10F44, joinDual},
+	{0x10F51, 0x10F53, joinDual},
+	{0x10F54, 0x10F54, joinRight},
+	{0x10F70, 0x10F73, joinDual},
+	{0x10F74, 0x10F75, joinRight},
+	{0x10F76, 0x10F81, joinDual},
+	{0x10FB0, 0x10FB0, joinDual},
+	{0x10FB2, 0x10FB3, joinDual},
+	{0x10FB4, 0x10FB6, joinRight},
+	{0x10FB8, 0x10FB8, joinDual},
+	{0x10FB9, 0x10FBA, joinRight},
+	{0x10FBB, 0x10FBC, joinDual},
+	{0x10FBD, 0x10FBD, joinRight},
+	{0x10FBE, 0x10FBF, joinDual},
+	{0x10FC1, 0x10FC1, joinDual},
+	{0x10FC2, 0x10FC3, joinRight},
+	{0x10FC4, 0x10FC4, joinDual},
+	{0x10FC9, 0x10FC9, joinRight},
+	{0x10FCA, 0x10FCA, joinDual},
+	{0x10FCB, 0x10FCB, joinLeft},
+	{0x110BD, 0x110BD, joinNone},
+	{0x110CD, 0x110CD, joinNone},
+	{0x1E900, 0x1E943, joinDual},
+	{0x1E94B, 0x1E94B, joinTransparent},
+}
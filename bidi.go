@@ -0,0 +1,680 @@
+package gui
+
+import (
+	"golang.org/x/text/unicode/bidi"
+
+	"slices"
+)
+
+// Text mixing left-to-right and right-to-left scripts is ordered for display
+// by the Unicode Bidirectional Algorithm (UAX #9).  Each character is given
+// an embedding level, even for left-to-right and odd for right-to-left, from
+// its bidi class, the explicit embeddings, overrides and isolates around it,
+// and the characters near it.  Runs of characters are then reversed, from the
+// highest level down, to give the order in which they are displayed.  A line
+// of text is treated as a single paragraph.
+
+// A Direction is the direction in which text and Views are laid out.
+type Direction int
+
+const (
+	// DirectionAuto takes the direction from the first strongly directional
+	// character of the text, for a Font, or from its parent, for a View.
+	DirectionAuto Direction = iota
+	LeftToRight
+	RightToLeft
+)
+
+// maxBidiDepth is the deepest explicit embedding level.
+const maxBidiDepth = 125
+
+// A bidiRun is a span of text at one embedding level.
+type bidiRun struct {
+	start, end int // byte offsets
+	level      int8
+}
+
+func (r bidiRun) rtl() bool { return r.level&1 == 1 }
+
+// A bidiParagraph holds the classes and resolved levels of the runes of a
+// text.
+type bidiParagraph struct {
+	text    string
+	pos     []int        // the byte offset of each rune
+	runes   []rune       // the runes
+	orig    []bidi.Class // their bidi classes
+	types   []bidi.Class // their classes as resolved so far
+	levels  []int8       // their embedding levels
+	match   []int        // the index of the PDI matching each isolate initiator, or -1
+	level   int8         // the paragraph embedding level
+	removed []bool       // whether each rune is removed by rule X9
+}
+
+// bidiRuns returns the runs of text at each embedding level, in the order
+// they are displayed, for a paragraph laid out in direction dir.
+func bidiRuns(text string, dir Direction) []bidiRun {
+	if text == "" {
+		return nil
+	}
+	p := newBidiParagraph(text, dir)
+	p.resolveExplicit()
+	for _, seq := range p.isolatingRunSequences() {
+		p.resolveSequence(seq)
+	}
+	p.resolveLineEnds()
+	return p.reorder()
+}
+
+// paragraphLevel returns the embedding level of a paragraph of text laid out
+// in direction dir: 1 if it is right-to-left and 0 otherwise.
+func paragraphLevel(text string, dir Direction) int8 {
+	switch dir {
+	case LeftToRight:
+		return 0
+	case RightToLeft:
+		return 1
+	}
+	var classes []bidi.Class
+	for _, r := range text {
+		classes = append(classes, bidiClass(r))
+	}
+	return firstStrong(classes, 0, len(classes))
+}
+
+func newBidiParagraph(text string, dir Direction) *bidiParagraph {
+	p := &bidiParagraph{text: text}
+	for i, r := range text {
+		p.pos = append(p.pos, i)
+		p.runes = append(p.runes, r)
+		p.orig = append(p.orig, bidiClass(r))
+	}
+	n := len(p.runes)
+	p.types = slices.Clone(p.orig)
+	p.levels = make([]int8, n)
+	p.removed = make([]bool, n)
+	p.match = make([]int, n)
+
+	// Match isolate initiators with PDIs (BD9).
+	var open []int
+	for i, c := range p.orig {
+		p.match[i] = -1
+		switch c {
+		case bidi.LRI, bidi.RLI, bidi.FSI:
+			open = append(open, i)
+		case bidi.PDI:
+			if k := len(open); k > 0 {
+				p.match[open[k-1]] = i
+				open = open[:k-1]
+			}
+		case bidi.B:
+			open = open[:0]
+		}
+	}
+
+	p.level = paragraphLevel(text, dir)
+	return p
+}
+
+// bidiClass returns the bidi class of r.
+func bidiClass(r rune) bidi.Class {
+	props, _ := bidi.LookupRune(r)
+	return props.Class()
+}
+
+// firstStrong returns 1 if the first strongly directional class of
+// classes[i:j], outside of isolates, is right-to-left, and 0 otherwise (rules
+// P2 and P3).
+func firstStrong(classes []bidi.Class, i, j int) int8 {
+	depth := 0
+	for ; i < j; i++ {
+		switch classes[i] {
+		case bidi.L:
+			if depth == 0 {
+				return 0
+			}
+		case bidi.R, bidi.AL:
+			if depth == 0 {
+				return 1
+			}
+		case bidi.LRI, bidi.RLI, bidi.FSI:
+			depth++
+		case bidi.PDI:
+			if depth > 0 {
+				depth--
+			}
+		case bidi.B:
+			return 0
+		}
+	}
+	return 0
+}
+
+// resolveExplicit applies rules X1 to X9, giving each rune its explicit
+// embedding level.
+func (p *bidiParagraph) resolveExplicit() {
+	type status struct {
+		level    int8
+		override bidi.Class // L, R, or ON for none
+		isolate  bool
+	}
+	stack := []status{{p.level, bidi.ON, false}}
+	overflowIsolates, overflowEmbeddings, validIsolates := 0, 0, 0
+	next := func(rtl bool) int8 {
+		l := stack[len(stack)-1].level + 1
+		if rtl == (l&1 == 0) {
+			l++
+		}
+		return l
+	}
+
+	for i, c := range p.orig {
+		top := stack[len(stack)-1]
+		switch c {
+		case bidi.RLE, bidi.LRE, bidi.RLO, bidi.LRO:
+			p.levels[i] = top.level
+			p.removed[i] = true
+			l := next(c == bidi.RLE || c == bidi.RLO)
+			if l <= maxBidiDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				override := bidi.ON
+				switch c {
+				case bidi.LRO:
+					override = bidi.L
+				case bidi.RLO:
+					override = bidi.R
+				}
+				stack = append(stack, status{l, override, false})
+			} else if overflowIsolates == 0 {
+				overflowEmbeddings++
+			}
+
+		case bidi.RLI, bidi.LRI, bidi.FSI:
+			p.levels[i] = top.level
+			if top.override != bidi.ON {
+				p.types[i] = top.override
+			}
+			rtl := c == bidi.RLI
+			if c == bidi.FSI {
+				end := p.match[i]
+				if end < 0 {
+					end = len(p.orig)
+				}
+				rtl = firstStrong(p.orig, i+1, end) == 1
+			}
+			l := next(rtl)
+			if l <= maxBidiDepth && overflowIsolates == 0 && overflowEmbeddings == 0 {
+				validIsolates++
+				stack = append(stack, status{l, bidi.ON, true})
+			} else {
+				overflowIsolates++
+			}
+
+		case bidi.PDI:
+			if overflowIsolates > 0 {
+				overflowIsolates--
+			} else if validIsolates > 0 {
+				overflowEmbeddings = 0
+				for !stack[len(stack)-1].isolate {
+					stack = stack[:len(stack)-1]
+				}
+				stack = stack[:len(stack)-1]
+				validIsolates--
+			}
+			top = stack[len(stack)-1]
+			p.levels[i] = top.level
+			if top.override != bidi.ON {
+				p.types[i] = top.override
+			}
+
+		case bidi.PDF:
+			p.levels[i] = top.level
+			p.removed[i] = true
+			if overflowIsolates > 0 {
+			} else if overflowEmbeddings > 0 {
+				overflowEmbeddings--
+			} else if !top.isolate && len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+
+		case bidi.B:
+			p.levels[i] = p.level
+
+		case bidi.BN:
+			p.levels[i] = top.level
+			p.removed[i] = true
+
+		default:
+			p.levels[i] = top.level
+			if top.override != bidi.ON {
+				p.types[i] = top.override
+			}
+		}
+	}
+}
+
+// A runSequence is an isolating run sequence: the runes, skipping removed
+// ones, that the weak, neutral and implicit rules treat as contiguous.
+type runSequence struct {
+	runes    []int // indices
+	level    int8
+	sos, eos bidi.Class // the directions of the text before and after it
+}
+
+// isolatingRunSequences returns the isolating run sequences of the paragraph
+// (rule X10).  It must be called before any of them is resolved, as their
+// start and end directions depend on the explicit levels around them.
+func (p *bidiParagraph) isolatingRunSequences() []runSequence {
+	// The level runs: maximal spans of runes at one level, ignoring removed
+	// runes.
+	var runs [][]int
+	runOf := make([]int, len(p.runes))
+	var cur []int
+	for i := range p.runes {
+		if p.removed[i] {
+			continue
+		}
+		if len(cur) > 0 && p.levels[cur[0]] != p.levels[i] {
+			runs = append(runs, cur)
+			cur = nil
+		}
+		cur = append(cur, i)
+		runOf[i] = len(runs)
+	}
+	if len(cur) > 0 {
+		runs = append(runs, cur)
+	}
+
+	isMatchedPDI := make([]bool, len(p.runes))
+	for _, j := range p.match {
+		if j >= 0 {
+			isMatchedPDI[j] = true
+		}
+	}
+	var seqs []runSequence
+	for _, run := range runs {
+		if isMatchedPDI[run[0]] {
+			continue // it continues the sequence of its isolate initiator
+		}
+		seq := slices.Clone(run)
+		for {
+			last := seq[len(seq)-1]
+			j := p.match[last]
+			if !isIsolateInitiator(p.orig[last]) || j < 0 {
+				break
+			}
+			seq = append(seq, runs[runOf[j]]...)
+		}
+		seqs = append(seqs, p.runSequence(seq))
+	}
+	return seqs
+}
+
+// runSequence returns the isolating run sequence of the runes seq.
+func (p *bidiParagraph) runSequence(seq []int) runSequence {
+	level := p.levels[seq[0]]
+	first, last := seq[0], seq[len(seq)-1]
+
+	// The start and end of sequence types come from the higher of the
+	// sequence's level and that of the text beyond it.
+	before := p.level
+	for i := first - 1; i >= 0; i-- {
+		if !p.removed[i] {
+			before = p.levels[i]
+			break
+		}
+	}
+	// A sequence can only end in an isolate initiator if it has no matching
+	// PDI, and then the text after it is not compared.
+	after := p.level
+	if !isIsolateInitiator(p.types[last]) {
+		for i := last + 1; i < len(p.runes); i++ {
+			if !p.removed[i] {
+				after = p.levels[i]
+				break
+			}
+		}
+	}
+	return runSequence{seq, level, levelDir(max(level, before)), levelDir(max(level, after))}
+}
+
+func isIsolateInitiator(c bidi.Class) bool {
+	return c == bidi.LRI || c == bidi.RLI || c == bidi.FSI
+}
+
+// isNeutral reports whether c is a neutral or isolate formatting class, for
+// rules N1 and N2.
+func isNeutral(c bidi.Class) bool {
+	switch c {
+	case bidi.B, bidi.S, bidi.WS, bidi.ON, bidi.LRI, bidi.RLI, bidi.FSI, bidi.PDI:
+		return true
+	}
+	return false
+}
+
+// strongDir returns the direction c counts as in rules N0 and N1, treating
+// numbers as right-to-left, or ON if it is not strong.
+func strongDir(c bidi.Class) bidi.Class {
+	switch c {
+	case bidi.L:
+		return bidi.L
+	case bidi.R, bidi.AL, bidi.EN, bidi.AN:
+		return bidi.R
+	}
+	return bidi.ON
+}
+
+func levelDir(l int8) bidi.Class {
+	if l&1 == 1 {
+		return bidi.R
+	}
+	return bidi.L
+}
+
+// resolveSequence applies the weak, neutral and implicit rules (W1 to I2) to
+// an isolating run sequence.
+func (p *bidiParagraph) resolveSequence(rs runSequence) {
+	seq, level, sos, eos := rs.runes, rs.level, rs.sos, rs.eos
+	t := make([]bidi.Class, len(seq))
+	for k, i := range seq {
+		t[k] = p.types[i]
+	}
+
+	// W1: nonspacing marks take the class of what precedes them.
+	for k := range t {
+		if t[k] != bidi.NSM {
+			continue
+		}
+		switch {
+		case k == 0:
+			t[k] = sos
+		case isIsolateInitiator(t[k-1]) || t[k-1] == bidi.PDI:
+			t[k] = bidi.ON
+		default:
+			t[k] = t[k-1]
+		}
+	}
+	// W2 and W3: European numbers after Arabic letters are Arabic numbers,
+	// and Arabic letters are right-to-left.
+	strong := sos
+	for k := range t {
+		switch t[k] {
+		case bidi.L, bidi.R:
+			strong = t[k]
+		case bidi.AL:
+			strong = bidi.AL
+			t[k] = bidi.R
+		case bidi.EN:
+			if strong == bidi.AL {
+				t[k] = bidi.AN
+			}
+		}
+	}
+	// W4: a single separator between two numbers of the same kind joins them.
+	for k := 1; k+1 < len(t); k++ {
+		switch {
+		case t[k] == bidi.ES && t[k-1] == bidi.EN && t[k+1] == bidi.EN:
+			t[k] = bidi.EN
+		case t[k] == bidi.CS && t[k-1] == bidi.EN && t[k+1] == bidi.EN:
+			t[k] = bidi.EN
+		case t[k] == bidi.CS && t[k-1] == bidi.AN && t[k+1] == bidi.AN:
+			t[k] = bidi.AN
+		}
+	}
+	// W5: terminators next to European numbers are part of them.
+	for k := 0; k < len(t); {
+		if t[k] != bidi.ET {
+			k++
+			continue
+		}
+		end := k
+		for end < len(t) && t[end] == bidi.ET {
+			end++
+		}
+		if k > 0 && t[k-1] == bidi.EN || end < len(t) && t[end] == bidi.EN {
+			for ; k < end; k++ {
+				t[k] = bidi.EN
+			}
+		}
+		k = end
+	}
+	// W6: remaining separators and terminators are neutral.
+	for k := range t {
+		switch t[k] {
+		case bidi.ES, bidi.ET, bidi.CS:
+			t[k] = bidi.ON
+		}
+	}
+	// W7: European numbers in left-to-right text are left-to-right.
+	strong = sos
+	for k := range t {
+		switch t[k] {
+		case bidi.L, bidi.R:
+			strong = t[k]
+		case bidi.EN:
+			if strong == bidi.L {
+				t[k] = bidi.L
+			}
+		}
+	}
+
+	p.resolveBrackets(seq, t, level, sos)
+
+	// N1 and N2: neutrals between text of one direction take that direction,
+	// and otherwise the embedding direction.
+	e := levelDir(level)
+	for k := 0; k < len(t); {
+		if !isNeutral(t[k]) {
+			k++
+			continue
+		}
+		end := k
+		for end < len(t) && isNeutral(t[end]) {
+			end++
+		}
+		prev, next := sos, eos
+		if k > 0 {
+			prev = strongDir(t[k-1])
+		}
+		if end < len(t) {
+			next = strongDir(t[end])
+		}
+		dir := e
+		if prev == next {
+			dir = prev
+		}
+		for ; k < end; k++ {
+			t[k] = dir
+		}
+	}
+
+	// I1 and I2: raise the levels of text against the embedding direction.
+	for k, i := range seq {
+		p.types[i] = t[k]
+		switch {
+		case level&1 == 0 && t[k] == bidi.R:
+			p.levels[i] = level + 1
+		case level&1 == 0 && (t[k] == bidi.AN || t[k] == bidi.EN):
+			p.levels[i] = level + 2
+		case level&1 == 1 && (t[k] == bidi.L || t[k] == bidi.EN || t[k] == bidi.AN):
+			p.levels[i] = level + 1
+		}
+	}
+}
+
+// maxBracketPairs is the depth of the stack used to find bracket pairs.
+const maxBracketPairs = 63
+
+// resolveBrackets applies rule N0: paired brackets take the direction of the
+// text inside them, or of the text around them.
+func (p *bidiParagraph) resolveBrackets(seq []int, t []bidi.Class, level int8, sos bidi.Class) {
+	// Find the pairs (BD16).
+	type opener struct {
+		k     int
+		close rune
+	}
+	var stack []opener
+	var pairs [][2]int
+find:
+	for k, i := range seq {
+		if t[k] != bidi.ON {
+			continue
+		}
+		r := p.runes[i]
+		props, _ := bidi.LookupRune(r)
+		if !props.IsBracket() {
+			continue
+		}
+		if props.IsOpeningBracket() {
+			if len(stack) == maxBracketPairs {
+				break
+			}
+			stack = append(stack, opener{k, mirror(r)})
+			continue
+		}
+		for n := len(stack) - 1; n >= 0; n-- {
+			if stack[n].close == r || canonicalBracket(stack[n].close) == canonicalBracket(r) {
+				pairs = append(pairs, [2]int{stack[n].k, k})
+				stack = stack[:n]
+				continue find
+			}
+		}
+	}
+	slices.SortFunc(pairs, func(a, b [2]int) int { return a[0] - b[0] })
+
+	e := levelDir(level)
+	for _, pair := range pairs {
+		open, close := pair[0], pair[1]
+		dir := bidi.ON
+		for k := open + 1; k < close; k++ {
+			if d := strongDir(t[k]); d == e {
+				dir = e
+				break
+			} else if d != bidi.ON {
+				dir = d
+			}
+		}
+		if dir == bidi.ON {
+			continue
+		}
+		if dir != e {
+			// The brackets enclose only text against the embedding
+			// direction; they take that direction if the text before them
+			// does too.
+			ctx := sos
+			for k := open - 1; k >= 0; k-- {
+				if d := strongDir(t[k]); d != bidi.ON {
+					ctx = d
+					break
+				}
+			}
+			if ctx != dir {
+				dir = e
+			}
+		}
+		for _, k := range []int{open, close} {
+			t[k] = dir
+			for k++; k < len(t) && p.orig[seq[k]] == bidi.NSM; k++ {
+				t[k] = dir
+			}
+		}
+	}
+}
+
+// canonicalBracket maps the angle brackets that are canonically equivalent to
+// CJK ones to those.
+func canonicalBracket(r rune) rune {
+	switch r {
+	case 0x2329:
+		return 0x3008
+	case 0x232A:
+		return 0x3009
+	}
+	return r
+}
+
+// resolveLineEnds applies rule L1: separators, and whitespace before them or
+// at the end of the line, are at the paragraph level.
+func (p *bidiParagraph) resolveLineEnds() {
+	trailing := true
+	for i := len(p.runes) - 1; i >= 0; i-- {
+		switch c := p.orig[i]; {
+		case c == bidi.S || c == bidi.B:
+			p.levels[i] = p.level
+			trailing = true
+		case c == bidi.WS || isIsolateInitiator(c) || c == bidi.PDI || p.removed[i]:
+			if trailing {
+				p.levels[i] = p.level
+			}
+		default:
+			trailing = false
+		}
+	}
+	// Removed runes are not displayed, but are kept with their neighbors so
+	// that they do not split runs.
+	for i := range p.runes {
+		if p.removed[i] {
+			if i > 0 {
+				p.levels[i] = p.levels[i-1]
+			} else {
+				p.levels[i] = p.level
+			}
+		}
+	}
+}
+
+// reorder applies rule L2, returning the runs of the paragraph in the order
+// they are displayed.
+func (p *bidiParagraph) reorder() []bidiRun {
+	var runs []bidiRun
+	for i, l := range p.levels {
+		if n := len(runs); n > 0 && runs[n-1].level == l {
+			continue
+		} else if n > 0 {
+			runs[n-1].end = p.pos[i]
+		}
+		runs = append(runs, bidiRun{start: p.pos[i], level: l})
+	}
+	runs[len(runs)-1].end = len(p.text)
+
+	highest, lowestOdd := int8(0), int8(maxBidiDepth+2)
+	for _, r := range runs {
+		highest = max(highest, r.level)
+		if r.level&1 == 1 {
+			lowestOdd = min(lowestOdd, r.level)
+		}
+	}
+	for l := highest; l >= lowestOdd; l-- {
+		for i := 0; i < len(runs); {
+			if runs[i].level < l {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runs) && runs[j].level >= l {
+				j++
+			}
+			slices.Reverse(runs[i:j])
+			i = j
+		}
+	}
+	return runs
+}
+
+//go:generate go run gen_mirror.go
+
+// mirror returns the mirror image of r, or r if it has none.
+func mirror(r rune) rune {
+	if m, ok := mirrored[r]; ok {
+		return m
+	}
+	return r
+}
+
+// isBidiControl reports whether r is an invisible character that only
+// affects bidi ordering.
+func isBidiControl(r rune) bool {
+	switch r {
+	case 0x061C, 0x200E, 0x200F:
+		return true
+	}
+	return r >= 0x202A && r <= 0x202E || r >= 0x2066 && r <= 0x2069
+}
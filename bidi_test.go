@@ -0,0 +1,115 @@
+package gui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// bidiTests are in the format of the Unicode Character Database's
+// BidiCharacterTest.txt: the code points of a paragraph; its direction (0 for
+// left-to-right, 1 for right-to-left, 2 for auto); its resolved level; the
+// resolved level of each character, or x if it is removed by rule X9; and the
+// visual order of the characters that are not removed.  They cover isolates,
+// paired brackets (N0) and trailing whitespace (L1).
+const bidiTests = `
+05D0 2067 05D1;0;0;1 0 1;0 1 2
+0061 0020 2067 0062 0020 05D0 2069 0020 0063;2;0;0 0 0 2 1 1 0 0 0;0 1 2 5 4 3 6 7 8
+0061 0020 2067 0062 0020 05D0 2069 0020 0063;1;1;2 2 2 4 3 3 2 2 2;0 1 2 5 4 3 6 7 8
+05D0 0020 2066 0061 0020 05D1 2069 0020 05D2;2;1;1 1 1 2 2 3 1 1 1;8 7 6 3 4 5 2 1 0
+05D0 0020 2066 0061 0020 05D1 2069 0020 05D2;0;0;1 1 1 2 2 3 1 1 1;8 7 6 3 4 5 2 1 0
+2068 05D0 0020 0061 2069 0020 0062;0;0;0 1 1 2 0 0 0;0 3 2 1 4 5 6
+2068 05D0 0020 0061 2069 0020 0062;1;1;1 3 3 4 1 1 2;6 5 4 3 2 1 0
+0061 2067 2066 0062 2069;0;0;0 0 1 2 0;0 1 3 2 4
+0061 2067 2066 0062 2069;1;1;2 1 3 4 1;4 3 2 1 0
+2069 0061;1;1;1 2;1 0
+0061 0062 0020 2067 0063 0064 0020 05D0 05D1 2069 0020 0065 0066;0;0;0 0 0 0 2 2 1 1 1 0 0 0 0;0 1 2 3 8 7 6 4 5 9 10 11 12
+0061 0062 0020 2067 0063 0064 0020 05D0 05D1 2069 0020 0065 0066;1;1;2 2 2 2 4 4 3 3 3 2 2 2 2;0 1 2 3 8 7 6 4 5 9 10 11 12
+05D0 05D1 0028 05D2 05D3 005B 0026 0065 0066 005D 002E 0029 0067 0068;0;0;1 1 0 1 1 0 0 0 0 0 0 0 0 0;1 0 2 4 3 5 6 7 8 9 10 11 12 13
+05D0 05D1 0028 05D2 05D3 005B 0026 0065 0066 005D 002E 0029 0067 0068;1;1;1 1 1 1 1 1 1 2 2 1 1 1 2 2;12 13 11 10 9 7 8 6 5 4 3 2 1 0
+05D0 05D1 0028 05D2 05D3 005B 0026 0065 0066 005D 002E 0029 0067 0068;2;1;1 1 1 1 1 1 1 2 2 1 1 1 2 2;12 13 11 10 9 7 8 6 5 4 3 2 1 0
+0061 0028 0062 0029 05D0;1;1;2 2 2 2 1;4 0 1 2 3
+05D0 0028 0062 0029 0063;0;0;1 0 0 0 0;0 1 2 3 4
+05D0 05D1 0020 0028 0063 0064 0029;2;1;1 1 1 1 2 2 1;6 4 5 3 2 1 0
+0061 0062 0020 0028 05D2 05D3 0029 0020 0065;2;0;0 0 0 0 1 1 0 0 0;0 1 2 3 5 4 6 7 8
+05D0 2329 0062 3009;0;0;1 0 0 0;0 1 2 3
+05D0 2329 0062 3009;1;1;1 1 2 1;3 2 1 0
+05D0 0028 0061 005B 0062 0029 0063 005D;0;0;1 0 0 0 0 0 0 0;0 1 2 3 4 5 6 7
+05D0 0028 0061 005B 0062 0029 0063 005D;1;1;1 1 2 2 2 1 2 1;7 6 5 2 3 4 1 0
+05D0 0028 0301 0062 0029 0301;1;1;1 1 1 2 1 1;5 4 3 2 1 0
+0061 0020 05D0 0020 0009 0020 0062 0020;1;1;2 1 1 1 1 1 2 1;7 6 5 4 3 2 1 0
+05D0 0020 2067 0061 2069 0020;0;0;1 0 0 2 0 0;0 1 2 3 4 5
+0061 0062 0020 0009;1;1;2 2 1 1;3 2 0 1
+05D0 0020 0031 002E 0035 0025 0020 05D1;2;1;1 1 2 2 2 2 1 1;7 6 2 3 4 5 1 0
+0628 0020 0031 0032 0020 0628;2;1;1 1 2 2 1 1;5 4 2 3 1 0
+0061 0020 0661 0662 0663 0020 0062;2;0;0 0 2 2 2 0 0;0 1 2 3 4 5 6
+0061 0020 0661 0662 0663 0020 0062;1;1;2 1 2 2 2 1 2;6 5 2 3 4 1 0
+202B 0061 0062 202C 0020 0063;0;0;x 2 2 x 0 0;1 2 4 5
+202E 0061 0020 0062 202C;0;0;x 1 1 1 x;3 2 1
+0061 0062 0063 0020 05D0 05D1 05D2 0020 0064 0065 0066;2;0;0 0 0 0 1 1 1 0 0 0 0;0 1 2 3 6 5 4 7 8 9 10
+05D0 05D1 05D2 0020 0061 0062 0063;2;1;1 1 1 1 2 2 2;4 5 6 3 2 1 0
+0061 00AD 05D1;0;0;0 x 1;0 2
+`
+
+func TestBidi(t *testing.T) {
+	for _, line := range strings.Split(strings.TrimSpace(bidiTests), "\n") {
+		f := strings.Split(line, ";")
+		var text []rune
+		for _, cp := range strings.Fields(f[0]) {
+			r, _ := strconv.ParseUint(cp, 16, 32)
+			text = append(text, rune(r))
+		}
+		dir := map[string]Direction{"0": LeftToRight, "1": RightToLeft, "2": DirectionAuto}[f[1]]
+
+		p := newBidiParagraph(string(text), dir)
+		p.resolveExplicit()
+		for _, seq := range p.isolatingRunSequences() {
+			p.resolveSequence(seq)
+		}
+		p.resolveLineEnds()
+
+		var levels []string
+		for i, l := range p.levels {
+			if p.removed[i] {
+				levels = append(levels, "x")
+			} else {
+				levels = append(levels, strconv.Itoa(int(l)))
+			}
+		}
+		index := map[int]int{}
+		for i, pos := range p.pos {
+			index[pos] = i
+		}
+		var order []string
+		for _, run := range bidiRuns(string(text), dir) {
+			var is []string
+			for pos := run.start; pos < run.end; pos++ {
+				if i, ok := index[pos]; ok && !p.removed[i] {
+					is = append(is, strconv.Itoa(i))
+				}
+			}
+			if run.rtl() {
+				slices.Reverse(is)
+			}
+			order = append(order, is...)
+		}
+
+		got := fmt.Sprintf("%d;%s;%s", p.level, strings.Join(levels, " "), strings.Join(order, " "))
+		if want := strings.Join(f[2:], ";"); got != want {
+			t.Errorf("%s;%s: got %s, want %s", f[0], f[1], got, want)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	for _, c := range [][2]rune{{'(', ')'}, {'[', ']'}, {'<', '>'}, {'«', '»'}, {'≤', '≥'}, {'∈', '∋'}, {'〈', '〉'}} {
+		if mirror(c[0]) != c[1] || mirror(c[1]) != c[0] {
+			t.Errorf("mirror(%q) = %q, mirror(%q) = %q", c[0], mirror(c[0]), c[1], mirror(c[1]))
+		}
+	}
+	if mirror('a') != 'a' {
+		t.Error("mirror('a') != 'a'")
+	}
+}
@@ -0,0 +1,71 @@
+package gui
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// A capturer is a Renderer that can read back the pixels of the frame it last
+// drew.
+type capturer interface {
+	// capture returns the frame as it appears on screen, with its first row at
+	// the top.
+	capture() *image.RGBA
+}
+
+// Capture paints w and returns its contents at framebuffer resolution, which
+// is larger than w's size on HiDPI displays.  Areas that nothing is painted on
+// appear black, as on screen.  It must not be called from w's goroutine.
+func (w *Window) Capture() *image.RGBA {
+	var img *image.RGBA
+	w.Do(func() { img = w.capture() })
+	return img
+}
+
+// CaptureView paints v's Window and returns the part of its contents covered
+// by v, at framebuffer resolution.  It must not be called from the Window's
+// goroutine.
+func CaptureView(v View) *image.RGBA {
+	w := v.win()
+	if w == nil {
+		panic("gui.CaptureView called on windowless View")
+	}
+	var img *image.RGBA
+	w.Do(func() {
+		full := w.capture()
+		r := InnerRect(v)
+		bounds := ZR
+		for i, p := range []Point{r.Min, Pt(r.Max.X, r.Min.Y), r.Max, Pt(r.Min.X, r.Max.Y)} {
+			p = MapToParent(Map(p, v, w.Self), w.Self)
+			if i == 0 {
+				bounds = Rectangle{p, p}
+			} else {
+				bounds = bounds.Union(Rectangle{p, p})
+			}
+		}
+		ax := float64(w.bufWidth) / w.size.X
+		ay := float64(w.bufHeight) / w.size.Y
+		px := image.Rect(int(math.Floor(ax*bounds.Min.X)), w.bufHeight-int(math.Ceil(ay*bounds.Max.Y)),
+			int(math.Ceil(ax*bounds.Max.X)), w.bufHeight-int(math.Floor(ay*bounds.Min.Y))).Intersect(full.Rect)
+		img = image.NewRGBA(image.Rectangle{Max: px.Size()})
+		draw.Draw(img, img.Rect, full, px.Min, draw.Src)
+	})
+	return img
+}
+
+func (w *Window) capture() *image.RGBA {
+	w.paintFrame()
+	img := w.renderer.(capturer).capture()
+	w.swapBuffers()
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+func (r *SoftRenderer) capture() *image.RGBA {
+	img := image.NewRGBA(r.img.Rect)
+	copy(img.Pix, r.img.Pix)
+	return img
+}
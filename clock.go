@@ -0,0 +1,94 @@
+package gui
+
+import "time"
+
+// A clock tells a Window's time and schedules periodic work on its goroutine.
+type clock interface {
+	now() time.Time
+	// every calls f on the Window's goroutine every d until stop is called.
+	// stop must be called on the Window's goroutine.
+	every(d time.Duration, f func()) (stop func())
+}
+
+// realClock follows the system clock.
+type realClock struct {
+	w *Window
+}
+
+func (c realClock) now() time.Time { return time.Now() }
+
+func (c realClock) every(d time.Duration, f func()) func() {
+	stop := make(chan chan bool)
+	go func() {
+		tick := time.NewTicker(d)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				select {
+				case c.w.do <- f:
+				case ch := <-stop:
+					ch <- true
+					return
+				}
+			case ch := <-stop:
+				ch <- true
+				return
+			}
+		}
+	}()
+	return func() {
+		ch := make(chan bool)
+		stop <- ch
+		<-ch
+	}
+}
+
+// virtualClock only moves when advanced, so that headless Windows behave
+// deterministically.  It is only accessed from its Window's goroutine.
+type virtualClock struct {
+	t      time.Time
+	timers []*virtualTimer
+}
+
+type virtualTimer struct {
+	next time.Time
+	d    time.Duration
+	f    func()
+}
+
+func (c *virtualClock) now() time.Time { return c.t }
+
+func (c *virtualClock) every(d time.Duration, f func()) func() {
+	t := &virtualTimer{c.t.Add(d), d, f}
+	c.timers = append(c.timers, t)
+	return func() {
+		for i, t2 := range c.timers {
+			if t2 == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// advance moves the clock forward by d, calling timers in the order they fall
+// due.
+func (c *virtualClock) advance(d time.Duration) {
+	end := c.t.Add(d)
+	for {
+		var next *virtualTimer
+		for _, t := range c.timers {
+			if !t.next.After(end) && (next == nil || t.next.Before(next.next)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.t = next.next
+		next.next = next.next.Add(next.d)
+		next.f()
+	}
+	c.t = end
+}
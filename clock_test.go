@@ -0,0 +1,34 @@
+package gui
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVirtualClock(t *testing.T) {
+	c := &virtualClock{t: time.Unix(0, 0)}
+	var calls []string
+	var times []time.Duration
+	call := func(name string) func() {
+		return func() {
+			calls = append(calls, name)
+			times = append(times, c.now().Sub(time.Unix(0, 0)))
+		}
+	}
+	c.every(3*time.Second, call("a"))
+	stop := c.every(2*time.Second, call("b"))
+	c.advance(4 * time.Second)
+	stop()
+	c.advance(3 * time.Second)
+
+	if want := []string{"b", "a", "b", "a"}; !slices.Equal(calls, want) {
+		t.Errorf("calls are %v; want %v", calls, want)
+	}
+	if want := []time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 6 * time.Second}; !slices.Equal(times, want) {
+		t.Errorf("call times are %v; want %v", times, want)
+	}
+	if got, want := c.now(), time.Unix(7, 0); !got.Equal(want) {
+		t.Errorf("now is %v; want %v", got, want)
+	}
+}
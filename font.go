@@ -0,0 +1,226 @@
+package gui
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	_ "embed"
+	"io/fs"
+	"math"
+	"sync"
+)
+
+// A Font is a TrueType or OpenType typeface at a particular size.  Its metrics
+// and glyph outlines come from a pure-Go parser, so that text can be measured
+// anywhere, without an OpenGL context or a Window.  Its size is the height of
+// an em in the coordinates text is drawn in, and its metrics are in the same
+// units.  A Font may be used from any goroutine.
+type Font struct {
+	face *fontFace
+	ppem fixed.Int26_6
+	dir  Direction
+}
+
+// A fontFace is a parsed font file, shared by the Fonts of each size made
+// from it.
+type fontFace struct {
+	mu       sync.Mutex
+	f        *sfnt.Font
+	buf      sfnt.Buffer
+	ot       *otLayout
+	shapings map[shapingKey]shaping
+	// familyName, weight and italic are set when the face is registered,
+	// and are otherwise zero.
+	familyName string
+	weight     FontWeight
+	italic     bool
+}
+
+//go:embed "Times New Roman.ttf"
+var defaultFontData []byte
+
+var defaultFont struct {
+	sync.Once
+	f *Font
+}
+
+// DefaultFont returns the Font that Text uses unless given another: Times New
+// Roman at size 18.
+func DefaultFont() *Font {
+	defaultFont.Do(func() { defaultFont.f = LookupFont(FontStyle{}) })
+	return defaultFont.f
+}
+
+// ParseFont parses a TrueType or OpenType font file and returns its typeface at
+// the given size.  data must not be modified afterward.
+func ParseFont(data []byte, size float64) (*Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return (&Font{face: &fontFace{f: f, ot: parseOTLayout(data)}}).WithSize(size), nil
+}
+
+// LoadFont reads the font file name from fsys and parses it as ParseFont does.
+// Use os.DirFS to load a file from disk, or an embed.FS to load one built into
+// the program.
+func LoadFont(fsys fs.FS, name string, size float64) (*Font, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFont(data, size)
+}
+
+// WithSize returns f's typeface at another size.
+func (f *Font) WithSize(size float64) *Font {
+	return &Font{f.face, fixed.Int26_6(math.Round(size * 64)), f.dir}
+}
+
+// Size returns the height of f's em.
+func (f *Font) Size() float64 { return fixedFloat(f.ppem) }
+
+// WithDirection returns f laying out text in direction d.  Text that mixes
+// left-to-right and right-to-left scripts is ordered for display relative to
+// this direction: "abc אבג" starts at the left from left to right, and at the
+// right from right to left.  The default, DirectionAuto, takes the direction
+// from the first letter of each text.
+func (f *Font) WithDirection(d Direction) *Font {
+	return &Font{f.face, f.ppem, d}
+}
+
+// Direction returns the direction f lays out text in.
+func (f *Font) Direction() Direction { return f.dir }
+
+// Advance returns the width of text: the distance from the start of its first
+// glyph to where a glyph following it would start, as shaped.
+func (f *Font) Advance(text string) float64 {
+	_, adv := f.layout(text)
+	return adv
+}
+
+// Ascender returns how far f's glyphs extend above the baseline.
+func (f *Font) Ascender() float64 {
+	m := f.metrics()
+	return fixedFloat(m.Ascent)
+}
+
+// Descender is negative for fonts that extend below the baseline.
+func (f *Font) Descender() float64 {
+	m := f.metrics()
+	return -fixedFloat(m.Descent)
+}
+
+// LineHeight returns the recommended distance between the baselines of
+// consecutive lines of text.
+func (f *Font) LineHeight() float64 {
+	m := f.metrics()
+	return fixedFloat(m.Height)
+}
+
+// Kern returns the adjustment to the distance between the glyphs of a and b
+// when b follows a, which is usually negative or zero.
+func (f *Font) Kern(a, b rune) float64 {
+	return f.Advance(string([]rune{a, b})) - f.Advance(string(a)) - f.Advance(string(b))
+}
+
+func (f *Font) metrics() font.Metrics {
+	f.face.mu.Lock()
+	defer f.face.mu.Unlock()
+	m, _ := f.face.f.Metrics(&f.face.buf, f.ppem, font.HintingNone)
+	return m
+}
+
+// A glyph is a glyph of a Font positioned along a line of text.
+type glyph struct {
+	index sfnt.GlyphIndex
+	// x and y are the offset of the glyph's origin from the start of the
+	// line, with Y increasing upward.
+	x, y    float64
+	adv     float64 // how far it advances the line; 0 for attached marks
+	cluster int     // the byte offset in the text of the first rune it came from
+	rtl     bool    // whether it is in right-to-left text
+}
+
+// layout shapes text and returns its glyphs, in the order they are
+// displayed, and its total advance.
+func (f *Font) layout(text string) ([]glyph, float64) {
+	f.face.mu.Lock()
+	defer f.face.mu.Unlock()
+	shaped, adv := f.face.shape(text, f.dir)
+	scale := f.Size() / float64(f.face.f.UnitsPerEm())
+	glyphs := make([]glyph, len(shaped))
+	for i, g := range shaped {
+		a := 0.0
+		if g.attach == 0 {
+			a = float64(g.adv) * scale
+		}
+		glyphs[i] = glyph{g.index, float64(g.x) * scale, float64(g.y) * scale, a, g.cluster, g.rtl}
+	}
+	return glyphs, float64(adv) * scale
+}
+
+// family returns the name of the font's family, such as "Times New Roman".
+func (f *Font) family() string {
+	f.face.mu.Lock()
+	defer f.face.mu.Unlock()
+	if f.face.familyName != "" {
+		return f.face.familyName
+	}
+	name, _ := f.face.f.Name(&f.face.buf, sfnt.NameIDFamily)
+	return name
+}
+
+// style returns the weight and slant the font was registered with.
+func (f *Font) style() (FontWeight, bool) {
+	f.face.mu.Lock()
+	defer f.face.mu.Unlock()
+	return f.face.weight, f.face.italic
+}
+
+func (f *fontFace) setStyle(family string, weight FontWeight, italic bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.familyName, f.weight, f.italic = family, weight, italic
+}
+
+// use calls g with the parsed font and a buffer for its methods.
+func (f *Font) use(g func(*sfnt.Font, *sfnt.Buffer)) {
+	f.face.mu.Lock()
+	defer f.face.mu.Unlock()
+	g(f.face.f, &f.face.buf)
+}
+
+// outline traces the outline of text with its baseline starting at the
+// origin, transformed by m, to s.  The Y axis of the untransformed outline
+// increases upward.
+func (f *Font) outline(text string, m Affine2D, s pathSink) {
+	glyphs, _ := f.layout(text)
+	for _, g := range glyphs {
+		f.face.glyphOutline(g.index, f.Size(), m.Translate(Pt(g.x, g.y)), s)
+	}
+}
+
+// glyphOutline traces the outline of glyph g at ppem pixels per em,
+// transformed by m, to s.
+func (f *fontFace) glyphOutline(g sfnt.GlyphIndex, ppem float64, m Affine2D, s pathSink) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pt := func(p fixed.Point26_6) Point { return m.Apply(Pt(fixedFloat(p.X), -fixedFloat(p.Y))) }
+	segs, _ := f.f.LoadGlyph(&f.buf, g, fixed.Int26_6(ppem*64), nil)
+	for _, seg := range segs {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			s.moveTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			s.lineTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			s.quadTo(pt(seg.Args[0]), pt(seg.Args[1]))
+		case sfnt.SegmentOpCubeTo:
+			s.cubeTo(pt(seg.Args[0]), pt(seg.Args[1]), pt(seg.Args[2]))
+		}
+	}
+}
+
+func fixedFloat(x fixed.Int26_6) float64 { return float64(x) / 64 }
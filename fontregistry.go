@@ -0,0 +1,220 @@
+package gui
+
+import (
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomediumitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A FontWeight is the thickness of a typeface's strokes, from 100 to 900 as in
+// OpenType and CSS.
+type FontWeight int
+
+const (
+	WeightThin       FontWeight = 100
+	WeightExtraLight FontWeight = 200
+	WeightLight      FontWeight = 300
+	WeightRegular    FontWeight = 400
+	WeightMedium     FontWeight = 500
+	WeightSemiBold   FontWeight = 600
+	WeightBold       FontWeight = 700
+	WeightExtraBold  FontWeight = 800
+	WeightBlack      FontWeight = 900
+)
+
+// A FontStyle describes a Font to look up among the registered typefaces.
+type FontStyle struct {
+	// Family is the name of a family registered with RegisterFont, such as
+	// "Go", or one of the generic families "serif", "sans-serif" and
+	// "monospace".  Names are not case-sensitive.  An unknown or empty
+	// Family selects the family of DefaultFont.
+	Family string
+	// Weight is WeightRegular if zero.
+	Weight FontWeight
+	Italic bool
+	// Size is 18 if zero.
+	Size float64
+}
+
+// A registeredFont is a typeface in the registry, which is parsed when it is
+// first looked up.
+type registeredFont struct {
+	family string
+	weight FontWeight
+	italic bool
+	once   sync.Once
+	data   []byte
+	font   *Font
+}
+
+func (r *registeredFont) get() *Font {
+	r.once.Do(func() {
+		if r.font != nil {
+			return
+		}
+		f, err := ParseFont(r.data, defaultFontSize)
+		if err != nil {
+			panic(err)
+		}
+		f.face.setStyle(r.family, r.weight, r.italic)
+		r.font, r.data = f, nil
+	})
+	return r.font
+}
+
+var fontRegistry = struct {
+	sync.Mutex
+	families map[string][]*registeredFont // keyed by lowercase name
+	names    map[string]string            // the registered spelling of each family
+}{
+	families: map[string][]*registeredFont{},
+	names:    map[string]string{},
+}
+
+const (
+	defaultFontFamily = "times new roman"
+	defaultFontSize   = 18
+)
+
+// genericFamilies maps the generic family names to the built-in families.
+var genericFamilies = map[string]string{
+	"serif":      defaultFontFamily,
+	"sans-serif": "go",
+	"monospace":  "go mono",
+}
+
+func init() {
+	registerFontData("Times New Roman", WeightRegular, false, defaultFontData)
+	for _, f := range []struct {
+		family string
+		weight FontWeight
+		italic bool
+		data   []byte
+	}{
+		{"Go", WeightRegular, false, goregular.TTF},
+		{"Go", WeightRegular, true, goitalic.TTF},
+		{"Go", WeightMedium, false, gomedium.TTF},
+		{"Go", WeightMedium, true, gomediumitalic.TTF},
+		{"Go", WeightBold, false, gobold.TTF},
+		{"Go", WeightBold, true, gobolditalic.TTF},
+		{"Go Mono", WeightRegular, false, gomono.TTF},
+		{"Go Mono", WeightRegular, true, gomonoitalic.TTF},
+		{"Go Mono", WeightBold, false, gomonobold.TTF},
+		{"Go Mono", WeightBold, true, gomonobolditalic.TTF},
+	} {
+		registerFontData(f.family, f.weight, f.italic, f.data)
+	}
+}
+
+// RegisterFont adds f's typeface to family as its variant of the given weight
+// and slant, replacing any variant already registered for them.  The
+// typeface may then be looked up at any size with LookupFont.
+func RegisterFont(family string, weight FontWeight, italic bool, f *Font) {
+	f.face.setStyle(family, weight, italic)
+	registerFont(&registeredFont{family: family, weight: weight, italic: italic, font: f})
+}
+
+func registerFontData(family string, weight FontWeight, italic bool, data []byte) {
+	registerFont(&registeredFont{family: family, weight: weight, italic: italic, data: data})
+}
+
+func registerFont(f *registeredFont) {
+	fontRegistry.Lock()
+	defer fontRegistry.Unlock()
+	key := strings.ToLower(f.family)
+	fontRegistry.names[key] = f.family
+	fonts := fontRegistry.families[key]
+	for i, g := range fonts {
+		if g.weight == f.weight && g.italic == f.italic {
+			fonts[i] = f
+			return
+		}
+	}
+	fontRegistry.families[key] = append(fonts, f)
+}
+
+// FontFamilies returns the names of the registered families, sorted.
+func FontFamilies() []string {
+	fontRegistry.Lock()
+	defer fontRegistry.Unlock()
+	var names []string
+	for _, name := range fontRegistry.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupFont returns the registered typeface that best matches s, at s.Size.
+// As in CSS, a variant of the right slant is preferred over one of the right
+// weight, and a missing weight is substituted by the nearest one, lighter or
+// heavier depending on which side of regular it is.
+func LookupFont(s FontStyle) *Font {
+	if s.Weight == 0 {
+		s.Weight = WeightRegular
+	}
+	if s.Size == 0 {
+		s.Size = defaultFontSize
+	}
+	key := strings.ToLower(s.Family)
+	if g, ok := genericFamilies[key]; ok {
+		key = g
+	}
+
+	fontRegistry.Lock()
+	fonts := fontRegistry.families[key]
+	if len(fonts) == 0 {
+		fonts = fontRegistry.families[defaultFontFamily]
+	}
+	var best *registeredFont
+	bestPenalty := 0
+	for _, f := range fonts {
+		p := weightPenalty(s.Weight, f.weight)
+		if f.italic != s.Italic {
+			p += 10000
+		}
+		if best == nil || p < bestPenalty {
+			best, bestPenalty = f, p
+		}
+	}
+	fontRegistry.Unlock()
+	return best.get().WithSize(s.Size)
+}
+
+// weightPenalty orders the weights that substitute for want, as CSS does.
+func weightPenalty(want, have FontWeight) int {
+	d := int(have - want)
+	switch {
+	case want >= WeightRegular && want <= WeightMedium:
+		// Heavier up to medium, then lighter, then heavier.
+		if d >= 0 && have <= WeightMedium {
+			return d
+		}
+		if d < 0 {
+			return 1000 - d
+		}
+		return 2000 + d
+	case want < WeightRegular:
+		// Lighter, then heavier.
+		if d <= 0 {
+			return -d
+		}
+		return 1000 + d
+	}
+	// Heavier, then lighter.
+	if d >= 0 {
+		return d
+	}
+	return 1000 - d
+}
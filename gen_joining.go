@@ -0,0 +1,120 @@
+//go:build ignore
+
+// gen_joining generates arabicjoining.go from the Unicode Character Database's
+// ArabicShaping.txt.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const version = "14.0.0"
+
+// names are the names in shape.go of the joining types and of the joining
+// groups whose letters Syriac shapes specially.
+var names = map[string]string{
+	"U":           "joinNone",
+	"R":           "joinRight",
+	"L":           "joinLeft",
+	"D":           "joinDual",
+	"C":           "joinCausing",
+	"T":           "joinTransparent",
+	"ALAPH":       "joinAlaph",
+	"DALATH RISH": "joinDalathRish",
+}
+
+func main() {
+	types := map[rune]string{}
+	fetch("ArabicShaping.txt", func(fields []string) {
+		r, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t := fields[2]
+		if g := fields[3]; g == "ALAPH" || g == "DALATH RISH" {
+			t = g
+		}
+		types[rune(r)] = t
+	})
+
+	// Unlisted runes are transparent if they are marks or format characters
+	// and otherwise non-joining, so only the runes whose types differ are
+	// kept.
+	var runes []rune
+	for r, t := range types {
+		def := "U"
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+			def = "T"
+		}
+		if t != def {
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen_joining.go from ArabicShaping.txt of Unicode %s; DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&b, "package gui\n\n")
+	fmt.Fprintf(&b, "// arabicJoining holds the ranges of runes whose joining types are not\n")
+	fmt.Fprintf(&b, "// joinTransparent for marks and format characters and joinNone for the rest.\n")
+	fmt.Fprintf(&b, "var arabicJoining = []struct {\n\tlo, hi rune\n\ttyp    byte\n}{\n")
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && runes[j] == runes[j-1]+1 && types[runes[j]] == types[runes[i]] {
+			j++
+		}
+		name, ok := names[types[runes[i]]]
+		if !ok {
+			log.Fatalf("unknown joining type %q of U+%04X", types[runes[i]], runes[i])
+		}
+		fmt.Fprintf(&b, "\t{0x%04X, 0x%04X, %s},\n", runes[i], runes[j-1], name)
+		i = j
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("arabicjoining.go", src, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fetch calls f with the semicolon-separated fields of each line of the named
+// file of the Unicode Character Database.
+func fetch(name string, f func(fields []string)) {
+	resp, err := http.Get("https://www.unicode.org/Public/" + version + "/ucd/" + name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatal(name, ": ", resp.Status)
+	}
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		f(fields)
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
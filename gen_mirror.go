@@ -0,0 +1,66 @@
+//go:build ignore
+
+// gen_mirror generates bidimirror.go from the Unicode Character Database's
+// BidiMirroring.txt.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const version = "14.0.0"
+
+func main() {
+	resp, err := http.Get("https://www.unicode.org/Public/" + version + "/ucd/BidiMirroring.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Fatal(resp.Status)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen_mirror.go from BidiMirroring.txt of Unicode %s; DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&b, "package gui\n\n")
+	fmt.Fprintf(&b, "// mirrored maps each character whose glyph is mirrored in right-to-left text\n")
+	fmt.Fprintf(&b, "// to the character whose glyph is its mirror image (Bidi_Mirroring_Glyph).\n")
+	fmt.Fprintf(&b, "var mirrored = map[rune]rune{\n")
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		from, to, ok := strings.Cut(line, ";")
+		if !ok {
+			continue
+		}
+		a, err := strconv.ParseUint(strings.TrimSpace(from), 16, 32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c, err := strconv.ParseUint(strings.TrimSpace(to), 16, 32)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&b, "\t0x%04X: 0x%04X,\n", a, c)
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("bidimirror.go", src, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package gui
 
 import (
 	. "math"
+	"sort"
 	"strconv"
 )
 
@@ -238,6 +239,12 @@ func (r Rectangle) Canon() Rectangle {
 	return r
 }
 
+// corners returns the corners of r, counterclockwise in Y-up coordinates
+// starting from r.Min.
+func (r Rectangle) corners() []Point {
+	return []Point{r.Min, Pt(r.Max.X, r.Min.Y), r.Max, Pt(r.Min.X, r.Max.Y)}
+}
+
 // ZR is the zero Rectangle.
 var ZR Rectangle
 
@@ -292,3 +299,456 @@ func LineToLine(p, p2, q, q2 Point) (z, z2 Point) {
 	}
 	return
 }
+
+// A Bezier is a Bézier curve of any degree, given by its control points.  It
+// runs from its first control point at t=0 to its last at t=1.
+type Bezier []Point
+
+// Degree returns the degree of b, which is one less than its number of control
+// points.
+func (b Bezier) Degree() int {
+	return len(b) - 1
+}
+
+// At returns the point on b at t, or the origin if b has no control points.
+func (b Bezier) At(t float64) Point {
+	if len(b) == 0 {
+		return ZP
+	}
+	tmp := make([]Point, len(b))
+	copy(tmp, b)
+	for n := len(tmp) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			tmp[i] = tmp[i].Mul(1 - t).Add(tmp[i+1].Mul(t))
+		}
+	}
+	return tmp[0]
+}
+
+// Derivative returns the curve of b's derivative with respect to t, which is
+// one degree lower.
+func (b Bezier) Derivative() Bezier {
+	if len(b) < 2 {
+		return Bezier{ZP}
+	}
+	n := float64(len(b) - 1)
+	d := make(Bezier, len(b)-1)
+	for i := range d {
+		d[i] = b[i+1].Sub(b[i]).Mul(n)
+	}
+	return d
+}
+
+// Split returns the parts of b before and after t, each a curve of the same
+// degree running from t=0 to t=1.
+func (b Bezier) Split(t float64) (Bezier, Bezier) {
+	n := len(b)
+	left, right := make(Bezier, n), make(Bezier, n)
+	tmp := make([]Point, n)
+	copy(tmp, b)
+	for k := 0; k < n; k++ {
+		left[k] = tmp[0]
+		right[n-1-k] = tmp[n-1-k]
+		for i := 0; i < n-1-k; i++ {
+			tmp[i] = tmp[i].Mul(1 - t).Add(tmp[i+1].Mul(t))
+		}
+	}
+	return left, right
+}
+
+// Bounds returns the smallest Rectangle containing b.
+func (b Bezier) Bounds() Rectangle {
+	if len(b) == 0 {
+		return ZR
+	}
+	r := Rectangle{b[0], b[0]}
+	add := func(p Point) { r = r.Union(Rectangle{p, p}) }
+	add(b[len(b)-1])
+	d := b.Derivative()
+	xs, ys := make([]float64, len(d)), make([]float64, len(d))
+	for i, p := range d {
+		xs[i], ys[i] = p.X, p.Y
+	}
+	for _, t := range append(bernsteinRoots(xs), bernsteinRoots(ys)...) {
+		add(b.At(t))
+	}
+	return r
+}
+
+// hull returns the bounds of b's control points, which contain b.
+func (b Bezier) hull() Rectangle {
+	r := Rectangle{b[0], b[0]}
+	for _, p := range b[1:] {
+		r = r.Union(Rectangle{p, p})
+	}
+	return r
+}
+
+// Length returns the arc length of b.
+func (b Bezier) Length() float64 {
+	return b.length(0)
+}
+
+func (b Bezier) length(depth int) float64 {
+	if len(b) < 2 {
+		return 0
+	}
+	chord := b[len(b)-1].Sub(b[0]).Len()
+	poly := 0.0
+	for i := 1; i < len(b); i++ {
+		poly += b[i].Sub(b[i-1]).Len()
+	}
+	// The length lies between the chord and the control polygon, which
+	// converge as the curve is split.
+	if poly-chord <= 1e-9*(1+poly) || depth >= 30 {
+		n := float64(len(b) - 1)
+		return (2*chord + (n-1)*poly) / (n + 1)
+	}
+	l, r := b.Split(.5)
+	return l.length(depth+1) + r.length(depth+1)
+}
+
+// Flatten returns a polyline from the start to the end of b that is nowhere
+// farther than tolerance from it.
+func (b Bezier) Flatten(tolerance float64) []Point {
+	if len(b) == 0 {
+		return nil
+	}
+	pts := []Point{b[0]}
+	var flatten func(b Bezier, depth int)
+	flatten = func(b Bezier, depth int) {
+		if depth < 24 && !b.flat(tolerance) {
+			l, r := b.Split(.5)
+			flatten(l, depth+1)
+			flatten(r, depth+1)
+			return
+		}
+		pts = append(pts, b[len(b)-1])
+	}
+	flatten(b, 0)
+	return pts
+}
+
+// flat returns whether all of b's control points, and so b, are within
+// tolerance of its chord.
+func (b Bezier) flat(tolerance float64) bool {
+	if len(b) <= 2 {
+		return true
+	}
+	p, q := b[0], b[len(b)-1]
+	for _, c := range b[1 : len(b)-1] {
+		if c.Sub(PointToLine(c, p, q)).Len() > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// Nearest returns the parameter t of the point on b nearest to p, and that
+// point.
+func (b Bezier) Nearest(p Point) (t float64, q Point) {
+	if len(b) == 0 {
+		return 0, ZP
+	}
+	// Find the nearest of some samples and refine it by Newton's method on the
+	// derivative of the squared distance.
+	n := 8 * len(b)
+	best := Inf(1)
+	for i := 0; i <= n; i++ {
+		s := float64(i) / float64(n)
+		if d := b.At(s).Sub(p).Len(); d < best {
+			best, t = d, s
+		}
+	}
+	d1 := b.Derivative()
+	d2 := d1.Derivative()
+	for i := 0; i < 16; i++ {
+		v := b.At(t).Sub(p)
+		v1 := d1.At(t)
+		f := v.Dot(v1)
+		df := v1.Dot(v1) + v.Dot(d2.At(t))
+		if df == 0 {
+			break
+		}
+		s := Max(0, Min(1, t-f/df))
+		if b.At(s).Sub(p).Len() > b.At(t).Sub(p).Len() {
+			break
+		}
+		if Abs(s-t) < 1e-12 {
+			t = s
+			break
+		}
+		t = s
+	}
+	return t, b.At(t)
+}
+
+// IntersectLine returns, in increasing order, the parameters t at which b
+// crosses the line segment (p, q).
+func (b Bezier) IntersectLine(p, q Point) []float64 {
+	pq := q.Sub(p)
+	l2 := pq.Dot(pq)
+	if len(b) == 0 || l2 == 0 {
+		return nil
+	}
+	// The signed distances of the control points from the line are the
+	// coefficients of b's distance from it.
+	c := make([]float64, len(b))
+	for i, x := range b {
+		c[i] = pq.Cross(x.Sub(p))
+	}
+	var ts []float64
+	for _, t := range bernsteinRoots(c) {
+		if s := b.At(t).Sub(p).Dot(pq) / l2; s >= -1e-9 && s <= 1+1e-9 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// Intersect returns the parameters (t, u) of the points at which b crosses c,
+// where b.At(t) equals c.At(u), ordered by t.  Where the curves coincide, no
+// intersections are reported.
+func (b Bezier) Intersect(c Bezier) [][2]float64 {
+	if len(b) == 0 || len(c) == 0 {
+		return nil
+	}
+	const tolerance = 1e-7
+	var ts [][2]float64
+	var intersect func(b Bezier, t0, t1 float64, c Bezier, u0, u1 float64, depth int)
+	intersect = func(b Bezier, t0, t1 float64, c Bezier, u0, u1 float64, depth int) {
+		hb, hc := b.hull(), c.hull()
+		if hb.Min.X > hc.Max.X || hc.Min.X > hb.Max.X || hb.Min.Y > hc.Max.Y || hc.Min.Y > hb.Max.Y {
+			return
+		}
+		bflat, cflat := b.flat(tolerance), c.flat(tolerance)
+		if bflat && cflat || depth >= 48 {
+			// Intersect the chords.
+			p, r := b[0], b[len(b)-1].Sub(b[0])
+			q, s := c[0], c[len(c)-1].Sub(c[0])
+			rs := r.Cross(s)
+			if rs == 0 {
+				return
+			}
+			t := q.Sub(p).Cross(s) / rs
+			u := q.Sub(p).Cross(r) / rs
+			if t >= 0 && t <= 1 && u >= 0 && u <= 1 {
+				ts = append(ts, [2]float64{t0 + t*(t1-t0), u0 + u*(u1-u0)})
+			}
+			return
+		}
+		if !bflat && (cflat || hb.Dx()+hb.Dy() >= hc.Dx()+hc.Dy()) {
+			l, r := b.Split(.5)
+			tm := (t0 + t1) / 2
+			intersect(l, t0, tm, c, u0, u1, depth+1)
+			intersect(r, tm, t1, c, u0, u1, depth+1)
+		} else {
+			l, r := c.Split(.5)
+			um := (u0 + u1) / 2
+			intersect(b, t0, t1, l, u0, um, depth+1)
+			intersect(b, t0, t1, r, um, u1, depth+1)
+		}
+	}
+	intersect(b, 0, 1, c, 0, 1, 0)
+
+	sort.Slice(ts, func(i, j int) bool { return ts[i][0] < ts[j][0] })
+	db := b.Derivative()
+	n := 0
+	for _, x := range ts {
+		// Intersections at the ends of pieces are found in each piece.
+		if n > 0 && Abs(x[0]-ts[n-1][0]) < 1e-6 && Abs(x[1]-ts[n-1][1]) < 1e-6 {
+			continue
+		}
+		// Where the curves coincide, the pieces' chords meet all along them.
+		const dt = 1e-4
+		coincide := true
+		for _, t := range []float64{Max(0, x[0]-dt), Min(1, x[0]+dt)} {
+			p := b.At(t)
+			_, q := c.Nearest(p)
+			if p.Sub(q).Len() > 1e-3*dt*db.At(x[0]).Len() {
+				coincide = false
+			}
+		}
+		if coincide {
+			continue
+		}
+		ts[n] = x
+		n++
+	}
+	return ts[:n]
+}
+
+// bernsteinRoots returns, in increasing order, the roots in [0, 1] of the
+// polynomial with Bernstein coefficients c.
+func bernsteinRoots(c []float64) []float64 {
+	scale := 0.0
+	for _, x := range c {
+		scale = Max(scale, Abs(x))
+	}
+	if scale == 0 {
+		return nil
+	}
+	var roots []float64
+	var find func(c []float64, t0, t1 float64)
+	find = func(c []float64, t0, t1 float64) {
+		// The polynomial lies within the convex hull of its coefficients.
+		lo, hi := c[0], c[0]
+		for _, x := range c[1:] {
+			lo, hi = Min(lo, x), Max(hi, x)
+		}
+		if lo > 0 || hi < 0 {
+			return
+		}
+		if Max(-lo, hi) <= 1e-12*scale {
+			// The polynomial vanishes here, so it has no isolated roots.
+			return
+		}
+		if t1-t0 < 1e-10 {
+			t := (t0 + t1) / 2
+			if len(roots) == 0 || t-roots[len(roots)-1] > 1e-7 {
+				roots = append(roots, t)
+			}
+			return
+		}
+		l, r := splitBernstein(c)
+		tm := (t0 + t1) / 2
+		find(l, t0, tm)
+		find(r, tm, t1)
+	}
+	find(c, 0, 1)
+	return roots
+}
+
+// splitBernstein splits the polynomial with Bernstein coefficients c at 1/2.
+func splitBernstein(c []float64) (l, r []float64) {
+	n := len(c)
+	l, r = make([]float64, n), make([]float64, n)
+	tmp := append([]float64(nil), c...)
+	for k := 0; k < n; k++ {
+		l[k] = tmp[0]
+		r[n-1-k] = tmp[n-1-k]
+		for i := 0; i < n-1-k; i++ {
+			tmp[i] = (tmp[i] + tmp[i+1]) / 2
+		}
+	}
+	return l, r
+}
+
+// clipConvex returns the part of the polygon pts inside the convex polygon
+// clip.  It works in the arrays of pts and buf, which must not overlap, and
+// returns the other one for reuse as well.
+func clipConvex(pts, buf, clip []Point) ([]Point, []Point) {
+	orient := 1.0
+	if polygonArea(clip) < 0 {
+		orient = -1
+	}
+	for i, a := range clip {
+		if len(pts) == 0 {
+			break
+		}
+		ab := clip[(i+1)%len(clip)].Sub(a)
+		side := func(p Point) float64 { return orient * ab.Cross(p.Sub(a)) }
+		in := pts
+		pts, buf = buf[:0], in
+		for j, p := range in {
+			q := in[(j+1)%len(in)]
+			sp, sq := side(p), side(q)
+			if sp >= 0 {
+				pts = append(pts, p)
+			}
+			if sp*sq < 0 {
+				pts = append(pts, p.Add(q.Sub(p).Mul(sp/(sp-sq))))
+			}
+		}
+	}
+	return pts, buf
+}
+
+// An Affine2D is the transform taking (x, y) to (A*x + C*y + E, B*x + D*y + F).
+// Its zero value maps every point to the origin; Identity2D leaves points
+// unchanged.
+type Affine2D struct{ A, B, C, D, E, F float64 }
+
+// Identity2D is the Affine2D that leaves points unchanged.
+var Identity2D = Affine2D{1, 0, 0, 1, 0, 0}
+
+// Translation returns the Affine2D that adds p to points.
+func Translation(p Point) Affine2D { return Affine2D{1, 0, 0, 1, p.X, p.Y} }
+
+// Scaling returns the Affine2D that multiplies points by x and y on the
+// respective axes.
+func Scaling(x, y float64) Affine2D { return Affine2D{x, 0, 0, y, 0, 0} }
+
+// Rotation returns the Affine2D that rotates points by rad radians about the
+// origin, from the X axis toward the Y axis.
+func Rotation(rad float64) Affine2D {
+	s, c := Sincos(rad)
+	return Affine2D{c, s, -s, c, 0, 0}
+}
+
+// Skew returns the Affine2D that shears points by angles x and y, in radians,
+// away from the Y and X axes respectively.
+func Skew(x, y float64) Affine2D { return Affine2D{1, Tan(y), Tan(x), 1, 0, 0} }
+
+// Apply returns p transformed by m.
+func (m Affine2D) Apply(p Point) Point {
+	return Point{m.A*p.X + m.C*p.Y + m.E, m.B*p.X + m.D*p.Y + m.F}
+}
+
+// ApplyRect returns the smallest Rectangle containing r transformed by m.
+func (m Affine2D) ApplyRect(r Rectangle) Rectangle {
+	var b Rectangle
+	for i, p := range r.corners() {
+		p = m.Apply(p)
+		if i == 0 {
+			b = Rectangle{p, p}
+		} else {
+			b = b.Union(Rectangle{p, p})
+		}
+	}
+	return b
+}
+
+// Mul returns the transform that applies n and then m.
+func (m Affine2D) Mul(n Affine2D) Affine2D {
+	return Affine2D{
+		m.A*n.A + m.C*n.B,
+		m.B*n.A + m.D*n.B,
+		m.A*n.C + m.C*n.D,
+		m.B*n.C + m.D*n.D,
+		m.A*n.E + m.C*n.F + m.E,
+		m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+// Det returns the determinant of m's linear part, the factor by which m
+// scales areas.  It is negative if m flips orientation and zero if m is not
+// invertible.
+func (m Affine2D) Det() float64 { return m.A*m.D - m.B*m.C }
+
+// Invert returns the inverse of m.  If m is not invertible, Invert returns the
+// zero Affine2D.
+func (m Affine2D) Invert() Affine2D {
+	det := m.Det()
+	if det == 0 {
+		return Affine2D{}
+	}
+	a, b, c, d := m.D/det, -m.B/det, -m.C/det, m.A/det
+	return Affine2D{a, b, c, d, -(a*m.E + c*m.F), -(b*m.E + d*m.F)}
+}
+
+// Linear returns m without its translation.
+func (m Affine2D) Linear() Affine2D { return Affine2D{m.A, m.B, m.C, m.D, 0, 0} }
+
+// Rectilinear returns whether m maps axis-aligned rectangles to axis-aligned
+// rectangles.
+func (m Affine2D) Rectilinear() bool { return m.B == 0 && m.C == 0 || m.A == 0 && m.D == 0 }
+
+// Translate returns the transform that translates by p and then applies m.
+func (m Affine2D) Translate(p Point) Affine2D { return m.Mul(Translation(p)) }
+
+// Scale returns the transform that scales by x and y and then applies m.
+func (m Affine2D) Scale(x, y float64) Affine2D { return m.Mul(Scaling(x, y)) }
+
+// Rotate returns the transform that rotates by rad radians and then applies m.
+func (m Affine2D) Rotate(rad float64) Affine2D { return m.Mul(Rotation(rad)) }
@@ -0,0 +1,166 @@
+package gui
+
+import (
+	"math"
+	"testing"
+)
+
+// A cubic arch, and the parabola y = 2x - x² from (0, 0) to (2, 0).
+var (
+	arch     = Bezier{Pt(0, 0), Pt(0, 1), Pt(1, 1), Pt(1, 0)}
+	parabola = Bezier{Pt(0, 0), Pt(1, 2), Pt(2, 0)}
+)
+
+const eps = 1e-6
+
+func near(a, b float64) bool       { return math.Abs(a-b) < eps }
+func nearPt(p, q Point) bool       { return near(p.X, q.X) && near(p.Y, q.Y) }
+func nearRect(r, s Rectangle) bool { return nearPt(r.Min, s.Min) && nearPt(r.Max, s.Max) }
+
+func nearBezier(b, c Bezier) bool {
+	if len(b) != len(c) {
+		return false
+	}
+	for i := range b {
+		if !nearPt(b[i], c[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBezierSplit(t *testing.T) {
+	for _, c := range []struct {
+		b           Bezier
+		t           float64
+		left, right Bezier
+	}{
+		{arch, .5, Bezier{Pt(0, 0), Pt(0, .5), Pt(.25, .75), Pt(.5, .75)}, Bezier{Pt(.5, .75), Pt(.75, .75), Pt(1, .5), Pt(1, 0)}},
+		{parabola, .25, Bezier{Pt(0, 0), Pt(.25, .5), Pt(.5, .75)}, Bezier{Pt(.5, .75), Pt(1.25, 1.5), Pt(2, 0)}},
+		{Bezier{Pt(0, 0), Pt(4, 8)}, .75, Bezier{Pt(0, 0), Pt(3, 6)}, Bezier{Pt(3, 6), Pt(4, 8)}},
+		{Bezier{Pt(1, 2)}, .5, Bezier{Pt(1, 2)}, Bezier{Pt(1, 2)}},
+	} {
+		l, r := c.b.Split(c.t)
+		if !nearBezier(l, c.left) || !nearBezier(r, c.right) {
+			t.Errorf("%v.Split(%v) = %v, %v; want %v, %v", c.b, c.t, l, r, c.left, c.right)
+		}
+	}
+}
+
+func TestBezierBounds(t *testing.T) {
+	for _, c := range []struct {
+		b    Bezier
+		want Rectangle
+	}{
+		{arch, Rectangle{Pt(0, 0), Pt(1, .75)}},
+		{parabola, Rectangle{Pt(0, 0), Pt(2, 1)}},
+		{Bezier{Pt(3, 1), Pt(-1, 2)}, Rectangle{Pt(-1, 1), Pt(3, 2)}},
+		{Bezier{Pt(1, 2)}, Rectangle{Pt(1, 2), Pt(1, 2)}},
+		{Bezier{}, ZR},
+	} {
+		if got := c.b.Bounds(); !nearRect(got, c.want) {
+			t.Errorf("%v.Bounds() = %v; want %v", c.b, got, c.want)
+		}
+	}
+}
+
+func TestBezierLength(t *testing.T) {
+	for _, c := range []struct {
+		b    Bezier
+		want float64
+	}{
+		{Bezier{Pt(0, 0), Pt(3, 4)}, 5},
+		{Bezier{Pt(0, 0), Pt(1, 0), Pt(2, 0)}, 2},
+		{parabola, math.Sqrt(5) + math.Asinh(2)/2},
+		{Bezier{Pt(1, 2)}, 0},
+	} {
+		if got := c.b.Length(); !near(got, c.want) {
+			t.Errorf("%v.Length() = %v; want %v", c.b, got, c.want)
+		}
+	}
+}
+
+func TestBezierNearest(t *testing.T) {
+	for _, c := range []struct {
+		b     Bezier
+		p     Point
+		t     float64
+		nearP Point
+	}{
+		{parabola, Pt(1, 5), .5, Pt(1, 1)},
+		{Bezier{Pt(0, 0), Pt(10, 0)}, Pt(3, 4), .3, Pt(3, 0)},
+		{Bezier{Pt(0, 0), Pt(10, 0)}, Pt(12, 1), 1, Pt(10, 0)},
+		{arch, Pt(-1, -1), 0, Pt(0, 0)},
+	} {
+		tt, q := c.b.Nearest(c.p)
+		if !near(tt, c.t) || !nearPt(q, c.nearP) {
+			t.Errorf("%v.Nearest(%v) = %v, %v; want %v, %v", c.b, c.p, tt, q, c.t, c.nearP)
+		}
+	}
+}
+
+func TestBezierIntersectLine(t *testing.T) {
+	for _, c := range []struct {
+		b    Bezier
+		p, q Point
+		want []float64
+	}{
+		{parabola, Pt(-1, .75), Pt(3, .75), []float64{.25, .75}},
+		{parabola, Pt(0, .75), Pt(.8, .75), []float64{.25}},
+		{parabola, Pt(-1, 2), Pt(3, 2), nil},
+		{arch, Pt(.5, -1), Pt(.5, 2), []float64{.5}},
+	} {
+		got := c.b.IntersectLine(c.p, c.q)
+		if len(got) != len(c.want) {
+			t.Errorf("%v.IntersectLine(%v, %v) = %v; want %v", c.b, c.p, c.q, got, c.want)
+			continue
+		}
+		for i := range got {
+			if !near(got[i], c.want[i]) {
+				t.Errorf("%v.IntersectLine(%v, %v) = %v; want %v", c.b, c.p, c.q, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestBezierIntersect(t *testing.T) {
+	r := math.Sqrt(2) / 4
+	for _, c := range []struct {
+		b, c Bezier
+		want [][2]float64
+	}{
+		{parabola, Bezier{Pt(-1, .75), Pt(3, .75)}, [][2]float64{{.25, .375}, {.75, .625}}},
+		{parabola, Bezier{Pt(0, 1), Pt(1, -1), Pt(2, 1)}, [][2]float64{{.5 - r, .5 - r}, {.5 + r, .5 + r}}},
+		{parabola, Bezier{Pt(0, 2), Pt(2, 2)}, nil},
+		{parabola, parabola, nil},
+	} {
+		got := c.b.Intersect(c.c)
+		if len(got) != len(c.want) {
+			t.Errorf("%v.Intersect(%v) = %v; want %v", c.b, c.c, got, c.want)
+			continue
+		}
+		for i := range got {
+			if !near(got[i][0], c.want[i][0]) || !near(got[i][1], c.want[i][1]) {
+				t.Errorf("%v.Intersect(%v) = %v; want %v", c.b, c.c, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestBezierDegenerate(t *testing.T) {
+	p := Pt(1, 2)
+	if got := (Bezier{p}).Flatten(.1); len(got) == 0 || got[0] != p || got[len(got)-1] != p {
+		t.Errorf("Bezier{p}.Flatten(.1) = %v", got)
+	}
+	if got := (Bezier{p}).At(.5); got != p {
+		t.Errorf("Bezier{p}.At(.5) = %v", got)
+	}
+	if got := (Bezier{}).At(.5); got != ZP {
+		t.Errorf("Bezier{}.At(.5) = %v", got)
+	}
+	if got := (Bezier{}).Flatten(.1); got != nil {
+		t.Errorf("Bezier{}.Flatten(.1) = %v", got)
+	}
+}
@@ -1,33 +1,24 @@
 package gui
 
-import . "github.com/chsc/gogl/gl21"
-
 type Color struct{ R, G, B, A float64 }
 
-func SetColor(c Color)       { Color4d(Double(c.R), Double(c.G), Double(c.B), Double(c.A)) }
-func SetPointSize(x float64) { PointSize(Float(x)) }
-func SetLineWidth(x float64) { LineWidth(Float(x)) }
-
-func DrawPoint(p Point) {
-	Begin(POINTS)
-	defer End()
-	Vertex2d(Double(p.X), Double(p.Y))
+// SetColor sets the color for drawing.  Within a View whose opacity could not
+// be applied through a layer, the color's alpha is multiplied by the opacity.
+func SetColor(c Color) {
+	r := renderer()
+	c.A *= painting.alpha
+	r.SetColor(c)
 }
 
-func DrawLine(p1, p2 Point) {
-	Begin(LINES)
-	defer End()
-	Vertex2d(Double(p1.X), Double(p1.Y))
-	Vertex2d(Double(p2.X), Double(p2.Y))
-}
+func SetPointSize(x float64) { renderer().SetPointSize(x) }
+func SetLineWidth(x float64) { renderer().SetLineWidth(x) }
 
-func DrawLineStrip(p ...Point) {
-	Begin(LINE_STRIP)
-	defer End()
-	for _, p := range p {
-		Vertex2d(Double(p.X), Double(p.Y))
-	}
-}
+func DrawPoint(p Point)           { renderer().DrawPoint(p) }
+func DrawLine(p1, p2 Point)       { renderer().DrawLine(p1, p2) }
+func DrawLineStrip(p ...Point)    { renderer().DrawLineStrip(p...) }
+func DrawPolygon(pts ...Point)    { renderer().DrawPolygon(pts...) }
+func FillPolygon(pts ...Point)    { renderer().FillPolygon(pts...) }
+func DrawBezier(ctrlPts ...Point) { renderer().DrawBezier(ctrlPts...) }
 
 func DrawRect(r Rectangle) {
 	p1, p2, p3, p4 := r.Min, Pt(r.Max.X, r.Min.Y), r.Max, Pt(r.Min.X, r.Max.Y)
@@ -37,42 +28,19 @@ func DrawRect(r Rectangle) {
 	DrawLine(p4, p1)
 }
 
-func FillRect(r Rectangle) {
-	Rectd(Double(r.Min.X), Double(r.Min.Y), Double(r.Max.X), Double(r.Max.Y))
-}
+func FillRect(r Rectangle) { renderer().FillRect(r) }
 
-func DrawPolygon(pts ...Point) {
-	Begin(LINE_LOOP)
-	defer End()
-	for _, p := range pts {
-		Vertex2d(Double(p.X), Double(p.Y))
-	}
-}
+// DrawText draws text in font f with its baseline starting at p.
+func DrawText(f *Font, text string, p Point) { renderer().DrawText(f, text, p) }
 
-func FillPolygon(pts ...Point) {
-	Begin(POLYGON)
-	defer End()
-	for _, p := range pts {
-		Vertex2d(Double(p.X), Double(p.Y))
-	}
-}
+// PushTransform saves the current transform, to be restored by PopTransform.
+func PushTransform() { renderer().PushTransform() }
+func PopTransform()  { renderer().PopTransform() }
 
-func DrawBezier(ctrlPts ...Point) {
-	pts := []Double{}
-	steps := 0.0
-	for i, p := range ctrlPts {
-		pts = append(pts, Double(p.X), Double(p.Y), 0)
-		if i > 0 {
-			steps += ctrlPts[i].Sub(ctrlPts[i-1]).Len()
-		}
-	}
-	Map1d(MAP1_VERTEX_3, 0, 1, 3, Int(len(ctrlPts)), &pts[0])
-	Enable(MAP1_VERTEX_3)
-	defer Disable(MAP1_VERTEX_3)
-	MapGrid1d(Int(steps), 0, 1)
-	EvalMesh1(LINE, 0, Int(steps))
-}
+// Translate and Scale transform subsequent drawing, like Rotate.
+func Translate(p Point)  { renderer().Translate(p) }
+func Scale(x, y float64) { renderer().Scale(x, y) }
 
-func Rotate(rot float64) {
-	Rotated(Double(rot*360), 0, 0, 1)
-}
+// Rotate rotates subsequent drawing by rot full turns.  It does not affect
+// hit-testing or clipping; rotate a View with SetTransform for that.
+func Rotate(rot float64) { renderer().Rotate(rot) }
@@ -0,0 +1,639 @@
+package gui
+
+import (
+	. "github.com/chsc/gogl/gl21"
+
+	"image"
+	"math"
+	"slices"
+	"unsafe"
+)
+
+// gl21Renderer draws with fixed-function OpenGL 2.1 into the context current
+// on the calling thread.
+type gl21Renderer struct {
+	size, bufSize Point
+	clip          image.Rectangle // in pixels, with Y increasing downward
+	bounds        image.Rectangle // the pixels being drawn, to which every clip is limited
+	color         Color
+	paint         Paint
+	cov           coverageRasterizer
+	maskTex       Uint
+	images        map[*Image]Uint // textures holding Images
+
+	atlas  *glyphAtlas
+	texs   []Uint      // the textures of the atlas pages
+	glyphs []glyphQuad // glyphs waiting to be drawn
+
+	// frame is a copy of the previous frame, which BeginPartial draws back
+	// because the window's buffer is undefined after it is swapped.
+	frame     gl21Copy
+	frameSize Point
+
+	fbSize  Point   // the size in pixels of the window's buffer
+	stencil []Point // the polygon of the clip in the stencil buffer, if any
+	layers  map[*Layer]*gl21Layer
+	saved   []gl21State // the state saved by BeginLayer
+	under   []gl21Copy  // for each open layer, the pixels it is rendered over
+}
+
+// A gl21Layer is a texture holding an image of a Layer.
+type gl21Layer struct {
+	gl21Copy
+	version uint64
+}
+
+type gl21State struct {
+	layer         *Layer
+	size, bufSize Point
+	clip, bounds  image.Rectangle
+	stencil       []Point
+	proj, mv      [16]Double
+	color         Color
+	paint         Paint
+}
+
+// A gl21Copy is a texture holding a copy of the pixels at the bottom left of
+// the buffer.
+type gl21Copy struct {
+	tex  Uint
+	w, h int
+}
+
+// copy copies the w by h pixels at the bottom left of the buffer to c.
+func (c *gl21Copy) copy(w, h int) {
+	if c.tex == 0 {
+		GenTextures(1, &c.tex)
+		BindTexture(TEXTURE_2D, c.tex)
+		TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, LINEAR)
+		TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, LINEAR)
+		TexParameteri(TEXTURE_2D, TEXTURE_WRAP_S, CLAMP_TO_EDGE)
+		TexParameteri(TEXTURE_2D, TEXTURE_WRAP_T, CLAMP_TO_EDGE)
+	}
+	BindTexture(TEXTURE_2D, c.tex)
+	if c.w != w || c.h != h {
+		TexImage2D(TEXTURE_2D, 0, RGBA, Sizei(w), Sizei(h), 0, RGBA, UNSIGNED_BYTE, nil)
+		c.w, c.h = w, h
+	}
+	CopyTexSubImage2D(TEXTURE_2D, 0, 0, 0, 0, 0, Sizei(w), Sizei(h))
+}
+
+// restore draws c back to the bottom left of a buffer of size bufSize,
+// replacing what is there, and leaves the scissor test and blending disabled
+// and the matrices reset.
+func (c *gl21Copy) restore(bufSize Point) {
+	Disable(SCISSOR_TEST)
+	Disable(STENCIL_TEST)
+	Disable(BLEND)
+	Viewport(0, 0, Sizei(bufSize.X), Sizei(bufSize.Y))
+	MatrixMode(PROJECTION)
+	LoadIdentity()
+	Ortho(0, Double(bufSize.X), 0, Double(bufSize.Y), -1, 1)
+	MatrixMode(MODELVIEW)
+	LoadIdentity()
+	Enable(TEXTURE_2D)
+	BindTexture(TEXTURE_2D, c.tex)
+	TexEnvi(TEXTURE_ENV, TEXTURE_ENV_MODE, REPLACE)
+	Begin(QUADS)
+	for _, p := range []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		TexCoord2d(Double(p.X), Double(p.Y))
+		Vertex2d(Double(p.X*c.w), Double(p.Y*c.h))
+	}
+	End()
+	Disable(TEXTURE_2D)
+}
+
+func (r *gl21Renderer) Begin(size, bufSize Point) {
+	r.begin(size, bufSize)
+	Clear(COLOR_BUFFER_BIT | DEPTH_BUFFER_BIT)
+}
+
+// BeginPartial draws the copy of the previous frame back into the window's
+// buffer.
+func (r *gl21Renderer) BeginPartial(size, bufSize Point, damage Rectangle) bool {
+	if r.frame.tex == 0 || r.frameSize != size || r.frame.w != int(bufSize.X) || r.frame.h != int(bufSize.Y) {
+		return false
+	}
+	r.frame.restore(bufSize)
+	r.begin(size, bufSize)
+	ax, ay := bufSize.X/size.X, bufSize.Y/size.Y
+	// damage's edges lie on pixel boundaries, up to rounding error.
+	x0, y0 := int(math.Floor(ax*damage.Min.X+1e-6)), int(math.Floor(ay*damage.Min.Y+1e-6))
+	x1, y1 := int(math.Ceil(ax*damage.Max.X-1e-6)), int(math.Ceil(ay*damage.Max.Y-1e-6))
+	r.bounds = image.Rect(x0, int(bufSize.Y)-y1, x1, int(bufSize.Y)-y0).Intersect(r.bounds)
+	r.clip = r.bounds
+	r.scissor()
+	Clear(COLOR_BUFFER_BIT | DEPTH_BUFFER_BIT)
+	return true
+}
+
+// begin resets the state for a frame.
+func (r *gl21Renderer) begin(size, bufSize Point) {
+	if r.atlas == nil {
+		r.atlas = newGlyphAtlas()
+		r.atlas.onEvict = func(int) { r.drawGlyphs() }
+	}
+	r.size, r.bufSize, r.fbSize = size, bufSize, bufSize
+	r.SetColor(Color{1, 1, 1, 1})
+	r.releaseImages()
+	r.releaseLayers()
+	r.saved = r.saved[:0]
+	r.stencil = nil
+
+	Enable(SCISSOR_TEST)
+	Enable(BLEND)
+	Enable(POINT_SMOOTH)
+	Enable(LINE_SMOOTH)
+	BlendFuncSeparate(SRC_ALPHA, ONE_MINUS_SRC_ALPHA, ONE, ONE_MINUS_SRC_ALPHA)
+
+	Viewport(0, 0, Sizei(bufSize.X), Sizei(bufSize.Y))
+	MatrixMode(PROJECTION)
+	LoadIdentity()
+	Ortho(0, Double(size.X), 0, Double(size.Y), -1, 1)
+	MatrixMode(MODELVIEW)
+	LoadIdentity()
+
+	Scissor(0, 0, Sizei(bufSize.X), Sizei(bufSize.Y))
+	r.clip = image.Rect(0, 0, int(bufSize.X), int(bufSize.Y))
+	r.bounds = r.clip
+	Disable(STENCIL_TEST)
+}
+
+// End copies the frame for the next BeginPartial.
+func (r *gl21Renderer) End() {
+	r.frame.copy(int(r.bufSize.X), int(r.bufSize.Y))
+	r.frameSize = r.size
+}
+
+func (r *gl21Renderer) SetColor(c Color) {
+	r.color, r.paint = c, nil
+	Color4d(Double(c.R), Double(c.G), Double(c.B), Double(c.A))
+}
+func (r *gl21Renderer) SetPaint(p Paint)       { r.paint = p }
+func (r *gl21Renderer) SetPointSize(x float64) { PointSize(Float(x)) }
+func (r *gl21Renderer) SetLineWidth(x float64) { LineWidth(Float(x)) }
+
+func (r *gl21Renderer) DrawPoint(p Point) {
+	Begin(POINTS)
+	defer End()
+	Vertex2d(Double(p.X), Double(p.Y))
+}
+
+func (r *gl21Renderer) DrawLine(p1, p2 Point) {
+	Begin(LINES)
+	defer End()
+	Vertex2d(Double(p1.X), Double(p1.Y))
+	Vertex2d(Double(p2.X), Double(p2.Y))
+}
+
+func (r *gl21Renderer) DrawLineStrip(p ...Point) {
+	Begin(LINE_STRIP)
+	defer End()
+	for _, p := range p {
+		Vertex2d(Double(p.X), Double(p.Y))
+	}
+}
+
+func (r *gl21Renderer) FillRect(rect Rectangle) {
+	if r.paint != nil {
+		r.FillPolygon(rect.Min, Pt(rect.Max.X, rect.Min.Y), rect.Max, Pt(rect.Min.X, rect.Max.Y))
+		return
+	}
+	Rectd(Double(rect.Min.X), Double(rect.Min.Y), Double(rect.Max.X), Double(rect.Max.Y))
+}
+
+func (r *gl21Renderer) DrawPolygon(pts ...Point) {
+	Begin(LINE_LOOP)
+	defer End()
+	for _, p := range pts {
+		Vertex2d(Double(p.X), Double(p.Y))
+	}
+}
+
+// FillPolygon draws convex polygons directly and others, and any filled with a
+// paint, through FillPath.
+func (r *gl21Renderer) FillPolygon(pts ...Point) {
+	if r.paint != nil || !convex(pts) {
+		r.FillPath(polygonPath(pts), NonZero)
+		return
+	}
+	Begin(POLYGON)
+	defer End()
+	for _, p := range pts {
+		Vertex2d(Double(p.X), Double(p.Y))
+	}
+}
+
+// FillPath rasterizes the coverage of p on the CPU and draws it as an alpha
+// texture tinted by the current color, or as an RGBA texture of the current
+// paint with the coverage in its alpha.
+func (r *gl21Renderer) FillPath(p *Path, rule FillRule) {
+	var mv [16]Double
+	GetDoublev(MODELVIEW_MATRIX, &mv[0])
+	ax, ay := r.bufSize.X/r.size.X, r.bufSize.Y/r.size.Y
+	dev := Affine2D{ax, 0, 0, -ay, 0, r.bufSize.Y}
+	r.cov.reset()
+	toDev := dev.Mul(Affine2D{float64(mv[0]), float64(mv[1]), float64(mv[4]), float64(mv[5]), float64(mv[12]), float64(mv[13])})
+	p.trace(toDev, &r.cov)
+	mask := r.cov.draw(rule, r.clip)
+	if mask == nil {
+		return
+	}
+
+	if r.maskTex == 0 {
+		GenTextures(1, &r.maskTex)
+		BindTexture(TEXTURE_2D, r.maskTex)
+		TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, NEAREST)
+		TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, NEAREST)
+	}
+	Enable(TEXTURE_2D)
+	defer Disable(TEXTURE_2D)
+	BindTexture(TEXTURE_2D, r.maskTex)
+	TexEnvi(TEXTURE_ENV, TEXTURE_ENV_MODE, MODULATE)
+	PixelStorei(UNPACK_ALIGNMENT, 1)
+	b := mask.Rect
+	if r.paint != nil {
+		img := paintMask(r.paint, toDev.Invert(), mask)
+		TexImage2D(TEXTURE_2D, 0, RGBA, Sizei(b.Dx()), Sizei(b.Dy()), 0, RGBA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&img.Pix[0])))
+		Color4d(1, 1, 1, 1)
+		defer Color4d(Double(r.color.R), Double(r.color.G), Double(r.color.B), Double(r.color.A))
+	} else {
+		TexImage2D(TEXTURE_2D, 0, ALPHA, Sizei(b.Dx()), Sizei(b.Dy()), 0, ALPHA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&mask.Pix[0])))
+	}
+
+	PushMatrix()
+	defer PopMatrix()
+	LoadIdentity()
+	Begin(QUADS)
+	defer End()
+	for _, c := range []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		TexCoord2d(Double(c.X), Double(c.Y))
+		x, y := float64(b.Min.X+c.X*b.Dx()), float64(b.Min.Y+c.Y*b.Dy())
+		Vertex2d(Double(x/ax), Double((r.bufSize.Y-y)/ay))
+	}
+}
+
+func (r *gl21Renderer) DrawImage(img *Image, dst, src Rectangle) {
+	if img.img.Rect.Empty() {
+		return
+	}
+	Enable(TEXTURE_2D)
+	defer Disable(TEXTURE_2D)
+	BindTexture(TEXTURE_2D, r.texture(img))
+	filter := Int(LINEAR)
+	if img.Filter == Nearest {
+		filter = NEAREST
+	}
+	TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, filter)
+	TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, filter)
+	TexEnvi(TEXTURE_ENV, TEXTURE_ENV_MODE, MODULATE)
+
+	size := img.Size()
+	Begin(QUADS)
+	defer End()
+	for _, c := range []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		TexCoord2d(Double((src.Min.X+c.X*src.Dx())/size.X), Double((src.Min.Y+c.Y*src.Dy())/size.Y))
+		Vertex2d(Double(dst.Min.X+c.X*dst.Dx()), Double(dst.Max.Y-c.Y*dst.Dy()))
+	}
+}
+
+// texture returns the texture holding img, uploading it if necessary.
+func (r *gl21Renderer) texture(img *Image) Uint {
+	if tex, ok := r.images[img]; ok {
+		return tex
+	}
+	var tex Uint
+	GenTextures(1, &tex)
+	BindTexture(TEXTURE_2D, tex)
+	TexParameteri(TEXTURE_2D, TEXTURE_WRAP_S, CLAMP_TO_EDGE)
+	TexParameteri(TEXTURE_2D, TEXTURE_WRAP_T, CLAMP_TO_EDGE)
+	PixelStorei(UNPACK_ALIGNMENT, 1)
+	m := img.img
+	TexImage2D(TEXTURE_2D, 0, RGBA, Sizei(m.Rect.Dx()), Sizei(m.Rect.Dy()), 0, RGBA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&m.Pix[0])))
+	if r.images == nil {
+		r.images = map[*Image]Uint{}
+	}
+	r.images[img] = tex
+	return tex
+}
+
+// releaseImages deletes the textures of released Images.
+func (r *gl21Renderer) releaseImages() {
+	for img, tex := range r.images {
+		if img.released.Load() {
+			DeleteTextures(1, &tex)
+			delete(r.images, img)
+		}
+	}
+}
+
+func (r *gl21Renderer) DrawBezier(ctrlPts ...Point) {
+	pts := []Double{}
+	steps := 0.0
+	for i, p := range ctrlPts {
+		pts = append(pts, Double(p.X), Double(p.Y), 0)
+		if i > 0 {
+			steps += ctrlPts[i].Sub(ctrlPts[i-1]).Len()
+		}
+	}
+	Map1d(MAP1_VERTEX_3, 0, 1, 3, Int(len(ctrlPts)), &pts[0])
+	Enable(MAP1_VERTEX_3)
+	defer Disable(MAP1_VERTEX_3)
+	MapGrid1d(Int(steps), 0, 1)
+	EvalMesh1(LINE, 0, Int(steps))
+}
+
+// DrawText draws glyphs from the atlas, rasterized at the current scale, tinted
+// by the current color.  Glyphs too big for the atlas are filled as Paths.
+func (r *gl21Renderer) DrawText(f *Font, text string, p Point) {
+	var mv [16]Double
+	GetDoublev(MODELVIEW_MATRIX, &mv[0])
+	ax, ay := r.bufSize.X/r.size.X, r.bufSize.Y/r.size.Y
+	m := Scaling(ax, ay).Mul(Affine2D{float64(mv[0]), float64(mv[1]), float64(mv[4]), float64(mv[5]), float64(mv[12]), float64(mv[13])}).Translate(p)
+	r.atlas.drawText(f, text, m, func(q glyphQuad) { r.glyphs = append(r.glyphs, q) }, func(path *Path) { r.fillGlyph(path, p) })
+	r.drawGlyphs()
+}
+
+// fillGlyph fills the outline of a glyph too big for the atlas, at the text
+// origin p, in the current color as DrawText would.
+func (r *gl21Renderer) fillGlyph(path *Path, p Point) {
+	paint := r.paint
+	r.paint = nil
+	PushMatrix()
+	Translated(Double(p.X), Double(p.Y), 0)
+	r.FillPath(path, NonZero)
+	PopMatrix()
+	r.paint = paint
+}
+
+// drawGlyphs draws the waiting glyphs, page by page, after copying the changed
+// parts of the atlas to its textures.
+func (r *gl21Renderer) drawGlyphs() {
+	if len(r.glyphs) == 0 {
+		return
+	}
+	PixelStorei(UNPACK_ALIGNMENT, 1)
+	for i, p := range r.atlas.pages {
+		if i == len(r.texs) {
+			var tex Uint
+			GenTextures(1, &tex)
+			BindTexture(TEXTURE_2D, tex)
+			TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, LINEAR)
+			TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, LINEAR)
+			TexParameteri(TEXTURE_2D, TEXTURE_WRAP_S, CLAMP_TO_EDGE)
+			TexParameteri(TEXTURE_2D, TEXTURE_WRAP_T, CLAMP_TO_EDGE)
+			TexImage2D(TEXTURE_2D, 0, ALPHA, atlasSize, atlasSize, 0, ALPHA, UNSIGNED_BYTE, nil)
+			r.texs = append(r.texs, tex)
+		}
+		if d := p.flush(); !d.Empty() {
+			BindTexture(TEXTURE_2D, r.texs[i])
+			PixelStorei(UNPACK_ROW_LENGTH, Int(p.img.Stride))
+			TexSubImage2D(TEXTURE_2D, 0, Int(d.Min.X), Int(d.Min.Y), Sizei(d.Dx()), Sizei(d.Dy()), ALPHA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&p.img.Pix[p.img.PixOffset(d.Min.X, d.Min.Y)])))
+			PixelStorei(UNPACK_ROW_LENGTH, 0)
+		}
+	}
+
+	ax, ay := r.bufSize.X/r.size.X, r.bufSize.Y/r.size.Y
+	Enable(TEXTURE_2D)
+	defer Disable(TEXTURE_2D)
+	TexEnvi(TEXTURE_ENV, TEXTURE_ENV_MODE, MODULATE)
+	PushMatrix()
+	defer PopMatrix()
+	LoadIdentity()
+	for i, tex := range r.texs {
+		BindTexture(TEXTURE_2D, tex)
+		Begin(QUADS)
+		for _, q := range r.glyphs {
+			if q.page != i {
+				continue
+			}
+			uv := [4]image.Point{q.src.Min, {q.src.Max.X, q.src.Min.Y}, q.src.Max, {q.src.Min.X, q.src.Max.Y}}
+			for j, p := range q.dst {
+				TexCoord2d(Double(float64(uv[j].X)/atlasSize), Double(float64(uv[j].Y)/atlasSize))
+				Vertex2d(Double(p.X/ax), Double(p.Y/ay))
+			}
+		}
+		End()
+	}
+	r.glyphs = r.glyphs[:0]
+}
+
+// BeginLayer renders the layer at the bottom left of the window's buffer,
+// after copying the pixels there so that EndLayer can put them back.  The
+// layer's alpha is accumulated in the buffer's alpha channel, which GLFW gives
+// windows by default.  Layers bigger than the window's buffer are not
+// supported.
+func (r *gl21Renderer) BeginLayer(l *Layer) bool {
+	if l.Size.X <= 0 || l.Size.Y <= 0 {
+		return false
+	}
+	var mv [16]Double
+	GetDoublev(MODELVIEW_MATRIX, &mv[0])
+	w, h := r.layerPixels(l, mv)
+	if w > int(r.fbSize.X) || h > int(r.fbSize.Y) {
+		return false
+	}
+	r.drawGlyphs()
+	if r.layers == nil {
+		r.layers = map[*Layer]*gl21Layer{}
+	}
+	if r.layers[l] == nil {
+		r.layers[l] = &gl21Layer{}
+	}
+	if len(r.saved) == len(r.under) {
+		r.under = append(r.under, gl21Copy{})
+	}
+	r.under[len(r.saved)].copy(w, h)
+	s := gl21State{layer: l, size: r.size, bufSize: r.bufSize, clip: r.clip, bounds: r.bounds, stencil: r.stencil, mv: mv, color: r.color, paint: r.paint}
+	GetDoublev(PROJECTION_MATRIX, &s.proj[0])
+	r.saved = append(r.saved, s)
+
+	r.size, r.bufSize = l.Size, Pt(float64(w), float64(h))
+	Viewport(0, 0, Sizei(w), Sizei(h))
+	MatrixMode(PROJECTION)
+	LoadIdentity()
+	Ortho(0, Double(r.size.X), 0, Double(r.size.Y), -1, 1)
+	MatrixMode(MODELVIEW)
+	LoadIdentity()
+	r.clip = image.Rect(0, 0, w, h)
+	r.bounds = r.clip
+	r.scissor()
+	r.stencil = nil
+	Disable(STENCIL_TEST)
+	ClearColor(0, 0, 0, 0)
+	Clear(COLOR_BUFFER_BIT)
+	r.SetColor(Color{1, 1, 1, 1})
+	return true
+}
+
+// EndLayer copies the layer to its texture and puts back the pixels it was
+// rendered over, and the clip polygon in the stencil buffer, if any.
+func (r *gl21Renderer) EndLayer() {
+	r.drawGlyphs()
+	s := r.saved[len(r.saved)-1]
+	r.saved = r.saved[:len(r.saved)-1]
+	e := r.layers[s.layer]
+	e.copy(int(r.bufSize.X), int(r.bufSize.Y))
+	e.version = s.layer.Version
+	r.under[len(r.saved)].restore(r.fbSize)
+
+	r.size, r.bufSize, r.clip, r.bounds, r.stencil = s.size, s.bufSize, s.clip, s.bounds, s.stencil
+	Enable(BLEND)
+	Enable(SCISSOR_TEST)
+	Viewport(0, 0, Sizei(r.bufSize.X), Sizei(r.bufSize.Y))
+	MatrixMode(PROJECTION)
+	LoadMatrixd(&s.proj[0])
+	MatrixMode(MODELVIEW)
+	LoadMatrixd(&s.mv[0])
+	r.scissor()
+	if r.stencil != nil {
+		r.drawStencil()
+	}
+	r.SetColor(s.color)
+	r.paint = s.paint
+}
+
+// DrawLayer draws the layer's texture, whose color is premultiplied by its
+// alpha.  BlendMultiply is exact only over an opaque backdrop.
+func (r *gl21Renderer) DrawLayer(l *Layer, opacity float64, blend BlendMode) bool {
+	e := r.layers[l]
+	if e == nil || e.tex == 0 || e.version != l.Version {
+		return false
+	}
+	var mv [16]Double
+	GetDoublev(MODELVIEW_MATRIX, &mv[0])
+	if w, h := r.layerPixels(l, mv); w != e.w || h != e.h {
+		return false
+	}
+	r.drawGlyphs()
+	switch blend {
+	case BlendMultiply:
+		BlendFuncSeparate(DST_COLOR, ONE_MINUS_SRC_ALPHA, ONE, ONE_MINUS_SRC_ALPHA)
+	case BlendScreen:
+		BlendFuncSeparate(ONE, ONE_MINUS_SRC_COLOR, ONE, ONE_MINUS_SRC_ALPHA)
+	case BlendAdd:
+		BlendFunc(ONE, ONE)
+	default:
+		BlendFunc(ONE, ONE_MINUS_SRC_ALPHA)
+	}
+	// Scale all of the premultiplied channels by opacity.
+	k := Double(opacity)
+	Color4d(k, k, k, k)
+	Enable(TEXTURE_2D)
+	BindTexture(TEXTURE_2D, e.tex)
+	TexEnvi(TEXTURE_ENV, TEXTURE_ENV_MODE, MODULATE)
+	// The texture's first row is the bottom of the layer.
+	Begin(QUADS)
+	for _, c := range []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		TexCoord2d(Double(c.X), Double(c.Y))
+		Vertex2d(Double(float64(c.X)*l.Size.X), Double(float64(c.Y)*l.Size.Y))
+	}
+	End()
+	Disable(TEXTURE_2D)
+	BlendFuncSeparate(SRC_ALPHA, ONE_MINUS_SRC_ALPHA, ONE, ONE_MINUS_SRC_ALPHA)
+	Color4d(Double(r.color.R), Double(r.color.G), Double(r.color.B), Double(r.color.A))
+	return true
+}
+
+// layerPixels returns the size in pixels of an image of l drawn with the
+// modelview matrix mv.
+func (r *gl21Renderer) layerPixels(l *Layer, mv [16]Double) (int, int) {
+	ax, ay := r.bufSize.X/r.size.X, r.bufSize.Y/r.size.Y
+	return layerPixels(l, Affine2D{ax * float64(mv[0]), ay * float64(mv[1]), ax * float64(mv[4]), ay * float64(mv[5]), 0, 0})
+}
+
+// releaseLayers deletes the textures of released Layers.
+func (r *gl21Renderer) releaseLayers() {
+	for l, e := range r.layers {
+		if l.released.Load() {
+			DeleteTextures(1, &e.tex)
+			delete(r.layers, l)
+		}
+	}
+}
+
+func (r *gl21Renderer) PushTransform()     { PushMatrix() }
+func (r *gl21Renderer) PopTransform()      { PopMatrix() }
+func (r *gl21Renderer) Translate(p Point)  { Translated(Double(p.X), Double(p.Y), 0) }
+func (r *gl21Renderer) Scale(x, y float64) { Scaled(Double(x), Double(y), 1) }
+func (r *gl21Renderer) Rotate(rot float64) { Rotated(Double(rot*360), 0, 0, 1) }
+func (r *gl21Renderer) Transform(m Affine2D) {
+	mat := [16]Double{Double(m.A), Double(m.B), 0, 0, Double(m.C), Double(m.D), 0, 0, 0, 0, 1, 0, Double(m.E), Double(m.F), 0, 1}
+	MultMatrixd(&mat[0])
+}
+
+func (r *gl21Renderer) Clip(rect Rectangle) {
+	ax := r.bufSize.X / r.size.X
+	ay := r.bufSize.Y / r.size.Y
+	x, y := int(ax*rect.Min.X), int(ay*rect.Min.Y)
+	w, h := int(ax*(rect.Dx()+1)), int(ay*(rect.Dy()+1))
+	r.clip = image.Rect(x, int(r.bufSize.Y)-y-h, x+w, int(r.bufSize.Y)-y).Intersect(r.bounds)
+	r.scissor()
+	r.stencil = nil
+	Disable(STENCIL_TEST)
+}
+
+// scissor sets the scissor rectangle to the clip.
+func (r *gl21Renderer) scissor() {
+	Scissor(Int(r.clip.Min.X), Int(int(r.bufSize.Y)-r.clip.Max.Y), Sizei(r.clip.Dx()), Sizei(r.clip.Dy()))
+}
+
+// ClipPolygon draws the polygon into the stencil buffer, scissored to its
+// bounds, and then only draws where it was drawn.
+func (r *gl21Renderer) ClipPolygon(pts ...Point) {
+	if len(pts) < 3 {
+		Scissor(0, 0, 0, 0)
+		r.clip = image.Rectangle{}
+		return
+	}
+	b := Rectangle{pts[0], pts[0]}
+	for _, p := range pts[1:] {
+		b = b.Union(Rectangle{p, p})
+	}
+	r.Clip(b)
+	r.stencil = slices.Clone(pts)
+	r.drawStencil()
+}
+
+// drawStencil draws the clip polygon into the stencil buffer, within the
+// scissor rectangle, and then only draws where it was drawn.
+func (r *gl21Renderer) drawStencil() {
+	Enable(STENCIL_TEST)
+	StencilMask(0xff)
+	Clear(STENCIL_BUFFER_BIT)
+	ColorMask(FALSE, FALSE, FALSE, FALSE)
+	StencilFunc(ALWAYS, 1, 0xff)
+	StencilOp(KEEP, KEEP, REPLACE)
+	PushMatrix()
+	LoadIdentity()
+	Begin(POLYGON)
+	for _, p := range r.stencil {
+		Vertex2d(Double(p.X), Double(p.Y))
+	}
+	End()
+	PopMatrix()
+	ColorMask(TRUE, TRUE, TRUE, TRUE)
+	StencilFunc(EQUAL, 1, 0xff)
+	StencilOp(KEEP, KEEP, KEEP)
+}
+
+// capture reads back the back buffer, which still holds the last frame if it
+// has not been swapped.
+func (r *gl21Renderer) capture() *image.RGBA {
+	w, h := int(r.bufSize.X), int(r.bufSize.Y)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 {
+		return img
+	}
+	PixelStorei(PACK_ALIGNMENT, 1)
+	ReadPixels(0, 0, Sizei(w), Sizei(h), RGBA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&img.Pix[0])))
+	// OpenGL's rows are bottom to top.
+	row := make([]byte, img.Stride)
+	for y := 0; y < h/2; y++ {
+		a, b := img.Pix[y*img.Stride:(y+1)*img.Stride], img.Pix[(h-1-y)*img.Stride:(h-y)*img.Stride]
+		copy(row, a)
+		copy(a, b)
+		copy(b, row)
+	}
+	return img
+}
@@ -0,0 +1,820 @@
+package gui
+
+import (
+	. "github.com/chsc/gogl/gl33"
+
+	"fmt"
+	"image"
+	"math"
+	"unsafe"
+)
+
+// gl33Renderer draws with an OpenGL 3.3 core-profile context current on the
+// calling thread.  Geometry is transformed on the CPU and collected into a
+// single batch of textured triangles, which is drawn with one call when the
+// clip changes or the frame ends.  Text is drawn from a glyph atlas rasterized
+// from Fonts.  Frames are drawn multisampled in an offscreen framebuffer and
+// resolved into the window's, which therefore needs no samples of its own.
+type gl33Renderer struct {
+	size, bufSize Point
+	clip          image.Rectangle // in pixels, with Y increasing downward
+	bounds        image.Rectangle // the pixels being drawn, to which every clip is limited
+	m             Affine2D
+	stack         []Affine2D
+
+	color     [4]float32
+	paint     Paint
+	pointSize float64
+	lineWidth float64
+
+	verts  []float32 // x, y, u, v, r, g, b, a per vertex
+	atlas  *glyphAtlas
+	cov    coverageRasterizer
+	images map[*Image]Uint // textures holding Images
+
+	stencil bool // whether the clip is in the stencil buffer
+	fbo     Uint // the framebuffer drawn to
+	layers  map[*Layer]*gl33Layer
+	saved   []gl33State // the state saved by BeginLayer
+
+	// target is the multisampled framebuffer that frames are drawn in.  It
+	// is kept between frames, unlike the window's buffer, which is undefined
+	// after it is swapped, so that BeginPartial can draw over the previous
+	// frame.  End resolves it into the window's buffer.
+	target    *gl33Target
+	frameSize Point // the size of the frame in target, or zero if there is none
+
+	program Uint
+	sizeLoc Int
+	modeLoc Int
+	vao     Uint
+	vbo     Uint
+	texs    []Uint // the textures of the atlas pages
+	page    int    // the atlas page that batched geometry samples
+	maskTex Uint
+}
+
+// A gl33Layer is a texture holding an image of a Layer, with a framebuffer to
+// render to it.
+type gl33Layer struct {
+	tex, fbo, depth Uint
+	w, h            int
+	version         uint64
+}
+
+// A gl33Target is a multisampled framebuffer.
+type gl33Target struct {
+	fbo, color, depth Uint
+	w, h              int
+}
+
+// gl33Samples is the number of samples per pixel that frames are drawn with.
+const gl33Samples = 4
+
+type gl33State struct {
+	layer         *Layer
+	size, bufSize Point
+	clip, bounds  image.Rectangle
+	stencil       bool
+	fbo           Uint
+	m             Affine2D
+	stack         []Affine2D
+	color         [4]float32
+	paint         Paint
+	pointSize     float64
+	lineWidth     float64
+}
+
+const gl33VertexShader = `#version 330 core
+uniform vec2 size;
+layout(location = 0) in vec2 pos;
+layout(location = 1) in vec2 uv;
+layout(location = 2) in vec4 color;
+out vec2 fragUV;
+out vec4 fragColor;
+void main() {
+	gl_Position = vec4(2*pos/size - 1, 0, 1);
+	fragUV = uv;
+	fragColor = color;
+}
+`
+
+const gl33FragmentShader = `#version 330 core
+uniform sampler2D atlas;
+// mode is 0 to tint by the texture's red channel as alpha and 1 to multiply by
+// the texture.
+uniform int mode;
+in vec2 fragUV;
+in vec4 fragColor;
+out vec4 outColor;
+void main() {
+	vec4 t = texture(atlas, fragUV);
+	if (mode == 0) {
+		t = vec4(1, 1, 1, t.r);
+	}
+	outColor = fragColor*t;
+}
+`
+
+func (r *gl33Renderer) init() {
+	vs := compileShader(VERTEX_SHADER, gl33VertexShader)
+	fs := compileShader(FRAGMENT_SHADER, gl33FragmentShader)
+	r.program = CreateProgram()
+	AttachShader(r.program, vs)
+	AttachShader(r.program, fs)
+	LinkProgram(r.program)
+	DeleteShader(vs)
+	DeleteShader(fs)
+	var ok Int
+	GetProgramiv(r.program, LINK_STATUS, &ok)
+	if ok == FALSE {
+		panic("gui: linking shader program: " + programInfoLog(r.program))
+	}
+	UseProgram(r.program)
+	r.sizeLoc = uniformLocation(r.program, "size")
+	r.modeLoc = uniformLocation(r.program, "mode")
+	Uniform1i(uniformLocation(r.program, "atlas"), 0)
+
+	GenVertexArrays(1, &r.vao)
+	BindVertexArray(r.vao)
+	GenBuffers(1, &r.vbo)
+	BindBuffer(ARRAY_BUFFER, r.vbo)
+	const stride = 8 * 4
+	EnableVertexAttribArray(0)
+	VertexAttribPointer(0, 2, FLOAT, FALSE, stride, Offset(nil, 0))
+	EnableVertexAttribArray(1)
+	VertexAttribPointer(1, 2, FLOAT, FALSE, stride, Offset(nil, 2*4))
+	EnableVertexAttribArray(2)
+	VertexAttribPointer(2, 4, FLOAT, FALSE, stride, Offset(nil, 4*4))
+
+	r.atlas = newGlyphAtlas()
+	r.atlas.onEvict = func(page int) {
+		if page == r.page {
+			r.upload()
+			r.flush()
+		}
+	}
+	ActiveTexture(TEXTURE0)
+	r.upload()
+
+	GenTextures(1, &r.maskTex)
+	BindTexture(TEXTURE_2D, r.maskTex)
+	TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, NEAREST)
+	TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, NEAREST)
+}
+
+func compileShader(typ Enum, src string) Uint {
+	s := CreateShader(typ)
+	csrc := GLString(src)
+	defer GLStringFree(csrc)
+	ShaderSource(s, 1, &csrc, nil)
+	CompileShader(s)
+	var ok Int
+	GetShaderiv(s, COMPILE_STATUS, &ok)
+	if ok == FALSE {
+		var n Int
+		GetShaderiv(s, INFO_LOG_LENGTH, &n)
+		log := make([]byte, n+1)
+		GetShaderInfoLog(s, Sizei(n), nil, (*Char)(unsafe.Pointer(&log[0])))
+		panic(fmt.Sprintf("gui: compiling shader: %s", GoString((*Char)(unsafe.Pointer(&log[0])))))
+	}
+	return s
+}
+
+func programInfoLog(p Uint) string {
+	var n Int
+	GetProgramiv(p, INFO_LOG_LENGTH, &n)
+	log := make([]byte, n+1)
+	GetProgramInfoLog(p, Sizei(n), nil, (*Char)(unsafe.Pointer(&log[0])))
+	return GoString((*Char)(unsafe.Pointer(&log[0])))
+}
+
+func uniformLocation(p Uint, name string) Int {
+	cname := GLString(name)
+	defer GLStringFree(cname)
+	return GetUniformLocation(p, cname)
+}
+
+func (r *gl33Renderer) Begin(size, bufSize Point) {
+	r.begin(size, bufSize)
+	Clear(COLOR_BUFFER_BIT | DEPTH_BUFFER_BIT)
+}
+
+// BeginPartial draws over the previous frame, which is still in the target.
+func (r *gl33Renderer) BeginPartial(size, bufSize Point, damage Rectangle) bool {
+	if r.target == nil || r.frameSize != size || r.target.w != int(bufSize.X) || r.target.h != int(bufSize.Y) {
+		return false
+	}
+	r.begin(size, bufSize)
+	ax, ay := bufSize.X/size.X, bufSize.Y/size.Y
+	// damage's edges lie on pixel boundaries, up to rounding error.
+	x0, y0 := int(math.Floor(ax*damage.Min.X+1e-6)), int(math.Floor(ay*damage.Min.Y+1e-6))
+	x1, y1 := int(math.Ceil(ax*damage.Max.X-1e-6)), int(math.Ceil(ay*damage.Max.Y-1e-6))
+	r.bounds = image.Rect(x0, int(bufSize.Y)-y1, x1, int(bufSize.Y)-y0).Intersect(r.bounds)
+	r.clip = r.bounds
+	r.scissor()
+	Clear(COLOR_BUFFER_BIT | DEPTH_BUFFER_BIT)
+	return true
+}
+
+// begin resets the state for a frame.
+func (r *gl33Renderer) begin(size, bufSize Point) {
+	if r.atlas == nil {
+		r.init()
+	}
+	r.size, r.bufSize = size, bufSize
+	r.releaseImages()
+	r.releaseLayers()
+	if w, h := int(bufSize.X), int(bufSize.Y); r.target == nil || r.target.w != w || r.target.h != h {
+		if r.target != nil {
+			r.target.delete()
+		}
+		r.target = newGL33Target(w, h)
+		r.frameSize = ZP
+	}
+	r.fbo = r.target.fbo
+	r.saved = r.saved[:0]
+	r.m = Identity2D
+	r.stack = r.stack[:0]
+	r.SetColor(Color{1, 1, 1, 1})
+	r.pointSize = 1
+	r.lineWidth = 1
+
+	UseProgram(r.program)
+	BindVertexArray(r.vao)
+	BindBuffer(ARRAY_BUFFER, r.vbo)
+	ActiveTexture(TEXTURE0)
+	BindTexture(TEXTURE_2D, r.texs[r.page])
+	Uniform2f(r.sizeLoc, Float(size.X), Float(size.Y))
+
+	Enable(SCISSOR_TEST)
+	Enable(BLEND)
+	Enable(MULTISAMPLE)
+	// Accumulate alpha so that layers hold premultiplied color.
+	BlendFuncSeparate(SRC_ALPHA, ONE_MINUS_SRC_ALPHA, ONE, ONE_MINUS_SRC_ALPHA)
+	BindFramebuffer(FRAMEBUFFER, r.fbo)
+	Viewport(0, 0, Sizei(bufSize.X), Sizei(bufSize.Y))
+	Scissor(0, 0, Sizei(bufSize.X), Sizei(bufSize.Y))
+	r.clip = image.Rect(0, 0, int(bufSize.X), int(bufSize.Y))
+	r.bounds = r.clip
+	r.stencil = false
+	Disable(STENCIL_TEST)
+}
+
+// End resolves the frame into the window's buffer.
+func (r *gl33Renderer) End() {
+	r.flush()
+	r.frameSize = r.size
+	w, h := Int(r.target.w), Int(r.target.h)
+	Disable(SCISSOR_TEST)
+	BindFramebuffer(READ_FRAMEBUFFER, r.target.fbo)
+	BindFramebuffer(DRAW_FRAMEBUFFER, 0)
+	BlitFramebuffer(0, 0, w, h, 0, 0, w, h, COLOR_BUFFER_BIT, NEAREST)
+	BindFramebuffer(FRAMEBUFFER, 0)
+	Enable(SCISSOR_TEST)
+}
+
+// newGL33Target returns a w by h multisampled framebuffer with a stencil
+// buffer.
+func newGL33Target(w, h int) *gl33Target {
+	t := &gl33Target{w: w, h: h}
+	// A framebuffer without pixels is incomplete.
+	sw, sh := Sizei(max(1, w)), Sizei(max(1, h))
+	GenRenderbuffers(1, &t.color)
+	BindRenderbuffer(RENDERBUFFER, t.color)
+	RenderbufferStorageMultisample(RENDERBUFFER, gl33Samples, RGBA8, sw, sh)
+	GenRenderbuffers(1, &t.depth)
+	BindRenderbuffer(RENDERBUFFER, t.depth)
+	RenderbufferStorageMultisample(RENDERBUFFER, gl33Samples, DEPTH24_STENCIL8, sw, sh)
+	GenFramebuffers(1, &t.fbo)
+	BindFramebuffer(FRAMEBUFFER, t.fbo)
+	FramebufferRenderbuffer(FRAMEBUFFER, COLOR_ATTACHMENT0, RENDERBUFFER, t.color)
+	FramebufferRenderbuffer(FRAMEBUFFER, DEPTH_STENCIL_ATTACHMENT, RENDERBUFFER, t.depth)
+	return t
+}
+
+func (t *gl33Target) delete() {
+	DeleteFramebuffers(1, &t.fbo)
+	DeleteRenderbuffers(1, &t.depth)
+	DeleteRenderbuffers(1, &t.color)
+}
+
+// upload copies the changed parts of the atlas pages to their textures,
+// creating any that are new, and leaves the current page's texture bound.
+func (r *gl33Renderer) upload() {
+	for i, p := range r.atlas.pages {
+		if i == len(r.texs) {
+			var tex Uint
+			GenTextures(1, &tex)
+			BindTexture(TEXTURE_2D, tex)
+			TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, LINEAR)
+			TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, LINEAR)
+			TexParameteri(TEXTURE_2D, TEXTURE_WRAP_S, CLAMP_TO_EDGE)
+			TexParameteri(TEXTURE_2D, TEXTURE_WRAP_T, CLAMP_TO_EDGE)
+			TexImage2D(TEXTURE_2D, 0, R8, atlasSize, atlasSize, 0, RED, UNSIGNED_BYTE, nil)
+			r.texs = append(r.texs, tex)
+		}
+		d := p.flush()
+		if d.Empty() {
+			continue
+		}
+		BindTexture(TEXTURE_2D, r.texs[i])
+		PixelStorei(UNPACK_ALIGNMENT, 1)
+		PixelStorei(UNPACK_ROW_LENGTH, Int(p.img.Stride))
+		TexSubImage2D(TEXTURE_2D, 0, Int(d.Min.X), Int(d.Min.Y), Sizei(d.Dx()), Sizei(d.Dy()), RED, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&p.img.Pix[p.img.PixOffset(d.Min.X, d.Min.Y)])))
+		PixelStorei(UNPACK_ROW_LENGTH, 0)
+	}
+	BindTexture(TEXTURE_2D, r.texs[r.page])
+}
+
+// flush draws the batched geometry.
+func (r *gl33Renderer) flush() {
+	if len(r.verts) == 0 {
+		return
+	}
+	BufferData(ARRAY_BUFFER, Sizeiptr(len(r.verts)*4), Pointer(unsafe.Pointer(&r.verts[0])), STREAM_DRAW)
+	DrawArrays(TRIANGLES, 0, Sizei(len(r.verts)/8))
+	r.verts = r.verts[:0]
+}
+
+// vertex adds a vertex in window coordinates with texture coordinates uv.
+func (r *gl33Renderer) vertex(p, uv Point) {
+	c := r.color
+	r.verts = append(r.verts, float32(p.X), float32(p.Y), float32(uv.X), float32(uv.Y), c[0], c[1], c[2], c[3])
+}
+
+// triangle adds a solid triangle in window coordinates.
+func (r *gl33Renderer) triangle(a, b, c Point) {
+	w := r.atlas.white()
+	r.vertex(a, w)
+	r.vertex(b, w)
+	r.vertex(c, w)
+}
+
+// fan adds a solid triangle fan, which fills pts if they form a convex
+// polygon, as GL_POLYGON does.
+func (r *gl33Renderer) fan(pts []Point) {
+	for i := 2; i < len(pts); i++ {
+		r.triangle(pts[0], pts[i-1], pts[i])
+	}
+}
+
+func (r *gl33Renderer) SetColor(c Color) {
+	r.color = [4]float32{float32(c.R), float32(c.G), float32(c.B), float32(c.A)}
+	r.paint = nil
+}
+func (r *gl33Renderer) SetPaint(p Paint)       { r.paint = p }
+func (r *gl33Renderer) SetPointSize(x float64) { r.pointSize = x }
+func (r *gl33Renderer) SetLineWidth(x float64) { r.lineWidth = x }
+
+// pixel returns the size of a pixel in window coordinates.
+func (r *gl33Renderer) pixel() Point {
+	return Pt(r.size.X/r.bufSize.X, r.size.Y/r.bufSize.Y)
+}
+
+func (r *gl33Renderer) DrawPoint(p Point) {
+	c := r.m.Apply(p)
+	px := r.pixel()
+	rad := math.Max(1, r.pointSize) / 2
+	const n = 16
+	pts := make([]Point, n)
+	for i := range pts {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / n)
+		pts[i] = c.Add(Pt(cos*px.X, sin*px.Y).Mul(rad))
+	}
+	r.fan(pts)
+}
+
+func (r *gl33Renderer) DrawLine(p1, p2 Point) { r.DrawLineStrip(p1, p2) }
+
+func (r *gl33Renderer) DrawLineStrip(pts ...Point) {
+	win := make([]Point, len(pts))
+	for i, p := range pts {
+		win[i] = r.m.Apply(p)
+	}
+	r.stroke(win)
+}
+
+func (r *gl33Renderer) DrawPolygon(pts ...Point) {
+	if len(pts) > 0 {
+		r.DrawLineStrip(append(pts[:len(pts):len(pts)], pts[0])...)
+	}
+}
+
+func (r *gl33Renderer) FillRect(rect Rectangle) {
+	r.FillPolygon(rect.Min, Pt(rect.Max.X, rect.Min.Y), rect.Max, Pt(rect.Min.X, rect.Max.Y))
+}
+
+// FillPolygon batches convex polygons and draws others, and any filled with a
+// paint, through FillPath.
+func (r *gl33Renderer) FillPolygon(pts ...Point) {
+	if r.paint != nil || !convex(pts) {
+		r.FillPath(polygonPath(pts), NonZero)
+		return
+	}
+	win := make([]Point, len(pts))
+	for i, p := range pts {
+		win[i] = r.m.Apply(p)
+	}
+	r.fan(win)
+}
+
+// FillPath rasterizes the coverage of p on the CPU and draws it from a mask
+// texture, which breaks the batch.  A paint is rasterized on the CPU too, into
+// an RGBA texture with the coverage in its alpha.
+func (r *gl33Renderer) FillPath(p *Path, rule FillRule) {
+	px := r.pixel()
+	dev := Affine2D{1 / px.X, 0, 0, -1 / px.Y, 0, r.bufSize.Y}
+	r.cov.reset()
+	p.trace(dev.Mul(r.m), &r.cov)
+	mask := r.cov.draw(rule, r.clip)
+	if mask == nil {
+		return
+	}
+
+	r.flush()
+	b := mask.Rect
+	BindTexture(TEXTURE_2D, r.maskTex)
+	PixelStorei(UNPACK_ALIGNMENT, 1)
+	if r.paint != nil {
+		img := paintMask(r.paint, dev.Mul(r.m).Invert(), mask)
+		TexImage2D(TEXTURE_2D, 0, RGBA8, Sizei(b.Dx()), Sizei(b.Dy()), 0, RGBA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&img.Pix[0])))
+		Uniform1i(r.modeLoc, 1)
+		defer Uniform1i(r.modeLoc, 0)
+		color := r.color
+		r.color = [4]float32{1, 1, 1, 1}
+		defer func() { r.color = color }()
+	} else {
+		TexImage2D(TEXTURE_2D, 0, R8, Sizei(b.Dx()), Sizei(b.Dy()), 0, RED, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&mask.Pix[0])))
+	}
+	corner := func(c image.Point) {
+		x, y := float64(b.Min.X+c.X*b.Dx()), float64(b.Min.Y+c.Y*b.Dy())
+		r.vertex(Pt(x*px.X, (r.bufSize.Y-y)*px.Y), Pt(float64(c.X), float64(c.Y)))
+	}
+	for _, c := range []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 0}, {1, 1}, {0, 1}} {
+		corner(c)
+	}
+	r.flush()
+	BindTexture(TEXTURE_2D, r.texs[r.page])
+}
+
+func (r *gl33Renderer) DrawBezier(ctrlPts ...Point) {
+	if len(ctrlPts) == 0 {
+		return
+	}
+	win := make([]Point, len(ctrlPts))
+	steps := 0.0
+	for i, p := range ctrlPts {
+		win[i] = r.m.Apply(p)
+		if i > 0 {
+			steps += win[i].Sub(win[i-1]).Len()
+		}
+	}
+	n := int(math.Ceil(steps/4)) + 1
+	pts := make([]Point, n+1)
+	tmp := make([]Point, len(win))
+	for i := range pts {
+		pts[i] = deCasteljau(win, tmp, float64(i)/float64(n))
+	}
+	r.stroke(pts)
+}
+
+// stroke draws a line strip through the window-coordinate points pts, with
+// the line width in pixels.
+func (r *gl33Renderer) stroke(pts []Point) {
+	px := r.pixel()
+	w := math.Max(1, r.lineWidth) / 2
+	for i := 1; i < len(pts); i++ {
+		p1, p2 := pts[i-1], pts[i]
+		// Offset perpendicular to the line in pixels, then back to window
+		// coordinates.
+		d := Pt((p2.X-p1.X)/px.X, (p2.Y-p1.Y)/px.Y)
+		l := d.Len()
+		if l == 0 {
+			continue
+		}
+		n := Pt(-d.Y*px.X, d.X*px.Y).Mul(w / l)
+		r.triangle(p1.Add(n), p2.Add(n), p2.Sub(n))
+		r.triangle(p1.Add(n), p2.Sub(n), p1.Sub(n))
+	}
+}
+
+// DrawText draws glyphs from the atlas, rasterized at the current scale.
+// Glyphs on a different page of the atlas than the batch break it, as do
+// glyphs too big for the atlas, which are filled as Paths.
+func (r *gl33Renderer) DrawText(f *Font, text string, p Point) {
+	px := r.pixel()
+	m := Scaling(1/px.X, 1/px.Y).Mul(r.m.Translate(p))
+	r.atlas.drawText(f, text, m, func(q glyphQuad) {
+		if q.page != r.page {
+			r.upload()
+			r.flush()
+			r.page = q.page
+			BindTexture(TEXTURE_2D, r.texs[r.page])
+		}
+		var c [4]Point
+		for i, d := range q.dst {
+			c[i] = Pt(d.X*px.X, d.Y*px.Y)
+		}
+		u0, v0 := float64(q.src.Min.X)/atlasSize, float64(q.src.Min.Y)/atlasSize
+		u1, v1 := float64(q.src.Max.X)/atlasSize, float64(q.src.Max.Y)/atlasSize
+		r.vertex(c[0], Pt(u0, v0))
+		r.vertex(c[1], Pt(u1, v0))
+		r.vertex(c[2], Pt(u1, v1))
+		r.vertex(c[0], Pt(u0, v0))
+		r.vertex(c[2], Pt(u1, v1))
+		r.vertex(c[3], Pt(u0, v1))
+	}, func(path *Path) { r.fillGlyph(path, p) })
+	r.upload()
+}
+
+// fillGlyph fills the outline of a glyph too big for the atlas, at the text
+// origin p, in the current color as DrawText would.
+func (r *gl33Renderer) fillGlyph(path *Path, p Point) {
+	paint, m := r.paint, r.m
+	r.paint, r.m = nil, r.m.Translate(p)
+	r.FillPath(path, NonZero)
+	r.paint, r.m = paint, m
+}
+
+// DrawImage draws img from its own texture, which breaks the batch.
+func (r *gl33Renderer) DrawImage(img *Image, dst, src Rectangle) {
+	if img.img.Rect.Empty() {
+		return
+	}
+	r.flush()
+	BindTexture(TEXTURE_2D, r.texture(img))
+	filter := Int(LINEAR)
+	if img.Filter == Nearest {
+		filter = NEAREST
+	}
+	TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, filter)
+	TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, filter)
+	Uniform1i(r.modeLoc, 1)
+
+	size := img.Size()
+	u0, v0 := src.Min.X/size.X, src.Min.Y/size.Y
+	u1, v1 := src.Max.X/size.X, src.Max.Y/size.Y
+	a, b := r.m.Apply(Pt(dst.Min.X, dst.Max.Y)), r.m.Apply(dst.Max)
+	c, d := r.m.Apply(Pt(dst.Max.X, dst.Min.Y)), r.m.Apply(dst.Min)
+	r.vertex(a, Pt(u0, v0))
+	r.vertex(b, Pt(u1, v0))
+	r.vertex(c, Pt(u1, v1))
+	r.vertex(a, Pt(u0, v0))
+	r.vertex(c, Pt(u1, v1))
+	r.vertex(d, Pt(u0, v1))
+	r.flush()
+
+	Uniform1i(r.modeLoc, 0)
+	BindTexture(TEXTURE_2D, r.texs[r.page])
+}
+
+// texture returns the texture holding img, uploading it if necessary.
+func (r *gl33Renderer) texture(img *Image) Uint {
+	if tex, ok := r.images[img]; ok {
+		return tex
+	}
+	var tex Uint
+	GenTextures(1, &tex)
+	BindTexture(TEXTURE_2D, tex)
+	TexParameteri(TEXTURE_2D, TEXTURE_WRAP_S, CLAMP_TO_EDGE)
+	TexParameteri(TEXTURE_2D, TEXTURE_WRAP_T, CLAMP_TO_EDGE)
+	PixelStorei(UNPACK_ALIGNMENT, 1)
+	m := img.img
+	TexImage2D(TEXTURE_2D, 0, RGBA8, Sizei(m.Rect.Dx()), Sizei(m.Rect.Dy()), 0, RGBA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&m.Pix[0])))
+	if r.images == nil {
+		r.images = map[*Image]Uint{}
+	}
+	r.images[img] = tex
+	return tex
+}
+
+// releaseImages deletes the textures of released Images.
+func (r *gl33Renderer) releaseImages() {
+	for img, tex := range r.images {
+		if img.released.Load() {
+			DeleteTextures(1, &tex)
+			delete(r.images, img)
+		}
+	}
+}
+
+func (r *gl33Renderer) PushTransform() { r.stack = append(r.stack, r.m) }
+func (r *gl33Renderer) PopTransform() {
+	r.m = r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+}
+func (r *gl33Renderer) Translate(p Point)    { r.m = r.m.Translate(p) }
+func (r *gl33Renderer) Scale(x, y float64)   { r.m = r.m.Scale(x, y) }
+func (r *gl33Renderer) Rotate(rot float64)   { r.m = r.m.Rotate(2 * math.Pi * rot) }
+func (r *gl33Renderer) Transform(m Affine2D) { r.m = r.m.Mul(m) }
+
+func (r *gl33Renderer) Clip(rect Rectangle) {
+	r.flush()
+	ax := r.bufSize.X / r.size.X
+	ay := r.bufSize.Y / r.size.Y
+	x, y := int(ax*rect.Min.X), int(ay*rect.Min.Y)
+	w, h := int(ax*(rect.Dx()+1)), int(ay*(rect.Dy()+1))
+	r.clip = image.Rect(x, int(r.bufSize.Y)-y-h, x+w, int(r.bufSize.Y)-y).Intersect(r.bounds)
+	r.scissor()
+	r.stencil = false
+	Disable(STENCIL_TEST)
+}
+
+// scissor sets the scissor rectangle to the clip.
+func (r *gl33Renderer) scissor() {
+	Scissor(Int(r.clip.Min.X), Int(int(r.bufSize.Y)-r.clip.Max.Y), Sizei(r.clip.Dx()), Sizei(r.clip.Dy()))
+}
+
+// ClipPolygon draws the polygon into the stencil buffer, scissored to its
+// bounds, and then only draws where it was drawn.
+func (r *gl33Renderer) ClipPolygon(pts ...Point) {
+	if len(pts) < 3 {
+		Scissor(0, 0, 0, 0)
+		r.clip = image.Rectangle{}
+		return
+	}
+	b := Rectangle{pts[0], pts[0]}
+	for _, p := range pts[1:] {
+		b = b.Union(Rectangle{p, p})
+	}
+	r.Clip(b)
+
+	Enable(STENCIL_TEST)
+	StencilMask(0xff)
+	Clear(STENCIL_BUFFER_BIT)
+	ColorMask(FALSE, FALSE, FALSE, FALSE)
+	StencilFunc(ALWAYS, 1, 0xff)
+	StencilOp(KEEP, KEEP, REPLACE)
+	r.fan(pts)
+	r.flush()
+	ColorMask(TRUE, TRUE, TRUE, TRUE)
+	StencilFunc(EQUAL, 1, 0xff)
+	StencilOp(KEEP, KEEP, KEEP)
+	r.stencil = true
+}
+
+// BeginLayer renders to a texture through a framebuffer object, which has its
+// own stencil buffer for polygonal clips.
+func (r *gl33Renderer) BeginLayer(l *Layer) bool {
+	if l.Size.X <= 0 || l.Size.Y <= 0 {
+		return false
+	}
+	px := r.pixel()
+	w, h := layerPixels(l, Affine2D{r.m.A / px.X, r.m.B / px.Y, r.m.C / px.X, r.m.D / px.Y, 0, 0})
+	r.flush()
+	e := r.layers[l]
+	if e == nil || e.w != w || e.h != h {
+		if e != nil {
+			e.delete()
+		}
+		e = newGL33Layer(w, h)
+		BindTexture(TEXTURE_2D, r.texs[r.page])
+		if r.layers == nil {
+			r.layers = map[*Layer]*gl33Layer{}
+		}
+		r.layers[l] = e
+	}
+	r.saved = append(r.saved, gl33State{l, r.size, r.bufSize, r.clip, r.bounds, r.stencil, r.fbo, r.m, r.stack, r.color, r.paint, r.pointSize, r.lineWidth})
+
+	r.size, r.bufSize = l.Size, Pt(float64(w), float64(h))
+	r.fbo = e.fbo
+	r.m = Identity2D
+	r.stack = nil
+	r.SetColor(Color{1, 1, 1, 1})
+	r.pointSize = 1
+	r.lineWidth = 1
+	BindFramebuffer(FRAMEBUFFER, e.fbo)
+	Uniform2f(r.sizeLoc, Float(r.size.X), Float(r.size.Y))
+	Viewport(0, 0, Sizei(w), Sizei(h))
+	Scissor(0, 0, Sizei(w), Sizei(h))
+	r.clip = image.Rect(0, 0, w, h)
+	r.bounds = r.clip
+	r.stencil = false
+	Disable(STENCIL_TEST)
+	ClearColor(0, 0, 0, 0)
+	Clear(COLOR_BUFFER_BIT | DEPTH_BUFFER_BIT | STENCIL_BUFFER_BIT)
+	return true
+}
+
+func (r *gl33Renderer) EndLayer() {
+	r.flush()
+	s := r.saved[len(r.saved)-1]
+	r.saved = r.saved[:len(r.saved)-1]
+	r.layers[s.layer].version = s.layer.Version
+	r.size, r.bufSize, r.clip, r.bounds, r.stencil, r.fbo = s.size, s.bufSize, s.clip, s.bounds, s.stencil, s.fbo
+	r.m, r.stack, r.color, r.paint, r.pointSize, r.lineWidth = s.m, s.stack, s.color, s.paint, s.pointSize, s.lineWidth
+
+	BindFramebuffer(FRAMEBUFFER, r.fbo)
+	Uniform2f(r.sizeLoc, Float(r.size.X), Float(r.size.Y))
+	Viewport(0, 0, Sizei(r.bufSize.X), Sizei(r.bufSize.Y))
+	r.scissor()
+	if r.stencil {
+		// The framebuffer's stencil buffer still holds the clip.
+		Enable(STENCIL_TEST)
+		StencilFunc(EQUAL, 1, 0xff)
+		StencilOp(KEEP, KEEP, KEEP)
+	}
+}
+
+// DrawLayer draws the layer's texture, whose color is premultiplied by its
+// alpha, which breaks the batch.  BlendMultiply is exact only over an opaque
+// backdrop.
+func (r *gl33Renderer) DrawLayer(l *Layer, opacity float64, blend BlendMode) bool {
+	e := r.layers[l]
+	if e == nil || e.version != l.Version {
+		return false
+	}
+	px := r.pixel()
+	if w, h := layerPixels(l, Affine2D{r.m.A / px.X, r.m.B / px.Y, r.m.C / px.X, r.m.D / px.Y, 0, 0}); w != e.w || h != e.h {
+		return false
+	}
+	r.flush()
+	BindTexture(TEXTURE_2D, e.tex)
+	Uniform1i(r.modeLoc, 1)
+	switch blend {
+	case BlendMultiply:
+		BlendFuncSeparate(DST_COLOR, ONE_MINUS_SRC_ALPHA, ONE, ONE_MINUS_SRC_ALPHA)
+	case BlendScreen:
+		BlendFuncSeparate(ONE, ONE_MINUS_SRC_COLOR, ONE, ONE_MINUS_SRC_ALPHA)
+	case BlendAdd:
+		BlendFunc(ONE, ONE)
+	default:
+		BlendFunc(ONE, ONE_MINUS_SRC_ALPHA)
+	}
+	color := r.color
+	// Scale all of the premultiplied channels by opacity.
+	k := float32(opacity)
+	r.color = [4]float32{k, k, k, k}
+	// The texture's first row is the bottom of the layer.
+	a, b := r.m.Apply(ZP), r.m.Apply(Pt(l.Size.X, 0))
+	c, d := r.m.Apply(l.Size), r.m.Apply(Pt(0, l.Size.Y))
+	r.vertex(a, Pt(0, 0))
+	r.vertex(b, Pt(1, 0))
+	r.vertex(c, Pt(1, 1))
+	r.vertex(a, Pt(0, 0))
+	r.vertex(c, Pt(1, 1))
+	r.vertex(d, Pt(0, 1))
+	r.flush()
+	r.color = color
+	BlendFuncSeparate(SRC_ALPHA, ONE_MINUS_SRC_ALPHA, ONE, ONE_MINUS_SRC_ALPHA)
+	Uniform1i(r.modeLoc, 0)
+	BindTexture(TEXTURE_2D, r.texs[r.page])
+	return true
+}
+
+func newGL33Layer(w, h int) *gl33Layer {
+	e := &gl33Layer{w: w, h: h}
+	GenTextures(1, &e.tex)
+	BindTexture(TEXTURE_2D, e.tex)
+	TexParameteri(TEXTURE_2D, TEXTURE_MIN_FILTER, LINEAR)
+	TexParameteri(TEXTURE_2D, TEXTURE_MAG_FILTER, LINEAR)
+	TexParameteri(TEXTURE_2D, TEXTURE_WRAP_S, CLAMP_TO_EDGE)
+	TexParameteri(TEXTURE_2D, TEXTURE_WRAP_T, CLAMP_TO_EDGE)
+	TexImage2D(TEXTURE_2D, 0, RGBA8, Sizei(w), Sizei(h), 0, RGBA, UNSIGNED_BYTE, nil)
+	GenRenderbuffers(1, &e.depth)
+	BindRenderbuffer(RENDERBUFFER, e.depth)
+	RenderbufferStorage(RENDERBUFFER, DEPTH24_STENCIL8, Sizei(w), Sizei(h))
+	GenFramebuffers(1, &e.fbo)
+	BindFramebuffer(FRAMEBUFFER, e.fbo)
+	FramebufferTexture2D(FRAMEBUFFER, COLOR_ATTACHMENT0, TEXTURE_2D, e.tex, 0)
+	FramebufferRenderbuffer(FRAMEBUFFER, DEPTH_STENCIL_ATTACHMENT, RENDERBUFFER, e.depth)
+	return e
+}
+
+func (e *gl33Layer) delete() {
+	DeleteFramebuffers(1, &e.fbo)
+	DeleteRenderbuffers(1, &e.depth)
+	DeleteTextures(1, &e.tex)
+}
+
+// releaseLayers deletes the textures of released Layers.
+func (r *gl33Renderer) releaseLayers() {
+	for l, e := range r.layers {
+		if l.released.Load() {
+			e.delete()
+			delete(r.layers, l)
+		}
+	}
+}
+
+// capture reads back the back buffer, which still holds the last frame if it
+// has not been swapped.
+func (r *gl33Renderer) capture() *image.RGBA {
+	w, h := int(r.bufSize.X), int(r.bufSize.Y)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 {
+		return img
+	}
+	PixelStorei(PACK_ALIGNMENT, 1)
+	ReadPixels(0, 0, Sizei(w), Sizei(h), RGBA, UNSIGNED_BYTE, Pointer(unsafe.Pointer(&img.Pix[0])))
+	// OpenGL's rows are bottom to top.
+	row := make([]byte, img.Stride)
+	for y := 0; y < h/2; y++ {
+		a, b := img.Pix[y*img.Stride:(y+1)*img.Stride], img.Pix[(h-1-y)*img.Stride:(h-y)*img.Stride]
+		copy(row, a)
+		copy(a, b)
+		copy(b, row)
+	}
+	return img
+}
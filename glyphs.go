@@ -0,0 +1,288 @@
+package gui
+
+import (
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/vector"
+
+	"image"
+	"math"
+)
+
+// A glyphAtlas packs glyphs rasterized from Fonts into alpha images for GPU
+// Renderers to mirror in textures.  Glyphs are packed in shelves, left to
+// right and top to bottom, on up to maxAtlasPages pages; when they are all
+// full, the least recently used page is cleared.  Glyphs too big for a page
+// are not cached as pixels; drawText hands their outlines to the Renderer to
+// fill as Paths.
+//
+// Each Renderer has its own atlas rather than sharing one.  Windows paint on
+// their own goroutines, and a page evicted by one Renderer would pull glyphs
+// out from under another's batched geometry halfway through its frame; the
+// textures that mirror the pages belong to a single GL context anyway.
+type glyphAtlas struct {
+	pages  []*atlasPage
+	glyphs map[glyphKey]atlasGlyph
+	clock  uint64 // counts lookups, to order the pages by use
+
+	// onEvict, if not nil, is called before a page is cleared, so that
+	// geometry referring to its glyphs can be drawn first.
+	onEvict func(page int)
+
+	z vector.Rasterizer
+}
+
+// An atlasPage is one image of a glyphAtlas.
+type atlasPage struct {
+	img  *image.Alpha
+	x, y int // where the next glyph is placed
+	rowH int // the height of the current shelf
+	used uint64
+
+	// dirty is the part of img that has changed since the last call to flush.
+	dirty image.Rectangle
+}
+
+type glyphKey struct {
+	font  *fontFace
+	index sfnt.GlyphIndex
+	ppem  float64
+	sub   int // the horizontal offset of the origin, in subpixelSteps
+}
+
+// An atlasGlyph is the location of a glyph in a glyphAtlas.
+type atlasGlyph struct {
+	page int
+	r    image.Rectangle // the glyph's pixels in the page
+	// off is the offset of r.Min from the glyph's origin, with Y increasing
+	// downward.
+	off image.Point
+	// big is set for glyphs that do not fit on a page.
+	big bool
+}
+
+const (
+	atlasSize     = 1024
+	maxAtlasPages = 4
+	// subpixelSteps is the number of horizontal positions within a pixel at
+	// which glyphs are rasterized.
+	subpixelSteps = 4
+)
+
+// newGlyphAtlas returns an atlas with one empty page.
+func newGlyphAtlas() *glyphAtlas {
+	a := &glyphAtlas{glyphs: map[glyphKey]atlasGlyph{}}
+	a.addPage()
+	return a
+}
+
+func (a *glyphAtlas) addPage() {
+	p := &atlasPage{img: image.NewAlpha(image.Rect(0, 0, atlasSize, atlasSize))}
+	p.reset()
+	a.pages = append(a.pages, p)
+}
+
+func (p *atlasPage) reset() {
+	clear(p.img.Pix)
+	// An opaque block in the corner serves solid geometry.
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			p.img.Pix[y*p.img.Stride+x] = 0xff
+		}
+	}
+	p.x, p.y, p.rowH = 3, 0, 2
+	p.dirty = p.img.Rect
+}
+
+// white returns the texture coordinates of an opaque texel, which is the same
+// on every page.
+func (a *glyphAtlas) white() Point { return Pt(1, 1).Div(atlasSize) }
+
+// flush returns the part of the page that has changed since the last call.
+func (p *atlasPage) flush() image.Rectangle {
+	r := p.dirty
+	p.dirty = image.Rectangle{}
+	return r
+}
+
+// place reserves a w by h rectangle on the page, reporting false if there is
+// no room.
+func (p *atlasPage) place(w, h int) (image.Rectangle, bool) {
+	x, y, rowH := p.x, p.y, p.rowH
+	if x+w > atlasSize {
+		x, y, rowH = 0, y+rowH, 0
+	}
+	if y+h > atlasSize {
+		return image.Rectangle{}, false
+	}
+	p.x, p.y, p.rowH = x+w, y, max(rowH, h)
+	return image.Rect(x, y, x+w, y+h), true
+}
+
+// place finds room for a w by h glyph, adding a page or clearing the least
+// recently used one if necessary.  It reports false if the glyph does not fit
+// even on an empty page.
+func (a *glyphAtlas) place(w, h int) (int, image.Rectangle, bool) {
+	// The opaque block keeps a glyph too wide to go beside it out of the top
+	// two rows.
+	if w > atlasSize || h > atlasSize-2 {
+		return 0, image.Rectangle{}, false
+	}
+	for i, p := range a.pages {
+		if r, ok := p.place(w, h); ok {
+			return i, r, true
+		}
+	}
+	i := len(a.pages)
+	if i < maxAtlasPages {
+		a.addPage()
+	} else {
+		i = 0
+		for j, p := range a.pages {
+			if p.used < a.pages[i].used {
+				i = j
+			}
+		}
+		if a.onEvict != nil {
+			a.onEvict(i)
+		}
+		for k, e := range a.glyphs {
+			if e.page == i {
+				delete(a.glyphs, k)
+			}
+		}
+		a.pages[i].reset()
+	}
+	r, ok := a.pages[i].place(w, h)
+	return i, r, ok
+}
+
+// glyph returns glyph g of f rasterized at ppem pixels per em with its origin
+// sub/subpixelSteps of a pixel to the right, adding it to the atlas if
+// necessary.  ppem should be quantized by the caller so that the atlas does
+// not fill with nearly identical glyphs.
+func (a *glyphAtlas) glyph(f *fontFace, g sfnt.GlyphIndex, ppem float64, sub int) atlasGlyph {
+	a.clock++
+	k := glyphKey{f, g, ppem, sub}
+	if e, ok := a.glyphs[k]; ok {
+		if e.r.Empty() {
+			return e
+		}
+		a.pages[e.page].used = a.clock
+		return e
+	}
+
+	m := Affine2D{1, 0, 0, -1, float64(sub) / subpixelSteps, 0}
+	var b boundsSink
+	f.glyphOutline(g, ppem, m, &b)
+	if !b.ok {
+		a.glyphs[k] = atlasGlyph{}
+		return atlasGlyph{}
+	}
+	// A pixel of padding keeps linear filtering from bleeding between glyphs.
+	bounds := image.Rect(int(math.Floor(b.r.Min.X))-1, int(math.Floor(b.r.Min.Y))-1, int(math.Ceil(b.r.Max.X))+1, int(math.Ceil(b.r.Max.Y))+1)
+	w, h := bounds.Dx(), bounds.Dy()
+	i, r, ok := a.place(w, h)
+	if !ok {
+		e := atlasGlyph{page: -1, big: true}
+		a.glyphs[k] = e
+		return e
+	}
+	p := a.pages[i]
+	p.used = a.clock
+
+	a.z.Reset(w, h)
+	z := &rasterSink{z: &a.z, off: Pt(float64(bounds.Min.X), float64(bounds.Min.Y))}
+	f.glyphOutline(g, ppem, m, z)
+	z.close()
+	a.z.Draw(p.img, r, image.Opaque, image.Point{})
+	p.dirty = p.dirty.Union(r)
+
+	e := atlasGlyph{page: i, r: r, off: bounds.Min}
+	a.glyphs[k] = e
+	return e
+}
+
+// A glyphQuad is a glyph from a glyphAtlas placed on the framebuffer.
+type glyphQuad struct {
+	page int
+	// dst holds the corners of the glyph in pixels, with Y increasing upward,
+	// in the order top left, top right, bottom right, bottom left.
+	dst [4]Point
+	src image.Rectangle // the glyph's pixels in the page
+}
+
+// drawText calls quad with each glyph of text drawn in f, where m maps text
+// coordinates to framebuffer pixels, both with Y increasing upward.  Glyphs
+// are rasterized at the scale of m, so that they stay sharp on HiDPI
+// framebuffers and in zoomed Views.  When m neither rotates nor skews, each
+// glyph is placed on whole pixels from a rasterization at the nearest
+// subpixel offset, so that its texels map one to one onto pixels.
+//
+// Glyphs too big for the atlas are passed to fill instead, as outlines in text
+// coordinates.
+func (a *glyphAtlas) drawText(f *Font, text string, m Affine2D, quad func(glyphQuad), fill func(*Path)) {
+	// The scale from text to pixels, quantized to limit the glyphs cached.
+	s := math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+	ppem := math.Round(f.Size()*s*4) / 4
+	if ppem == 0 {
+		return
+	}
+	s = ppem / f.Size()
+	snap := m.B == 0 && m.C == 0 && m.A > 0 && math.Abs(m.A-m.D) < 1e-9*m.A
+
+	glyphs, _ := f.layout(text)
+	for _, g := range glyphs {
+		if !snap {
+			e := a.glyph(f.face, g.index, ppem, 0)
+			if e.big {
+				fill(glyphPath(f, g))
+				continue
+			}
+			if e.r.Empty() {
+				continue
+			}
+			// The glyph's corners in text coordinates.
+			x0 := g.x + float64(e.off.X)/s
+			x1 := x0 + float64(e.r.Dx())/s
+			y1 := g.y - float64(e.off.Y)/s
+			y0 := y1 - float64(e.r.Dy())/s
+			quad(glyphQuad{e.page, [4]Point{m.Apply(Pt(x0, y1)), m.Apply(Pt(x1, y1)), m.Apply(Pt(x1, y0)), m.Apply(Pt(x0, y0))}, e.r})
+			continue
+		}
+
+		o := m.Apply(Pt(g.x, g.y))
+		x := math.Floor(o.X)
+		sub := int(math.Round((o.X - x) * subpixelSteps))
+		if sub == subpixelSteps {
+			x, sub = x+1, 0
+		}
+		e := a.glyph(f.face, g.index, ppem, sub)
+		if e.big {
+			fill(glyphPath(f, g))
+			continue
+		}
+		if e.r.Empty() {
+			continue
+		}
+		x0 := x + float64(e.off.X)
+		x1 := x0 + float64(e.r.Dx())
+		y1 := math.Round(o.Y) - float64(e.off.Y)
+		y0 := y1 - float64(e.r.Dy())
+		quad(glyphQuad{e.page, [4]Point{Pt(x0, y1), Pt(x1, y1), Pt(x1, y0), Pt(x0, y0)}, e.r})
+	}
+}
+
+// glyphPath returns the outline of g in text coordinates.
+func glyphPath(f *Font, g glyph) *Path {
+	p := &Path{}
+	f.face.glyphOutline(g.index, f.Size(), Translation(Pt(g.x, g.y)), pathBuilder{p})
+	return p
+}
+
+// A pathBuilder is a pathSink that adds to a Path.
+type pathBuilder struct{ p *Path }
+
+func (b pathBuilder) moveTo(p Point)       { b.p.MoveTo(p) }
+func (b pathBuilder) lineTo(p Point)       { b.p.LineTo(p) }
+func (b pathBuilder) quadTo(c, p Point)    { b.p.QuadTo(c, p) }
+func (b pathBuilder) cubeTo(c, d, p Point) { b.p.CubicTo(c, d, p) }
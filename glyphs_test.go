@@ -0,0 +1,39 @@
+package gui
+
+import "testing"
+
+func TestGlyphAtlasPlace(t *testing.T) {
+	for _, c := range []struct {
+		w, h int
+		ok   bool
+	}{
+		{10, 10, true},
+		{atlasSize - 3, atlasSize - 2, true},
+		{atlasSize, atlasSize - 2, true},
+		{atlasSize, atlasSize - 1, false},
+		{atlasSize - 3, atlasSize, false},
+		{atlasSize + 1, 10, false},
+	} {
+		a := newGlyphAtlas()
+		i, r, ok := a.place(c.w, c.h)
+		if ok != c.ok {
+			t.Errorf("placing %dx%d on an empty atlas reports %v; want %v", c.w, c.h, ok, c.ok)
+			continue
+		}
+		if ok && (i != 0 || r.Dx() != c.w || r.Dy() != c.h || !r.In(a.pages[0].img.Rect)) {
+			t.Errorf("placing %dx%d on an empty atlas gives page %d, %v", c.w, c.h, i, r)
+		}
+	}
+
+	// A glyph that no page can hold is drawn as an outline without evicting
+	// anything.
+	a := newGlyphAtlas()
+	f := DefaultFont().face
+	small := a.glyph(f, 36, 20, 0)
+	if e := a.glyph(f, 36, 2000, 0); !e.big {
+		t.Errorf("glyph at 2000 ppem is not big: %+v", e)
+	}
+	if e := a.glyph(f, 36, 20, 0); e != small || len(a.pages) != 1 {
+		t.Errorf("small glyph moved from %+v to %+v", small, e)
+	}
+}
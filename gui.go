@@ -3,9 +3,15 @@ package gui
 import (
 	"github.com/gordonklaus/glfw"
 	gl "github.com/chsc/gogl/gl21"
+	gl33 "github.com/chsc/gogl/gl33"
 	"log"
 )
 
+// CoreProfile selects an OpenGL 3.3 core-profile context for new Windows,
+// drawn by a batching, shader-based Renderer instead of the fixed-function
+// OpenGL 2.1 one.  It must be set before Run is called.
+var CoreProfile = false
+
 var do = make(chan func(), 1)
 var windows []*Window
 
@@ -18,7 +24,11 @@ func Run(init func()) error {
 		return err
 	}
 	defer glfw.Terminate()
-	if err := gl.Init(); err != nil {
+	if CoreProfile {
+		if err := gl33.Init(); err != nil {
+			return err
+		}
+	} else if err := gl.Init(); err != nil {
 		return err
 	}
 	go init()
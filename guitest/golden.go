@@ -0,0 +1,155 @@
+// Package guitest helps test the appearance of gui Views by comparing their
+// offscreen renderings against golden images checked in under testdata.
+//
+// Run tests with -update to write the current renderings as the new golden
+// images.
+package guitest
+
+import (
+	"github.com/gordonklaus/gui"
+
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden images instead of comparing against them")
+
+// Options control how a rendering is compared against its golden image.
+type Options struct {
+	// Tolerance is the largest difference, out of 255, allowed in any color
+	// channel of a pixel before the pixel is considered to differ.
+	Tolerance uint8
+	// Scale multiplies the size of the rendering, e.g. 2 for HiDPI.  Zero
+	// means 1.
+	Scale float64
+}
+
+// GoldenView renders v and its descendants offscreen and compares the result
+// against testdata/name.png.  v must not belong to a Window that is running;
+// use GoldenWindow for that.
+func GoldenView(t testing.TB, name string, v gui.View, opts Options) {
+	t.Helper()
+	Golden(t, name, gui.RenderImage(v, opts.scale()), opts)
+}
+
+// GoldenWindow renders w offscreen on its goroutine and compares the result
+// against testdata/name.png.
+func GoldenWindow(t testing.TB, name string, w *gui.Window, opts Options) {
+	t.Helper()
+	var img image.Image
+	w.Do(func() { img = gui.RenderImage(w, opts.scale()) })
+	Golden(t, name, img, opts)
+}
+
+// Golden compares img against testdata/name.png, failing t if they differ in
+// size or if any pixel differs by more than opts.Tolerance.  On failure, the
+// actual image and an image highlighting the differing pixels are written
+// alongside the golden image as name.actual.png and name.diff.png.
+func Golden(t testing.TB, name string, img image.Image, opts Options) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+	if *update {
+		if err := writePNG(path, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := readPNG(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	diff, n := Diff(want, img, opts.Tolerance)
+	if n == 0 {
+		os.Remove(filepath.Join("testdata", name+".actual.png"))
+		os.Remove(filepath.Join("testdata", name+".diff.png"))
+		return
+	}
+	writePNG(filepath.Join("testdata", name+".actual.png"), img)
+	writePNG(filepath.Join("testdata", name+".diff.png"), diff)
+	if n < 0 {
+		t.Fatalf("%s: size is %v, want %v", name, img.Bounds().Size(), want.Bounds().Size())
+	}
+	t.Fatalf("%s: %d pixels differ by more than %d", name, n, opts.Tolerance)
+}
+
+// Diff compares images a and b and returns the number of pixels in which any
+// channel differs by more than tolerance, along with an image that shows those
+// pixels in red over a faded copy of a.  If the images differ in size, Diff
+// returns -1 and an image of b.
+func Diff(a, b image.Image, tolerance uint8) (diff *image.RGBA, n int) {
+	ra, rb := a.Bounds(), b.Bounds()
+	if ra.Size() != rb.Size() {
+		diff = image.NewRGBA(image.Rectangle{Max: rb.Size()})
+		for y := 0; y < rb.Dy(); y++ {
+			for x := 0; x < rb.Dx(); x++ {
+				diff.Set(x, y, b.At(rb.Min.X+x, rb.Min.Y+y))
+			}
+		}
+		return diff, -1
+	}
+	diff = image.NewRGBA(image.Rectangle{Max: ra.Size()})
+	tol := uint32(tolerance) * 0x101
+	for y := 0; y < ra.Dy(); y++ {
+		for x := 0; x < ra.Dx(); x++ {
+			ca := color.NRGBA64Model.Convert(a.At(ra.Min.X+x, ra.Min.Y+y)).(color.NRGBA64)
+			cb := color.NRGBA64Model.Convert(b.At(rb.Min.X+x, rb.Min.Y+y)).(color.NRGBA64)
+			if absDiff(ca.R, cb.R) > tol || absDiff(ca.G, cb.G) > tol || absDiff(ca.B, cb.B) > tol || absDiff(ca.A, cb.A) > tol {
+				diff.Set(x, y, color.NRGBA{255, 0, 0, 255})
+				n++
+			} else {
+				gray := color.GrayModel.Convert(ca).(color.Gray).Y
+				diff.Set(x, y, color.NRGBA{gray, gray, gray, uint8(ca.A>>8) / 4})
+			}
+		}
+	}
+	return diff, n
+}
+
+func absDiff(a, b uint16) uint32 {
+	if a > b {
+		return uint32(a - b)
+	}
+	return uint32(b - a)
+}
+
+func (o Options) scale() float64 {
+	if o.Scale == 0 {
+		return 1
+	}
+	return o.Scale
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,133 @@
+package guitest
+
+import (
+	"github.com/gordonklaus/gui"
+
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A shapes View paints a gradient background, a translucent rectangle and a
+// stroked, dashed path.
+type shapes struct{ *gui.ViewBase }
+
+func newShapes() *shapes {
+	v := &shapes{}
+	v.ViewBase = gui.NewView(v)
+	v.Resize(64, 48)
+	return v
+}
+
+func (v *shapes) Paint() {
+	r := gui.InnerRect(v)
+	gui.SetPaint(&gui.LinearGradient{
+		Start: r.Min,
+		End:   r.Max,
+		Stops: []gui.ColorStop{
+			{Offset: 0, Color: gui.Color{B: .5, A: 1}},
+			{Offset: 1, Color: gui.Color{G: .5, B: .5, A: 1}},
+		},
+	})
+	gui.FillRect(r)
+	gui.SetColor(gui.Color{R: 1, G: .5, A: .75})
+	gui.FillRect(gui.Rectangle{Min: gui.Pt(8, 8), Max: gui.Pt(40, 24)})
+	var p gui.Path
+	p.MoveTo(gui.Pt(8, 40))
+	p.QuadTo(gui.Pt(32, 0), gui.Pt(56, 40))
+	gui.SetColor(gui.Color{R: 1, G: 1, B: 1, A: 1})
+	gui.StrokePath(&p, gui.Stroke{Width: 3, Cap: gui.RoundCap, Dashes: []float64{6, 4}})
+}
+
+func TestGoldenView(t *testing.T) {
+	GoldenView(t, "shapes", newShapes(), Options{})
+	GoldenView(t, "shapes@2x", newShapes(), Options{Scale: 2})
+}
+
+// A recorder records failures instead of ending the test.
+type recorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *recorder) Helper() {}
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	want, err := readPNG(filepath.Join("testdata", "shapes.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+	if err := writePNG(filepath.Join("testdata", "shapes.png"), want); err != nil {
+		t.Fatal(err)
+	}
+
+	img := gui.RenderImage(newShapes(), 1)
+	img.Set(3, 4, color.RGBA{255, 0, 255, 255})
+	r := &recorder{TB: t}
+	Golden(r, "shapes", img, Options{})
+	if want := "shapes: 1 pixels differ by more than 0"; r.failure != want {
+		t.Errorf("failure is %q; want %q", r.failure, want)
+	}
+	for _, name := range []string{"shapes.actual.png", "shapes.diff.png"} {
+		if _, err := os.Stat(filepath.Join("testdata", name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// A matching rendering removes the files left by the failure.
+	r = &recorder{TB: t}
+	Golden(r, "shapes", gui.RenderImage(newShapes(), 1), Options{})
+	if r.failure != "" {
+		t.Errorf("unexpected failure %q", r.failure)
+	}
+	for _, name := range []string{"shapes.actual.png", "shapes.diff.png"} {
+		if _, err := os.Stat(filepath.Join("testdata", name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	b := image.NewRGBA(image.Rect(10, 10, 14, 13))
+	for i := range a.Pix {
+		a.Pix[i], b.Pix[i] = 100, 100
+		if i%4 == 3 {
+			a.Pix[i], b.Pix[i] = 255, 255
+		}
+	}
+	if _, n := Diff(a, b, 0); n != 0 {
+		t.Errorf("identical images differ in %d pixels", n)
+	}
+	b.SetRGBA(11, 10, color.RGBA{103, 100, 100, 255})
+	b.SetRGBA(13, 12, color.RGBA{100, 100, 90, 255})
+	for _, c := range []struct {
+		tolerance uint8
+		n         int
+	}{{0, 2}, {2, 2}, {3, 1}, {9, 1}, {10, 0}} {
+		diff, n := Diff(a, b, c.tolerance)
+		if n != c.n {
+			t.Errorf("with tolerance %d, %d pixels differ; want %d", c.tolerance, n, c.n)
+		}
+		if c.n == 2 && diff.RGBAAt(1, 0) != (color.RGBA{255, 0, 0, 255}) {
+			t.Errorf("differing pixel is shown as %v; want red", diff.RGBAAt(1, 0))
+		}
+	}
+	if _, n := Diff(a, image.NewRGBA(image.Rect(0, 0, 3, 4)), 255); n != -1 {
+		t.Errorf("images of different sizes differ in %d pixels; want -1", n)
+	}
+}
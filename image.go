@@ -0,0 +1,160 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"sync/atomic"
+)
+
+// An Image is a bitmap that can be drawn with DrawImage.  Its pixels are
+// copied when it is created and never change, so it may be shared between
+// Windows.  GPU Renderers upload it to a texture the first time they draw it.
+type Image struct {
+	img *image.NRGBA
+	// Filter is how the Image is sampled when it is drawn larger or smaller
+	// than its size in pixels.
+	Filter   Filter
+	released atomic.Bool
+}
+
+// A Filter says how an Image's pixels are interpolated.
+type Filter int
+
+const (
+	// Linear interpolates bilinearly between the nearest four pixels.
+	Linear Filter = iota
+	// Nearest takes the nearest pixel, keeping edges sharp.
+	Nearest
+)
+
+// NewImage returns an Image with a copy of m's pixels.
+func NewImage(m image.Image) *Image {
+	b := m.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Rect, m, b.Min, draw.Src)
+	return &Image{img: img}
+}
+
+// LoadImage decodes an Image in PNG, JPEG or GIF format from r.
+func LoadImage(r io.Reader) (*Image, error) {
+	m, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(m), nil
+}
+
+// Size returns the size of img in pixels.
+func (img *Image) Size() Point {
+	return Pt(float64(img.img.Rect.Dx()), float64(img.img.Rect.Dy()))
+}
+
+// Release frees the textures that Renderers have uploaded img to.  It should
+// be called once img is no longer drawn; img must not be drawn afterward.
+func (img *Image) Release() { img.released.Store(true) }
+
+// DrawImage draws the part src of img stretched over dst.  src is in img's
+// pixels, with Y increasing downward from its top-left corner; if it is empty,
+// the whole Image is drawn.  The Image is tinted by the current color, so
+// that white draws it unchanged and a translucent color draws it translucent.
+func DrawImage(img *Image, dst, src Rectangle) { renderer().DrawImage(img, dst, img.source(src)) }
+
+// source returns src, or the bounds of img if src is empty.
+func (img *Image) source(src Rectangle) Rectangle {
+	if src.Empty() {
+		return Rectangle{ZP, img.Size()}
+	}
+	return src
+}
+
+// tinted returns a copy of the part src of img, rounded out to whole pixels,
+// tinted by c, for Renderers that cannot tint Images themselves.
+func (img *Image) tinted(src Rectangle, c Color) *image.NRGBA {
+	b := image.Rect(int(math.Floor(src.Min.X)), int(math.Floor(src.Min.Y)), int(math.Ceil(src.Max.X)), int(math.Ceil(src.Max.Y))).Intersect(img.img.Rect)
+	m := image.NewNRGBA(b)
+	k := [4]float64{c.R, c.G, c.B, c.A}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i, j := img.img.PixOffset(x, y), m.PixOffset(x, y)
+			for n := range k {
+				m.Pix[j+n] = unitUint8(float64(img.img.Pix[i+n]) / 0xff * k[n])
+			}
+		}
+	}
+	return m
+}
+
+// imageRect returns the transform that maps the unit square to where the pixels
+// b of an Image lie when its part src is drawn over dst, with the first row of
+// b at the top.
+func imageRect(b image.Rectangle, dst, src Rectangle) Affine2D {
+	sx, sy := dst.Dx()/src.Dx(), dst.Dy()/src.Dy()
+	x := dst.Min.X + (float64(b.Min.X)-src.Min.X)*sx
+	y := dst.Max.Y - (float64(b.Max.Y)-src.Min.Y)*sy
+	return Affine2D{float64(b.Dx()) * sx, 0, 0, float64(b.Dy()) * sy, x, y}
+}
+
+// An imagePaint is a Paint that draws part of an Image over a rectangle, for
+// Renderers that draw Images by filling a rectangle.
+type imagePaint struct {
+	img      *Image
+	dst, src Rectangle
+	tint     Color
+}
+
+func (p *imagePaint) colorAt(q Point) Color {
+	x := p.src.Min.X + (q.X-p.dst.Min.X)/p.dst.Dx()*p.src.Dx()
+	y := p.src.Min.Y + (p.dst.Max.Y-q.Y)/p.dst.Dy()*p.src.Dy()
+	c := sampleImage(p.img.img, x, y, p.img.Filter, clampTexel)
+	return Color{c.R * p.tint.R, c.G * p.tint.G, c.B * p.tint.B, c.A * p.tint.A}
+}
+
+// sampleImage returns the color of m at (x, y), in pixels from the top-left
+// corner of its bounds.  wrap maps pixel indices outside the bounds to ones
+// inside.
+func sampleImage(m image.Image, x, y float64, filter Filter, wrap func(i, n int) int) Color {
+	b := m.Bounds()
+	if b.Empty() {
+		return Color{}
+	}
+	at := func(i, j int) color.Color {
+		return m.At(b.Min.X+wrap(i, b.Dx()), b.Min.Y+wrap(j, b.Dy()))
+	}
+	if filter == Nearest {
+		r, g, bl, a := at(int(math.Floor(x)), int(math.Floor(y))).RGBA()
+		if a == 0 {
+			return Color{}
+		}
+		return Color{float64(r) / float64(a), float64(g) / float64(a), float64(bl) / float64(a), float64(a) / 0xffff}
+	}
+	// Pixel centers are at integers.
+	x, y = x-.5, y-.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	var sum [4]float64
+	for _, s := range [4]struct {
+		dx, dy int
+		k      float64
+	}{{0, 0, (1 - fx) * (1 - fy)}, {1, 0, fx * (1 - fy)}, {0, 1, (1 - fx) * fy}, {1, 1, fx * fy}} {
+		if s.k == 0 {
+			continue
+		}
+		r, g, bl, a := at(int(x0)+s.dx, int(y0)+s.dy).RGBA()
+		sum[0] += s.k * float64(r)
+		sum[1] += s.k * float64(g)
+		sum[2] += s.k * float64(bl)
+		sum[3] += s.k * float64(a)
+	}
+	if sum[3] == 0 {
+		return Color{}
+	}
+	return Color{sum[0] / sum[3], sum[1] / sum[3], sum[2] / sum[3], sum[3] / 0xffff}
+}
+
+func clampTexel(i, n int) int { return max(0, min(n-1, i)) }
@@ -0,0 +1,179 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// A ZoomMode says how an ImageView scales its image to its size.
+type ZoomMode int
+
+const (
+	// ZoomFit shows the whole image as large as it fits.
+	ZoomFit ZoomMode = iota
+	// ZoomFill covers the ImageView with the image, cropping it if necessary.
+	ZoomFill
+	// ZoomActual shows the image at one pixel per unit.
+	ZoomActual
+)
+
+// An ImageView displays an image.Image, which can be zoomed about the cursor
+// with the scroll wheel and panned by dragging.  While the mouse is over the
+// image, a readout shows the coordinates and color of the pixel under it.
+//
+// Its inner coordinates are the image's pixels, with the image's bottom-left
+// corner at the origin.
+type ImageView struct {
+	*ViewBase
+	src  image.Image
+	img  *Image
+	mode ZoomMode
+	// zoomed is set once the image has been zoomed or panned by hand, after
+	// which resizing the ImageView no longer applies mode.
+	zoomed bool
+
+	font     *Font
+	hover    image.Point // the pixel under the mouse, in src's coordinates
+	hovering bool
+	drag     Point // where the image was grabbed, in image pixels
+}
+
+func NewImageView(m image.Image) *ImageView {
+	v := &ImageView{}
+	v.ViewBase = NewView(v)
+	v.font = DefaultFont()
+	v.SetImage(m)
+	return v
+}
+
+// Image returns the displayed image.
+func (v *ImageView) Image() image.Image { return v.src }
+
+// SetImage displays m, scaled according to the ZoomMode.
+func (v *ImageView) SetImage(m image.Image) {
+	if v.img != nil {
+		v.img.Release()
+	}
+	v.src = m
+	v.img = NewImage(m)
+	v.hovering = false
+	v.zoomed = false
+	v.fit()
+}
+
+// SetZoomMode scales the image according to mode, discarding any zooming or
+// panning by hand.
+func (v *ImageView) SetZoomMode(mode ZoomMode) {
+	v.mode = mode
+	v.zoomed = false
+	v.fit()
+}
+
+// Zoom returns the size of an image pixel in the ImageView's frame.
+func (v *ImageView) Zoom() float64 { return v.scale.X }
+
+func (v *ImageView) Resize(width, height float64) {
+	v.ViewBase.Resize(width, height)
+	if !v.zoomed {
+		v.fit()
+	}
+}
+
+// fit scales and centers the image according to v.mode.
+func (v *ImageView) fit() {
+	if v.img == nil {
+		return
+	}
+	size := v.img.Size()
+	if size.X == 0 || size.Y == 0 || v.size.X == 0 || v.size.Y == 0 {
+		return
+	}
+	z := 1.0
+	switch v.mode {
+	case ZoomFit:
+		z = math.Min(v.size.X/size.X, v.size.Y/size.Y)
+	case ZoomFill:
+		z = math.Max(v.size.X/size.X, v.size.Y/size.Y)
+	}
+	v.Scale(z, z)
+	v.Pan(size.Mul(z / 2).Sub(v.size.Div(2)))
+}
+
+// Scroll zooms about the cursor, by a factor of 1.1 per unit scrolled.
+func (v *ImageView) Scroll(s ScrollEvent) {
+	z := v.Zoom()
+	z2 := math.Max(1./64, math.Min(256, z*math.Pow(1.1, s.Delta.Y)))
+	// Keep s.Pos at the same place in the frame.
+	v.Pan(v.pan.Add(s.Pos.Mul(z2 - z)))
+	v.Scale(z2, z2)
+	v.zoomed = true
+	v.updateHover(s.Pos)
+}
+
+func (v *ImageView) Mouse(m MouseEvent) {
+	switch {
+	case m.Press:
+		v.drag = m.Pos
+	case m.Drag, m.Release:
+		// Keep the grabbed pixel under the mouse.  The pan is in the
+		// frame's units, which are zoomed pixels.
+		if d := v.drag.Sub(m.Pos); d != ZP {
+			v.Pan(v.pan.Add(Pt(d.X*v.scale.X, d.Y*v.scale.Y)))
+			v.zoomed = true
+		}
+	}
+	if m.Leave {
+		v.hovering = false
+		Repaint(v)
+		return
+	}
+	v.updateHover(m.Pos)
+}
+
+func (v *ImageView) Hover(m MouseEvent) { v.updateHover(m.Pos) }
+
+// updateHover finds the pixel under p, which is in v's coordinates.
+func (v *ImageView) updateHover(p Point) {
+	b := v.src.Bounds()
+	x, y := int(math.Floor(p.X)), int(math.Floor(float64(b.Dy())-p.Y))
+	hover := image.Pt(b.Min.X+x, b.Min.Y+y)
+	hovering := hover.In(b)
+	if hover != v.hover || hovering != v.hovering {
+		v.hover, v.hovering = hover, hovering
+		Repaint(v)
+	}
+}
+
+func (v *ImageView) Paint() {
+	r := InnerRect(v)
+	SetColor(Color{.2, .2, .2, 1})
+	FillRect(r)
+
+	// Show pixels sharply when they are enlarged, for inspection.
+	v.img.Filter = Linear
+	if v.Zoom() >= 1 {
+		v.img.Filter = Nearest
+	}
+	SetColor(Color{1, 1, 1, 1})
+	DrawImage(v.img, Rectangle{ZP, v.img.Size()}, ZR)
+
+	if !v.hovering {
+		return
+	}
+	c := color.NRGBAModel.Convert(v.src.At(v.hover.X, v.hover.Y)).(color.NRGBA)
+	text := fmt.Sprintf("%d, %d: %d %d %d %d", v.hover.X, v.hover.Y, c.R, c.G, c.B, c.A)
+	// Draw the readout at a constant size in the bottom-left corner of the
+	// frame.
+	PushTransform()
+	defer PopTransform()
+	Translate(r.Min)
+	Scale(1/v.scale.X, 1/v.scale.Y)
+	const margin = 4
+	h := v.font.Ascender() - v.font.Descender()
+	SetColor(Color{0, 0, 0, .7})
+	FillRect(Rectangle{ZP, Pt(v.font.Advance(text)+2*margin, h+2*margin)})
+	SetColor(Color{1, 1, 1, 1})
+	DrawText(v.font, text, Pt(margin, margin-v.font.Descender()))
+}
@@ -0,0 +1,113 @@
+package gui
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// A Layer is the offscreen rendering of a cached View and its descendants.
+// Renderers that support layers keep an image of each Layer they render,
+// keyed by its address, and draw that image until the Layer's Version changes.
+type Layer struct {
+	// Size is the size of the Layer in the coordinates it is drawn in.
+	Size Point
+	// Version changes whenever the Layer's contents may have changed.
+	Version  uint64
+	released atomic.Bool
+}
+
+// Release frees the images that Renderers have made of l.
+func (l *Layer) Release() { l.released.Store(true) }
+
+// A BlendMode says how a View's layer is combined with what is painted
+// beneath it.  Colors are combined as if premultiplied by their alpha.
+type BlendMode int
+
+const (
+	// BlendNormal paints the layer over the backdrop.
+	BlendNormal BlendMode = iota
+	// BlendMultiply multiplies the backdrop's colors by the layer's,
+	// darkening it.
+	BlendMultiply
+	// BlendScreen multiplies the complements of the backdrop's and the
+	// layer's colors, lightening the backdrop.
+	BlendScreen
+	// BlendAdd adds the layer's colors to the backdrop's.
+	BlendAdd
+)
+
+// blend returns the premultiplied color or alpha of a pixel of the layer, s
+// with alpha sa, blended with that of the backdrop, d with alpha da.
+func (b BlendMode) blend(s, sa, d, da float64) float64 {
+	switch b {
+	case BlendMultiply:
+		return s*d + s*(1-da) + d*(1-sa)
+	case BlendScreen:
+		return s + d - s*d
+	case BlendAdd:
+		return math.Min(1, s+d)
+	}
+	return s + d*(1-sa)
+}
+
+// layerPixels returns the size in pixels of an image of l drawn with the
+// transform m from its coordinates to pixels.
+func layerPixels(l *Layer, m Affine2D) (int, int) {
+	sx, sy := math.Hypot(m.A, m.B), math.Hypot(m.C, m.D)
+	return max(1, int(math.Ceil(l.Size.X*sx-1e-6))), max(1, int(math.Ceil(l.Size.Y*sy-1e-6)))
+}
+
+// invalidateLayers marks the layers of v and its ancestors as changed.
+func invalidateLayers(v View) {
+	for ; v != nil; v = Parent(v) {
+		if l := v.base().layer; l != nil {
+			l.Version++
+		}
+	}
+}
+
+// paintLayer draws v's layer over its frame in the current transform with v's
+// opacity and blend mode, first rendering it if its Renderer has no image of
+// its current Version.  If the Renderer does not support layers, v is painted
+// directly as part of root: its opacity is approximated by fading the colors
+// and paints it and its descendants set, which shows overlapping descendants
+// through each other, and its blend mode is not applied.
+func (v *ViewBase) paintLayer(root View) {
+	rend := painting.r
+	if v.layer == nil {
+		v.layer = &Layer{}
+	}
+	l := v.layer
+	if l.Size != v.size {
+		l.Size = v.size
+		l.Version++
+	}
+	if rend.DrawLayer(l, v.opacity, v.blend) {
+		return
+	}
+	if !rend.BeginLayer(l) {
+		rend.Transform(Translation(v.pan.Mul(-1)).Scale(v.scale.X, v.scale.Y))
+		alpha := painting.alpha
+		painting.alpha *= v.opacity
+		v.paintContents(root)
+		painting.alpha = alpha
+		return
+	}
+	// Render v as the root of the layer, in the coordinates of its frame.
+	// The whole layer is rendered, however little of it is damaged.
+	damage := painting.damage
+	painting.damage = Rectangle{ZP, v.size}
+	rend.Transform(frameToParent(v.Self).Invert())
+	v.paint(v.Self)
+	painting.damage = damage
+	rend.EndLayer()
+	rend.DrawLayer(l, v.opacity, v.blend)
+}
+
+// releaseLayer releases v's layer, if it has one.
+func (v *ViewBase) releaseLayer() {
+	if v.layer != nil {
+		v.layer.Release()
+		v.layer = nil
+	}
+}
@@ -5,12 +5,22 @@ type Mouser interface {
 }
 
 type MouseEvent struct {
-	Pos                        Point
-	Enter, Leave               bool
+	Pos          Point
+	Enter, Leave bool
+	// Move is set in the events passed to Hover.  Drag is set when the mouse
+	// moves anywhere while a button pressed over the Mouser is held.
 	Move, Press, Release, Drag bool
 	Button                     int
 }
 
+// A Hoverer is told when the mouse moves over it, as for a readout of what
+// is under the mouse.  Mousers only learn of such moves through Enter and
+// Leave.  Hover is not called while a button pressed over the Hoverer is
+// held, as its Mouse method is told of those moves with Drag.
+type Hoverer interface {
+	Hover(MouseEvent)
+}
+
 type AggregateMouser []Mouser
 
 func (a AggregateMouser) Mouse(m MouseEvent) {
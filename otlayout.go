@@ -0,0 +1,747 @@
+package gui
+
+import (
+	"golang.org/x/image/font/sfnt"
+
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+// otLayout holds the OpenType layout tables of a font, which substitute and
+// position glyphs beyond what its character map and advances provide.
+type otLayout struct {
+	gdef, gsub, gpos otTable
+	lookups          map[otKey][]featureLookup // cached by otTable.lookups
+}
+
+type otKey struct {
+	gpos   bool
+	script string
+}
+
+// An otTable is the bytes of an OpenType table.  It is read leniently:
+// anything out of range reads as zero, so that a malformed table has no effect
+// rather than causing a panic.
+type otTable []byte
+
+func (t otTable) u16(off int) int {
+	if off < 0 || off+2 > len(t) {
+		return 0
+	}
+	return int(t[off])<<8 | int(t[off+1])
+}
+
+func (t otTable) i16(off int) int { return int(int16(t.u16(off))) }
+func (t otTable) u32(off int) int { return t.u16(off)<<16 | t.u16(off+2) }
+
+func (t otTable) tag(off int) string {
+	if off < 0 || off+4 > len(t) {
+		return ""
+	}
+	return string(t[off : off+4])
+}
+
+// offset returns base plus the 16-bit offset at off, or 0 if it is null.
+func (t otTable) offset(base, off int) int {
+	if o := t.u16(off); o != 0 {
+		return base + o
+	}
+	return 0
+}
+
+// parseOTLayout finds the layout tables in the font file data.
+func parseOTLayout(data []byte) *otLayout {
+	d := otTable(data)
+	l := &otLayout{lookups: map[otKey][]featureLookup{}}
+	for i := 0; i < d.u16(4); i++ {
+		rec := 12 + 16*i
+		off, n := d.u32(rec+8), d.u32(rec+12)
+		if off+n > len(d) {
+			continue
+		}
+		switch t := otTable(d[off : off+n]); d.tag(rec) {
+		case "GDEF":
+			l.gdef = t
+		case "GSUB":
+			l.gsub = t
+		case "GPOS":
+			l.gpos = t
+		}
+	}
+	return l
+}
+
+// coverage returns the index of g in the Coverage table at off, or -1.
+func (t otTable) coverage(off int, g sfnt.GlyphIndex) int {
+	if off == 0 {
+		return -1
+	}
+	x := int(g)
+	n := t.u16(off + 2)
+	switch t.u16(off) {
+	case 1:
+		i := sort.Search(n, func(i int) bool { return t.u16(off+4+2*i) >= x })
+		if i < n && t.u16(off+4+2*i) == x {
+			return i
+		}
+	case 2:
+		i := sort.Search(n, func(i int) bool { return t.u16(off+4+6*i+2) >= x })
+		if r := off + 4 + 6*i; i < n && t.u16(r) <= x {
+			return t.u16(r+4) + x - t.u16(r)
+		}
+	}
+	return -1
+}
+
+// class returns the class of g in the ClassDef table at off.
+func (t otTable) class(off int, g sfnt.GlyphIndex) int {
+	if off == 0 {
+		return 0
+	}
+	x := int(g)
+	switch t.u16(off) {
+	case 1:
+		if start := t.u16(off + 2); x >= start && x < start+t.u16(off+4) {
+			return t.u16(off + 6 + 2*(x-start))
+		}
+	case 2:
+		n := t.u16(off + 2)
+		i := sort.Search(n, func(i int) bool { return t.u16(off+4+6*i+2) >= x })
+		if r := off + 4 + 6*i; i < n && t.u16(r) <= x {
+			return t.u16(r + 4)
+		}
+	}
+	return 0
+}
+
+// A featureLookup is a lookup applied for the features that include it.
+type featureLookup struct {
+	index    int
+	features []string
+}
+
+// lookups returns the lookups of the features for the first of scripts that
+// the table supports, or for its default script, in the order they apply.
+func (t otTable) lookups(scripts, features []string) []featureLookup {
+	scriptList, featureList := t.offset(0, 4), t.offset(0, 6)
+	if scriptList == 0 || featureList == 0 {
+		return nil
+	}
+	langSys := 0
+	for _, want := range append(scripts, "DFLT", "dflt", "latn") {
+		for i := 0; i < t.u16(scriptList) && langSys == 0; i++ {
+			if rec := scriptList + 2 + 6*i; t.tag(rec) == want {
+				script := scriptList + t.u16(rec+4)
+				langSys = t.offset(script, script)
+			}
+		}
+		if langSys != 0 {
+			break
+		}
+	}
+	if langSys == 0 {
+		return nil
+	}
+
+	var ls []featureLookup
+	add := func(i int, required bool) {
+		rec := featureList + 2 + 6*i
+		tag := t.tag(rec)
+		if !required && !slices.Contains(features, tag) {
+			return
+		}
+		feature := featureList + t.u16(rec+4)
+		for j := 0; j < t.u16(feature+2); j++ {
+			ls = append(ls, featureLookup{t.u16(feature + 4 + 2*j), []string{tag}})
+		}
+	}
+	if i := t.u16(langSys + 2); i != 0xFFFF {
+		add(i, true)
+	}
+	for j := 0; j < t.u16(langSys+4); j++ {
+		add(t.u16(langSys+6+2*j), false)
+	}
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i].index < ls[j].index })
+
+	// A lookup shared by several features is applied once, for all of them.
+	merged := ls[:0]
+	for _, fl := range ls {
+		if n := len(merged); n > 0 && merged[n-1].index == fl.index {
+			if !slices.Contains(merged[n-1].features, fl.features[0]) {
+				merged[n-1].features = append(merged[n-1].features, fl.features[0])
+			}
+			continue
+		}
+		merged = append(merged, fl)
+	}
+	return merged
+}
+
+// An otLookup is a lookup of a GSUB or GPOS table.
+type otLookup struct {
+	typ, flag int
+	set       int   // the mark filtering set, if flag has useMarkFilteringSet
+	subtables []int // with extension subtables resolved
+}
+
+// Lookup flags.
+const (
+	ignoreBaseGlyphs    = 0x2
+	ignoreLigatures     = 0x4
+	ignoreMarks         = 0x8
+	useMarkFilteringSet = 0x10
+)
+
+// Glyph classes in GDEF.
+const (
+	baseGlyph      = 1
+	ligatureGlyph  = 2
+	markGlyph      = 3
+	componentGlyph = 4
+)
+
+// lookup returns lookup i of the table, whose extension lookup type is ext.
+func (t otTable) lookup(i, ext int) otLookup {
+	list := t.offset(0, 8)
+	if list == 0 || i >= t.u16(list) {
+		return otLookup{}
+	}
+	l := list + t.u16(list+2+2*i)
+	lk := otLookup{typ: t.u16(l), flag: t.u16(l + 2)}
+	n := t.u16(l + 4)
+	for j := 0; j < n; j++ {
+		s := l + t.u16(l+6+2*j)
+		if lk.typ == ext {
+			lk.subtables = append(lk.subtables, s+t.u32(s+4))
+			if j == 0 {
+				lk.typ = t.u16(s + 2)
+			}
+			continue
+		}
+		lk.subtables = append(lk.subtables, s)
+	}
+	if lk.flag&useMarkFilteringSet != 0 {
+		lk.set = t.u16(l + 6 + 2*n)
+	}
+	return lk
+}
+
+// glyphClass returns the GDEF class of g, or 0 if the font does not classify
+// its glyphs.
+func (l *otLayout) glyphClass(g sfnt.GlyphIndex) int {
+	return l.gdef.class(l.gdef.offset(0, 4), g)
+}
+
+// ignored reports whether lk skips over g, a glyph of class c.
+func (l *otLayout) ignored(lk otLookup, g sfnt.GlyphIndex, c int) bool {
+	switch c {
+	case baseGlyph:
+		return lk.flag&ignoreBaseGlyphs != 0
+	case ligatureGlyph:
+		return lk.flag&ignoreLigatures != 0
+	case markGlyph:
+		if lk.flag&ignoreMarks != 0 {
+			return true
+		}
+		if lk.flag&useMarkFilteringSet != 0 {
+			// The MarkGlyphSetsDef is new in version 1.2 of GDEF.
+			sets := l.gdef.offset(0, 12)
+			if l.gdef.u32(0) < 0x00010002 || sets == 0 || lk.set >= l.gdef.u16(sets+2) {
+				return true
+			}
+			return l.gdef.coverage(sets+l.gdef.u32(sets+4+4*lk.set), g) < 0
+		}
+		if t := lk.flag >> 8; t != 0 {
+			return l.gdef.class(l.gdef.offset(0, 10), g) != t
+		}
+	}
+	return false
+}
+
+// next returns the index of the first glyph after i in s that lk does not
+// skip, or len(s.buf).
+func (s *shaper) next(lk otLookup, i int) int {
+	for i++; i < len(s.buf) && s.skip(lk, i); i++ {
+	}
+	return i
+}
+
+// prev returns the index of the last glyph before i in s that lk does not
+// skip, or -1.
+func (s *shaper) prev(lk otLookup, i int) int {
+	for i--; i >= 0 && s.skip(lk, i); i-- {
+	}
+	return i
+}
+
+func (s *shaper) skip(lk otLookup, i int) bool {
+	return s.ot.ignored(lk, s.buf[i].index, s.buf[i].class)
+}
+
+// substitute applies lookup index of GSUB to the glyphs that any of its
+// features apply to.
+func (s *shaper) substitute(fl featureLookup) {
+	lk := s.ot.gsub.lookup(fl.index, 7)
+	for i := 0; i < len(s.buf); {
+		if slices.ContainsFunc(fl.features, s.buf[i].applies) && !s.skip(lk, i) {
+			if j, ok := s.substAt(lk, i); ok {
+				i = j
+				continue
+			}
+		}
+		i++
+	}
+}
+
+// substAt applies the first subtable of lk that matches at buf[i], returning
+// the index of the glyph after those it substituted.
+func (s *shaper) substAt(lk otLookup, i int) (int, bool) {
+	t := s.ot.gsub
+	g := s.buf[i].index
+	for _, st := range lk.subtables {
+		switch lk.typ {
+		case 1: // single
+			cov := t.coverage(t.offset(st, st+2), g)
+			if cov < 0 {
+				continue
+			}
+			switch t.u16(st) {
+			case 1:
+				s.replace(i, sfnt.GlyphIndex(int(g)+t.i16(st+4)))
+			case 2:
+				if cov >= t.u16(st+4) {
+					continue
+				}
+				s.replace(i, sfnt.GlyphIndex(t.u16(st+6+2*cov)))
+			default:
+				continue
+			}
+			return i + 1, true
+
+		case 2, 3: // multiple, alternate
+			cov := t.coverage(t.offset(st, st+2), g)
+			if cov < 0 || cov >= t.u16(st+4) {
+				continue
+			}
+			seq := st + t.u16(st+6+2*cov)
+			n := t.u16(seq)
+			if n == 0 {
+				continue
+			}
+			if lk.typ == 3 {
+				s.replace(i, sfnt.GlyphIndex(t.u16(seq+2)))
+				return i + 1, true
+			}
+			gs := make([]shapeGlyph, n)
+			for k := range gs {
+				gs[k] = s.buf[i]
+				gs[k].index = sfnt.GlyphIndex(t.u16(seq + 2 + 2*k))
+				gs[k].class = s.classOf(gs[k].index, gs[k].class)
+			}
+			s.buf = slices.Replace(s.buf, i, i+1, gs...)
+			return i + n, true
+
+		case 4: // ligature
+			cov := t.coverage(t.offset(st, st+2), g)
+			if cov < 0 || cov >= t.u16(st+4) {
+				continue
+			}
+			set := st + t.u16(st+6+2*cov)
+			for k := 0; k < t.u16(set); k++ {
+				lig := set + t.u16(set+2+2*k)
+				pos := []int{i}
+				for c := 1; c < t.u16(lig+2); c++ {
+					j := s.next(lk, pos[len(pos)-1])
+					if j == len(s.buf) || s.buf[j].index != sfnt.GlyphIndex(t.u16(lig+2+2*c)) {
+						pos = nil
+						break
+					}
+					pos = append(pos, j)
+				}
+				if pos == nil {
+					continue
+				}
+				s.buf[i].index = sfnt.GlyphIndex(t.u16(lig))
+				s.buf[i].class = s.classOf(s.buf[i].index, ligatureGlyph)
+				for k := len(pos) - 1; k > 0; k-- {
+					s.buf = slices.Delete(s.buf, pos[k], pos[k]+1)
+				}
+				return i + 1, true
+			}
+
+		case 5, 6: // context, chained context
+			if j, ok := s.context(t, lk, st, i, s.substNested); ok {
+				return j, true
+			}
+		}
+	}
+	return i, false
+}
+
+// replace substitutes glyph g for buf[i].
+func (s *shaper) replace(i int, g sfnt.GlyphIndex) {
+	s.buf[i].index = g
+	s.buf[i].class = s.classOf(g, s.buf[i].class)
+}
+
+// classOf returns the GDEF class of g, or c if the font does not classify
+// its glyphs.
+func (s *shaper) classOf(g sfnt.GlyphIndex, c int) int {
+	if gc := s.ot.glyphClass(g); gc != 0 {
+		return gc
+	}
+	return c
+}
+
+func (s *shaper) substNested(lookup, i int) {
+	lk := s.ot.gsub.lookup(lookup, 7)
+	if !s.skip(lk, i) {
+		s.substAt(lk, i)
+	}
+}
+
+func (s *shaper) posNested(lookup, i int) {
+	lk := s.ot.gpos.lookup(lookup, 9)
+	if !s.skip(lk, i) {
+		s.posAt(lk, i)
+	}
+}
+
+// context applies a context or chained context subtable st of t at buf[i],
+// applying the nested lookups of the matching rule with apply.  It returns the
+// index of the glyph after the input sequence.
+func (s *shaper) context(t otTable, lk otLookup, st, i int, apply func(lookup, i int)) (int, bool) {
+	chained := lk.typ == 6 || lk.typ == 8
+	format := t.u16(st)
+	g := s.buf[i].index
+
+	if format == 3 {
+		var nb, ni, nl, back, input, ahead, rec, nRec int
+		if chained {
+			nb, back = t.u16(st+2), st+4
+			ni, input = t.u16(back+2*nb), back+2*nb+2
+			nl, ahead = t.u16(input+2*ni), input+2*ni+2
+			nRec, rec = t.u16(ahead+2*nl), ahead+2*nl+2
+		} else {
+			ni, nRec, input = t.u16(st+2), t.u16(st+4), st+6
+			rec = input + 2*ni
+		}
+		if ni == 0 {
+			return i, false
+		}
+		covered := func(off int) func(k, j int) bool {
+			return func(k, j int) bool { return t.coverage(t.offset(st, off+2*k), s.buf[j].index) >= 0 }
+		}
+		return s.applyRule(lk, i, nb, ni, nl, covered(back), covered(input), covered(ahead), t, rec, nRec, apply)
+	}
+	if format != 1 && format != 2 || t.coverage(t.offset(st, st+2), g) < 0 {
+		return i, false
+	}
+
+	// The ClassDefs of format 2, and where the rule sets are.
+	var backClasses, inputClasses, aheadClasses, sets int
+	switch {
+	case format == 1:
+		sets = st + 4
+	case chained:
+		backClasses, inputClasses, aheadClasses = t.offset(st, st+4), t.offset(st, st+6), t.offset(st, st+8)
+		sets = st + 10
+	default:
+		inputClasses = t.offset(st, st+4)
+		sets = st + 6
+	}
+	set := t.coverage(t.offset(st, st+2), g)
+	if format == 2 {
+		set = t.class(inputClasses, g)
+	}
+	if set >= t.u16(sets) {
+		return i, false
+	}
+	rules := t.offset(st, sets+2+2*set)
+	if rules == 0 {
+		return i, false
+	}
+	// seq returns a predicate matching the sequence of glyphs, or of classes
+	// in classDef, at off.
+	seq := func(classDef, off int) func(k, j int) bool {
+		if format == 1 {
+			return func(k, j int) bool { return s.buf[j].index == sfnt.GlyphIndex(t.u16(off+2*k)) }
+		}
+		return func(k, j int) bool { return t.class(classDef, s.buf[j].index) == t.u16(off+2*k) }
+	}
+	for r := 0; r < t.u16(rules); r++ {
+		rule := rules + t.u16(rules+2+2*r)
+		var nb, ni, nl, back, input, ahead, rec, nRec int
+		if chained {
+			nb, back = t.u16(rule), rule+2
+			ni, input = t.u16(back+2*nb), back+2*nb+2
+		} else {
+			ni, nRec, input = t.u16(rule), t.u16(rule+2), rule+4
+		}
+		if ni == 0 {
+			continue
+		}
+		if chained {
+			nl, ahead = t.u16(input+2*(ni-1)), input+2*(ni-1)+2
+			nRec, rec = t.u16(ahead+2*nl), ahead+2*nl+2
+		} else {
+			rec = input + 2*(ni-1)
+		}
+		// The first input glyph is matched by the coverage, and the sequence
+		// holds the rest.
+		rest := seq(inputClasses, input)
+		in := func(k, j int) bool { return k == 0 || rest(k-1, j) }
+		if j, ok := s.applyRule(lk, i, nb, ni, nl, seq(backClasses, back), in, seq(aheadClasses, ahead), t, rec, nRec, apply); ok {
+			return j, true
+		}
+	}
+	return i, false
+}
+
+// applyRule matches the nb glyphs before buf[i], the ni glyphs starting at it
+// and the nl glyphs after those with the predicates back, input and ahead,
+// which are given the index of the glyph within its sequence and in buf.  If
+// they all match, it applies the nRec nested lookups of the SequenceLookup
+// records at rec.
+func (s *shaper) applyRule(lk otLookup, i, nb, ni, nl int, back, input, ahead func(k, j int) bool, t otTable, rec, nRec int, apply func(lookup, i int)) (int, bool) {
+	pos := []int{i}
+	if !input(0, i) {
+		return i, false
+	}
+	for k := 1; k < ni; k++ {
+		j := s.next(lk, pos[k-1])
+		if j == len(s.buf) || !input(k, j) {
+			return i, false
+		}
+		pos = append(pos, j)
+	}
+	j := i
+	for k := 0; k < nb; k++ {
+		if j = s.prev(lk, j); j < 0 || !back(k, j) {
+			return i, false
+		}
+	}
+	j = pos[ni-1]
+	for k := 0; k < nl; k++ {
+		if j = s.next(lk, j); j == len(s.buf) || !ahead(k, j) {
+			return i, false
+		}
+	}
+
+	end := pos[ni-1] + 1
+	for r := 0; r < nRec; r++ {
+		seq, lookup := t.u16(rec+4*r), t.u16(rec+4*r+2)
+		if seq >= len(pos) {
+			continue
+		}
+		n := len(s.buf)
+		apply(lookup, pos[seq])
+		// Keep the positions after a substitution that changed the number of
+		// glyphs pointing at the same glyphs.
+		if d := len(s.buf) - n; d != 0 {
+			for k := seq + 1; k < len(pos); k++ {
+				pos[k] += d
+			}
+			end += d
+		}
+	}
+	return end, true
+}
+
+// position applies lookup index of GPOS to the glyphs.
+func (s *shaper) position(fl featureLookup) {
+	lk := s.ot.gpos.lookup(fl.index, 9)
+	for i := 0; i < len(s.buf); {
+		if !s.skip(lk, i) {
+			if j, ok := s.posAt(lk, i); ok {
+				i = j
+				continue
+			}
+		}
+		i++
+	}
+}
+
+// posAt applies the first subtable of lk that matches at buf[i], returning
+// the index of the next glyph to position.
+func (s *shaper) posAt(lk otLookup, i int) (int, bool) {
+	t := s.ot.gpos
+	g := s.buf[i].index
+	for _, st := range lk.subtables {
+		switch lk.typ {
+		case 1: // single
+			cov := t.coverage(t.offset(st, st+2), g)
+			if cov < 0 {
+				continue
+			}
+			vf := t.u16(st + 4)
+			switch t.u16(st) {
+			case 1:
+				t.value(st+6, vf, &s.buf[i])
+			case 2:
+				t.value(st+8+cov*valueSize(vf), vf, &s.buf[i])
+			}
+			return i + 1, true
+
+		case 2: // pair
+			cov := t.coverage(t.offset(st, st+2), g)
+			if cov < 0 {
+				continue
+			}
+			j := s.next(lk, i)
+			if j == len(s.buf) {
+				continue
+			}
+			vf1, vf2 := t.u16(st+4), t.u16(st+6)
+			size := valueSize(vf1) + valueSize(vf2)
+			rec := 0
+			switch t.u16(st) {
+			case 1:
+				if cov >= t.u16(st+8) {
+					continue
+				}
+				set := st + t.u16(st+10+2*cov)
+				n := t.u16(set)
+				second := int(s.buf[j].index)
+				k := sort.Search(n, func(k int) bool { return t.u16(set+2+k*(2+size)) >= second })
+				if k == n || t.u16(set+2+k*(2+size)) != second {
+					continue
+				}
+				rec = set + 2 + k*(2+size) + 2
+			case 2:
+				c1 := t.class(t.offset(st, st+8), g)
+				c2 := t.class(t.offset(st, st+10), s.buf[j].index)
+				n1, n2 := t.u16(st+12), t.u16(st+14)
+				if c1 >= n1 || c2 >= n2 {
+					continue
+				}
+				rec = st + 16 + (c1*n2+c2)*size
+			default:
+				continue
+			}
+			t.value(rec, vf1, &s.buf[i])
+			t.value(rec+valueSize(vf1), vf2, &s.buf[j])
+			if vf2 != 0 {
+				return j + 1, true
+			}
+			return j, true
+
+		case 4, 5: // mark to base, mark to ligature
+			markCov := t.coverage(t.offset(st, st+2), g)
+			if markCov < 0 {
+				continue
+			}
+			// The base is the previous glyph that is not a mark, whatever
+			// the lookup's flags.
+			j := i - 1
+			for j >= 0 && s.buf[j].class == markGlyph {
+				j--
+			}
+			if j < 0 {
+				continue
+			}
+			baseCov := t.coverage(t.offset(st, st+4), s.buf[j].index)
+			classes := t.u16(st + 6)
+			if baseCov < 0 {
+				continue
+			}
+			markArray := t.offset(st, st+8)
+			class, markAnchor := t.markRecord(markArray, markCov)
+			if class >= classes {
+				continue
+			}
+			array := t.offset(st, st+10)
+			var anchor int
+			if lk.typ == 4 {
+				anchor = t.offset(array, array+2+2*(baseCov*classes+class))
+			} else if attach := t.offset(array, array+2+2*baseCov); attach != 0 {
+				// Marks go on the last component of the ligature.
+				comp := t.u16(attach) - 1
+				anchor = t.offset(attach, attach+2+2*(comp*classes+class))
+			}
+			if anchor == 0 {
+				continue
+			}
+			s.attach(i, j, t, anchor, markAnchor)
+			return i + 1, true
+
+		case 6: // mark to mark
+			cov1 := t.coverage(t.offset(st, st+2), g)
+			if cov1 < 0 {
+				continue
+			}
+			j := s.prev(lk, i)
+			if j < 0 || s.buf[j].class != markGlyph {
+				continue
+			}
+			cov2 := t.coverage(t.offset(st, st+4), s.buf[j].index)
+			classes := t.u16(st + 6)
+			if cov2 < 0 {
+				continue
+			}
+			class, markAnchor := t.markRecord(t.offset(st, st+8), cov1)
+			array := t.offset(st, st+10)
+			if class >= classes {
+				continue
+			}
+			anchor := t.offset(array, array+2+2*(cov2*classes+class))
+			if anchor == 0 {
+				continue
+			}
+			s.attach(i, j, t, anchor, markAnchor)
+			return i + 1, true
+
+		case 7, 8: // context, chained context
+			if j, ok := s.context(t, lk, st, i, s.posNested); ok {
+				return j, true
+			}
+		}
+	}
+	return i, false
+}
+
+// markRecord returns the class and anchor of mark i of the MarkArray at off.
+func (t otTable) markRecord(off, i int) (class, anchor int) {
+	if off == 0 || i >= t.u16(off) {
+		return 0xFFFF, 0
+	}
+	rec := off + 2 + 4*i
+	return t.u16(rec), t.offset(off, rec+2)
+}
+
+// anchor returns the coordinates of the Anchor table at off.
+func (t otTable) anchor(off int) (x, y int) {
+	return t.i16(off + 2), t.i16(off + 4)
+}
+
+// attach places mark buf[i] so that its anchor at markAnchor meets the anchor
+// at baseAnchor of buf[j].
+func (s *shaper) attach(i, j int, t otTable, baseAnchor, markAnchor int) {
+	bx, by := t.anchor(baseAnchor)
+	mx, my := t.anchor(markAnchor)
+	g := &s.buf[i]
+	g.attach = j + 1
+	g.dx, g.dy = bx-mx, by-my
+}
+
+// value adds the ValueRecord at off with format vf to g.
+func (t otTable) value(off, vf int, g *shapeGlyph) {
+	read := func() int {
+		v := t.i16(off)
+		off += 2
+		return v
+	}
+	if vf&1 != 0 {
+		g.dx += read()
+	}
+	if vf&2 != 0 {
+		g.dy += read()
+	}
+	if vf&4 != 0 {
+		g.adv += read()
+	}
+}
+
+// valueSize returns the size of a ValueRecord with format vf.
+func valueSize(vf int) int { return 2 * bits.OnesCount16(uint16(vf)) }
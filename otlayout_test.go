@@ -0,0 +1,91 @@
+package gui
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLookupsShared(t *testing.T) {
+	// A GPOS table whose default script has the features dist, with lookup
+	// 0, and kern, with lookups 0 and 1.
+	var b []byte
+	u16 := func(xs ...int) {
+		for _, x := range xs {
+			b = append(b, byte(x>>8), byte(x))
+		}
+	}
+	u16(1, 0, 10, 32, 0)                                           // header
+	u16(1, 'D'<<8|'F', 'L'<<8|'T', 8)                              // ScriptList
+	u16(4, 0)                                                      // Script
+	u16(0, 0xFFFF, 2, 0, 1)                                        // LangSys
+	u16(2, 'd'<<8|'i', 's'<<8|'t', 14, 'k'<<8|'e', 'r'<<8|'n', 20) // FeatureList
+	u16(0, 1, 0)                                                   // dist
+	u16(0, 2, 0, 1)                                                // kern
+	got := otTable(b).lookups(nil, []string{"kern", "dist"})
+	want := []featureLookup{{0, []string{"dist", "kern"}}, {1, []string{"kern"}}}
+	if !slices.EqualFunc(got, want, func(a, b featureLookup) bool {
+		return a.index == b.index && slices.Equal(a.features, b.features)
+	}) {
+		t.Errorf("lookups = %v; want %v", got, want)
+	}
+}
+
+func TestLigature(t *testing.T) {
+	f := DefaultFont()
+	lam, _ := f.layout("ل")
+	alef, _ := f.layout("ا")
+	g, _ := f.layout("لا")
+	if len(g) != 1 || g[0].index == lam[0].index || g[0].index == alef[0].index {
+		t.Errorf("lam alef shapes to %v; want one ligature glyph", g)
+	}
+}
+
+func TestChainedContextSubstitution(t *testing.T) {
+	f := DefaultFont()
+	i, _ := f.layout("i")
+	dotless, _ := f.layout("i\u0301")
+	if dotless[0].index == i[0].index {
+		t.Fatal("i before an acute is not dotless")
+	}
+	for _, c := range []struct {
+		text string
+		want []glyph
+	}{
+		{"i\u0323", i},             // a mark below keeps the dot
+		{"i\u0323\u0301", dotless}, // the mark below is skipped to find the acute
+	} {
+		if g, _ := f.layout(c.text); g[0].index != c.want[0].index {
+			t.Errorf("%+q shapes i to glyph %d; want %d", c.text, g[0].index, c.want[0].index)
+		}
+	}
+}
+
+func TestPairPositioning(t *testing.T) {
+	f := DefaultFont()
+	_, a := f.layout("A")
+	_, v := f.layout("V")
+	if _, av := f.layout("AV"); av >= a+v {
+		t.Errorf("AV advances %v; want less than %v", av, a+v)
+	}
+	if _, sp := f.layout("A V"); sp <= a+v {
+		t.Errorf("A V advances %v; want more than %v", sp, a+v)
+	}
+}
+
+func TestMarkAttachment(t *testing.T) {
+	f := DefaultFont()
+	for _, text := range []string{"a\u0301", "\u0628\u064E"} {
+		g, adv := f.layout(text)
+		if len(g) != 2 {
+			t.Errorf("%+q shapes to %d glyphs; want 2", text, len(g))
+			continue
+		}
+		base, mark := g[0], g[1]
+		if base.rtl {
+			base, mark = mark, base
+		}
+		if mark.adv != 0 || mark.x <= base.x || mark.x >= base.x+base.adv || adv != base.adv {
+			t.Errorf("%+q: mark %+v is not attached to base %+v", text, mark, base)
+		}
+	}
+}
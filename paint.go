@@ -0,0 +1,263 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// A Paint determines the color of each point covered by a fill.  It is one of
+// *LinearGradient, *RadialGradient, *ConicGradient or *ImagePattern.  A Paint's
+// coordinates are those of the fill it is used with, so a gradient drawn in a
+// View's Paint method is positioned in the View's inner coordinates.
+type Paint interface {
+	// colorAt returns the paint's color at p.
+	colorAt(p Point) Color
+}
+
+// SetPaint sets the paint used by FillRect, FillPolygon, FillPath and
+// StrokePath, replacing the current color for them until the next call to
+// SetPaint or SetColor.  Points, lines, Bézier curves and text are still drawn
+// in the current color.  A nil Paint fills with the current color.
+func SetPaint(p Paint) {
+	r := renderer()
+	r.SetPaint(fade(p, painting.alpha))
+}
+
+// fade returns p with its alpha multiplied by alpha.  Gradients are copied
+// with faded stops, so that Renderers can still recognize them.
+func fade(p Paint, alpha float64) Paint {
+	if p == nil || alpha == 1 {
+		return p
+	}
+	switch p := p.(type) {
+	case *LinearGradient:
+		g := *p
+		g.Stops = fadeStops(p.Stops, alpha)
+		return &g
+	case *RadialGradient:
+		g := *p
+		g.Stops = fadeStops(p.Stops, alpha)
+		return &g
+	case *ConicGradient:
+		g := *p
+		g.Stops = fadeStops(p.Stops, alpha)
+		return &g
+	}
+	return fadedPaint{p, alpha}
+}
+
+func fadeStops(stops []ColorStop, alpha float64) []ColorStop {
+	faded := make([]ColorStop, len(stops))
+	for i, s := range stops {
+		s.Color.A *= alpha
+		faded[i] = s
+	}
+	return faded
+}
+
+// A fadedPaint is a Paint with its alpha multiplied by alpha.
+type fadedPaint struct {
+	p     Paint
+	alpha float64
+}
+
+func (p fadedPaint) colorAt(q Point) Color {
+	c := p.p.colorAt(q)
+	c.A *= p.alpha
+	return c
+}
+
+// A ColorStop is a color at an offset along a gradient, from 0 at its start
+// to 1 at its end.  A gradient's stops must be in order of increasing offset.
+type ColorStop struct {
+	Offset float64
+	Color  Color
+}
+
+// A Spread says how a paint extends beyond its start and end.
+type Spread int
+
+const (
+	// Pad extends a paint's edge colors.
+	Pad Spread = iota
+	// Repeat repeats a paint.
+	Repeat
+	// Reflect repeats a paint, mirroring every other repetition.
+	Reflect
+)
+
+// apply maps t to the range from 0 to 1.
+func (s Spread) apply(t float64) float64 {
+	switch s {
+	case Repeat:
+		return t - math.Floor(t)
+	case Reflect:
+		t = math.Mod(math.Abs(t), 2)
+		if t > 1 {
+			t = 2 - t
+		}
+		return t
+	}
+	return math.Max(0, math.Min(1, t))
+}
+
+// gradientColor returns the color at offset t among stops, which are sorted
+// by offset.  Colors are interpolated with premultiplied alpha, so that fading
+// to a transparent stop does not darken.
+func gradientColor(stops []ColorStop, t float64) Color {
+	switch {
+	case len(stops) == 0:
+		return Color{}
+	case t <= stops[0].Offset:
+		return stops[0].Color
+	case t >= stops[len(stops)-1].Offset:
+		return stops[len(stops)-1].Color
+	}
+	i := 1
+	for stops[i].Offset < t {
+		i++
+	}
+	s0, s1 := stops[i-1], stops[i]
+	if s1.Offset <= s0.Offset {
+		return s1.Color
+	}
+	k := (t - s0.Offset) / (s1.Offset - s0.Offset)
+	a0, a1 := s0.Color.A, s1.Color.A
+	a := a0 + k*(a1-a0)
+	if a == 0 {
+		return Color{}
+	}
+	mix := func(x0, x1 float64) float64 { return (x0*a0 + k*(x1*a1-x0*a0)) / a }
+	return Color{mix(s0.Color.R, s1.Color.R), mix(s0.Color.G, s1.Color.G), mix(s0.Color.B, s1.Color.B), a}
+}
+
+// A LinearGradient varies in color along the line from Start to End and is
+// constant perpendicular to it.
+type LinearGradient struct {
+	Start, End Point
+	Stops      []ColorStop
+	Spread     Spread
+}
+
+func (g *LinearGradient) colorAt(p Point) Color {
+	d := g.End.Sub(g.Start)
+	t := 0.0
+	if n := d.Dot(d); n > 0 {
+		t = p.Sub(g.Start).Dot(d) / n
+	}
+	return gradientColor(g.Stops, g.Spread.apply(t))
+}
+
+// A RadialGradient varies in color along rays from its focal point, from
+// offset 0 at the focal point to offset 1 on the circle of Radius around
+// Center.
+type RadialGradient struct {
+	Center Point
+	Radius float64
+	// Focus is the offset of the focal point from Center.  It is moved just
+	// inside the circle if it is outside.
+	Focus  Point
+	Stops  []ColorStop
+	Spread Spread
+}
+
+func (g *RadialGradient) colorAt(p Point) Color {
+	if g.Radius <= 0 {
+		return gradientColor(g.Stops, 1)
+	}
+	f := g.focus()
+	// Find t such that p is on the circle of radius t*Radius centered on the
+	// point t of the way from the focal point to Center.
+	q, d := p.Sub(g.Center.Add(f)), f.Mul(-1)
+	a := d.Dot(d) - g.Radius*g.Radius
+	b := q.Dot(d)
+	t := (b - math.Sqrt(math.Max(0, b*b-a*q.Dot(q)))) / a
+	return gradientColor(g.Stops, g.Spread.apply(t))
+}
+
+// focus returns the offset of the focal point from Center, moved inside the
+// circle if necessary.
+func (g *RadialGradient) focus() Point {
+	if l := g.Focus.Len(); l > .99*g.Radius {
+		return g.Focus.Mul(.99 * g.Radius / l)
+	}
+	return g.Focus
+}
+
+// A ConicGradient varies in color counterclockwise around Center, from offset
+// 0 at Angle radians to offset 1 a full turn later.
+type ConicGradient struct {
+	Center Point
+	Angle  float64
+	Stops  []ColorStop
+}
+
+func (g *ConicGradient) colorAt(p Point) Color {
+	t := (p.Sub(g.Center).Angle() - g.Angle) / (2 * math.Pi)
+	return gradientColor(g.Stops, t-math.Floor(t))
+}
+
+// An ImagePattern paints an image stretched over Rect, its first row at the
+// top of Rect (Rect.Max.Y).  If Rect is empty, the image is drawn one unit
+// per pixel with its bottom-left corner at the origin.  Beyond Rect the image
+// extends according to Spread.  Pixels are interpolated bilinearly.
+type ImagePattern struct {
+	Image  image.Image
+	Rect   Rectangle
+	Spread Spread
+}
+
+func (g *ImagePattern) colorAt(p Point) Color {
+	b := g.Image.Bounds()
+	rect := g.Rect
+	if rect.Empty() {
+		rect = Rectangle{ZP, Pt(float64(b.Dx()), float64(b.Dy()))}
+	}
+	x := (p.X - rect.Min.X) / rect.Dx() * float64(b.Dx())
+	y := (rect.Max.Y - p.Y) / rect.Dy() * float64(b.Dy())
+	return sampleImage(g.Image, x, y, Linear, g.texel)
+}
+
+// texel maps pixel index i to the range [0, n) according to g.Spread.
+func (g *ImagePattern) texel(i, n int) int {
+	switch g.Spread {
+	case Repeat:
+		return (i%n + n) % n
+	case Reflect:
+		i = (i%(2*n) + 2*n) % (2 * n)
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+		return i
+	}
+	return clampTexel(i, n)
+}
+
+// rasterizePaint returns an image of p over the pixels b, where toLocal maps
+// pixel coordinates to p's coordinates.  Each pixel is sampled at its center.
+func rasterizePaint(p Paint, toLocal Affine2D, b image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := p.colorAt(toLocal.Apply(Pt(float64(x)+.5, float64(y)+.5)))
+			img.SetNRGBA(x, y, color.NRGBA{unitUint8(c.R), unitUint8(c.G), unitUint8(c.B), unitUint8(c.A)})
+		}
+	}
+	return img
+}
+
+// paintMask returns an image of p over mask's bounds with its alpha scaled by
+// mask, for Renderers to composite a paint through a coverage mask.
+func paintMask(p Paint, toLocal Affine2D, mask *image.Alpha) *image.NRGBA {
+	img := rasterizePaint(p, toLocal, mask.Rect)
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			i := img.PixOffset(x, y) + 3
+			img.Pix[i] = uint8((uint32(img.Pix[i])*uint32(mask.AlphaAt(x, y).A) + 127) / 255)
+		}
+	}
+	return img
+}
+
+func unitUint8(x float64) uint8 { return uint8(math.Max(0, math.Min(1, x))*0xff + .5) }
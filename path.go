@@ -0,0 +1,453 @@
+package gui
+
+import (
+	"math"
+	"slices"
+)
+
+// A Path is a shape made of subpaths, each a sequence of lines and curves
+// starting at a point set by MoveTo.  The zero Path is empty and ready to use.
+type Path struct {
+	cmds  []pathCmd
+	start Point // of the current subpath
+	cur   Point
+	open  bool // whether there is a current subpath
+}
+
+type pathOp int
+
+const (
+	moveTo pathOp = iota
+	lineTo
+	quadTo
+	cubeTo
+	closePath
+)
+
+type pathCmd struct {
+	op  pathOp
+	pts [3]Point
+}
+
+// MoveTo starts a new subpath at p.
+func (p *Path) MoveTo(pt Point) {
+	p.cmds = append(p.cmds, pathCmd{op: moveTo, pts: [3]Point{pt}})
+	p.start, p.cur, p.open = pt, pt, true
+}
+
+// LineTo adds a line from the current point to pt.  Without a current
+// subpath, it behaves like MoveTo.
+func (p *Path) LineTo(pt Point) {
+	if !p.open {
+		p.MoveTo(pt)
+		return
+	}
+	p.cmds = append(p.cmds, pathCmd{op: lineTo, pts: [3]Point{pt}})
+	p.cur = pt
+}
+
+// QuadTo adds a quadratic Bézier curve from the current point to pt with
+// control point c.
+func (p *Path) QuadTo(c, pt Point) {
+	if !p.open {
+		p.MoveTo(c)
+	}
+	p.cmds = append(p.cmds, pathCmd{op: quadTo, pts: [3]Point{c, pt}})
+	p.cur = pt
+}
+
+// CubicTo adds a cubic Bézier curve from the current point to pt with control
+// points c1 and c2.
+func (p *Path) CubicTo(c1, c2, pt Point) {
+	if !p.open {
+		p.MoveTo(c1)
+	}
+	p.cmds = append(p.cmds, pathCmd{op: cubeTo, pts: [3]Point{c1, c2, pt}})
+	p.cur = pt
+}
+
+// ArcTo adds a circular arc around center, from the current point through
+// angle radians, counterclockwise for positive angles.
+func (p *Path) ArcTo(center Point, angle float64) {
+	if !p.open {
+		return
+	}
+	v := p.cur.Sub(center)
+	// Each piece of at most a quarter turn is approximated by a cubic.
+	n := int(math.Ceil(math.Abs(angle) / (math.Pi / 2)))
+	da := angle / float64(n)
+	k := 4 / 3. * math.Tan(da/4)
+	for i := 0; i < n; i++ {
+		sin, cos := math.Sincos(da)
+		w := Pt(v.X*cos-v.Y*sin, v.X*sin+v.Y*cos)
+		c1 := center.Add(v).Add(Pt(-v.Y, v.X).Mul(k))
+		c2 := center.Add(w).Add(Pt(w.Y, -w.X).Mul(k))
+		p.CubicTo(c1, c2, center.Add(w))
+		v = w
+	}
+}
+
+// Close adds a line back to the start of the current subpath and ends it.
+func (p *Path) Close() {
+	if !p.open {
+		return
+	}
+	p.cmds = append(p.cmds, pathCmd{op: closePath})
+	p.cur, p.open = p.start, false
+}
+
+// Empty returns whether p has no subpaths.
+func (p *Path) Empty() bool { return len(p.cmds) == 0 }
+
+// trace traces p, transformed by m, to s.  Open subpaths are closed, as for
+// filling.
+func (p *Path) trace(m Affine2D, s pathSink) {
+	for _, c := range p.cmds {
+		switch c.op {
+		case moveTo:
+			s.moveTo(m.Apply(c.pts[0]))
+		case lineTo:
+			s.lineTo(m.Apply(c.pts[0]))
+		case quadTo:
+			s.quadTo(m.Apply(c.pts[0]), m.Apply(c.pts[1]))
+		case cubeTo:
+			s.cubeTo(m.Apply(c.pts[0]), m.Apply(c.pts[1]), m.Apply(c.pts[2]))
+		}
+	}
+}
+
+// A polyline is a flattened subpath.
+type polyline struct {
+	pts    []Point
+	closed bool
+}
+
+// flattenTolerance is the greatest distance, in the Path's coordinates, that
+// flattened curves stray from the true curves.
+const flattenTolerance = .1
+
+// flatten approximates p's curves with lines.
+func (p *Path) flatten() []polyline {
+	var lines []polyline
+	var cur []Point
+	end := func(closed bool) {
+		if len(cur) > 0 {
+			lines = append(lines, polyline{cur, closed})
+		}
+		cur = nil
+	}
+	for _, c := range p.cmds {
+		switch c.op {
+		case moveTo:
+			end(false)
+			cur = []Point{c.pts[0]}
+		case lineTo:
+			cur = append(cur, c.pts[0])
+		case quadTo:
+			cur = flattenBezier(cur, []Point{cur[len(cur)-1], c.pts[0], c.pts[1]})
+		case cubeTo:
+			cur = flattenBezier(cur, []Point{cur[len(cur)-1], c.pts[0], c.pts[1], c.pts[2]})
+		case closePath:
+			end(true)
+		}
+	}
+	end(false)
+	return lines
+}
+
+// flattenBezier appends to pts the points after the first of a polyline
+// approximating the Bézier curve with control points ctrl.
+func flattenBezier(pts, ctrl []Point) []Point {
+	return append(pts, Bezier(ctrl).Flatten(flattenTolerance)[1:]...)
+}
+
+// A Join is the shape drawn where two segments of a stroke meet.
+type Join int
+
+const (
+	MiterJoin Join = iota
+	RoundJoin
+	BevelJoin
+)
+
+// A Cap is the shape drawn at the ends of an open subpath or dash.
+type Cap int
+
+const (
+	ButtCap Cap = iota
+	RoundCap
+	SquareCap
+)
+
+// A Stroke describes how to draw the outline of a Path.  Unlike SetLineWidth,
+// its width is in the Path's coordinates, so it scales with the transform.
+type Stroke struct {
+	Width float64 // zero means 1
+	Join  Join
+	// MiterLimit is the longest a miter may be, as a multiple of Width,
+	// before it is drawn as a bevel instead.  Zero means 4.
+	MiterLimit float64
+	Cap        Cap
+	// Dashes are the lengths of alternating dashes and gaps.  If there are an
+	// odd number, they are repeated to make an even number.  Without dashes,
+	// the stroke is solid.
+	Dashes []float64
+	// DashOffset is how far into the dash pattern the stroke starts.
+	DashOffset float64
+}
+
+// StrokePath draws the outline of p as described by s with the current color.
+func StrokePath(p *Path, s Stroke) {
+	FillPath(s.outline(p), NonZero)
+}
+
+// FillPath fills p with the current color, using rule to decide which parts
+// are inside it.  Open subpaths are closed.
+func FillPath(p *Path, rule FillRule) { renderer().FillPath(p, rule) }
+
+// outline returns a Path that, filled with the NonZero rule, covers the
+// stroke of p.
+func (s Stroke) outline(p *Path) *Path {
+	o := &Path{}
+	for _, poly := range s.polygons(p) {
+		// Counterclockwise pieces all wind positively, so where they overlap
+		// they are filled once rather than cancelling out.
+		if polygonArea(poly) < 0 {
+			slices.Reverse(poly)
+		}
+		o.addPolygon(poly)
+	}
+	return o
+}
+
+// polygonPath returns a Path around the polygon pts.
+func polygonPath(pts []Point) *Path {
+	p := &Path{}
+	p.addPolygon(pts)
+	return p
+}
+
+func (p *Path) addPolygon(pts []Point) {
+	for i, q := range pts {
+		if i == 0 {
+			p.MoveTo(q)
+		} else {
+			p.LineTo(q)
+		}
+	}
+	p.Close()
+}
+
+// polygonArea returns the area of the polygon pts, which is positive if it
+// runs counterclockwise.
+func polygonArea(pts []Point) float64 {
+	a := 0.0
+	for i, p := range pts {
+		a += p.Cross(pts[(i+1)%len(pts)])
+	}
+	return a / 2
+}
+
+// polygons returns convex polygons that together cover the stroke of p.
+func (s Stroke) polygons(p *Path) [][]Point {
+	w := s.Width
+	if w == 0 {
+		w = 1
+	}
+	t := stroker{w: w / 2, join: s.Join, cap: s.Cap, miterLimit: s.MiterLimit}
+	if t.miterLimit == 0 {
+		t.miterLimit = 4
+	}
+	for _, l := range p.flatten() {
+		for _, l := range s.dash(l) {
+			t.stroke(l)
+		}
+	}
+	return t.polys
+}
+
+// dash splits l into dashes.
+func (s Stroke) dash(l polyline) []polyline {
+	pattern := s.Dashes
+	if len(pattern)%2 == 1 {
+		pattern = append(pattern[:len(pattern):len(pattern)], pattern...)
+	}
+	total := 0.0
+	for _, d := range pattern {
+		if d < 0 {
+			return []polyline{l}
+		}
+		total += d
+	}
+	if total == 0 {
+		return []polyline{l}
+	}
+	pts := l.pts
+	if l.closed && len(pts) > 0 {
+		pts = append(pts[:len(pts):len(pts)], pts[0])
+	}
+
+	// Find where in the pattern the stroke starts.  A dash of zero length
+	// there is kept, to show its caps.
+	i := 0
+	left := pattern[0] - math.Mod(math.Mod(s.DashOffset, total)+total, total)
+	for left < 0 || left == 0 && pattern[i] > 0 {
+		i = (i + 1) % len(pattern)
+		left += pattern[i]
+	}
+	startOn := i%2 == 0
+
+	var dashes []polyline
+	var cur []Point
+	if i%2 == 0 && len(pts) > 0 {
+		cur = []Point{pts[0]}
+	}
+	for j := 1; j < len(pts); j++ {
+		p, q := pts[j-1], pts[j]
+		segLen := q.Sub(p).Len()
+		pos := 0.0
+		for segLen-pos > left {
+			pos += left
+			x := p.Add(q.Sub(p).Mul(pos / segLen))
+			if i%2 == 0 {
+				dashes = append(dashes, polyline{pts: append(cur, x)})
+				cur = nil
+			} else {
+				cur = []Point{x}
+			}
+			i = (i + 1) % len(pattern)
+			left = pattern[i]
+		}
+		left -= segLen - pos
+		if i%2 == 0 {
+			cur = append(cur, q)
+		}
+	}
+	if len(cur) > 1 {
+		switch {
+		case !l.closed || !startOn:
+			dashes = append(dashes, polyline{pts: cur})
+		case len(dashes) == 0:
+			return []polyline{l}
+		default:
+			// The dash through the start of a closed polyline is one dash,
+			// joined where it turns the corner there.
+			dashes[0].pts = append(cur, dashes[0].pts[1:]...)
+		}
+	}
+	return dashes
+}
+
+// A stroker collects the convex polygons covering strokes of polylines.
+type stroker struct {
+	w          float64 // half the stroke width
+	join       Join
+	cap        Cap
+	miterLimit float64
+	polys      [][]Point
+}
+
+func (t *stroker) stroke(l polyline) {
+	pts := make([]Point, 0, len(l.pts))
+	for _, p := range l.pts {
+		if len(pts) == 0 || !p.Eq(pts[len(pts)-1]) {
+			pts = append(pts, p)
+		}
+	}
+	if l.closed && len(pts) > 1 && pts[0].Eq(pts[len(pts)-1]) {
+		pts = pts[:len(pts)-1]
+	}
+	switch len(pts) {
+	case 0:
+		return
+	case 1:
+		// A zero-length subpath shows its caps.
+		p := pts[0]
+		switch t.cap {
+		case RoundCap:
+			t.polys = append(t.polys, t.arc(p, Pt(t.w, 0), 2*math.Pi))
+		case SquareCap:
+			t.polys = append(t.polys, []Point{p.Add(Pt(-t.w, -t.w)), p.Add(Pt(t.w, -t.w)), p.Add(Pt(t.w, t.w)), p.Add(Pt(-t.w, t.w))})
+		}
+		return
+	}
+	closed := l.closed && len(pts) > 2
+
+	n := len(pts) - 1
+	if closed {
+		n = len(pts)
+	}
+	for i := 0; i < n; i++ {
+		p, q := pts[i], pts[(i+1)%len(pts)]
+		o := t.normal(p, q)
+		t.polys = append(t.polys, []Point{p.Sub(o), q.Sub(o), q.Add(o), p.Add(o)})
+	}
+	for i := 0; i < len(pts); i++ {
+		if !closed && (i == 0 || i == len(pts)-1) {
+			continue
+		}
+		prev, next := pts[(i+len(pts)-1)%len(pts)], pts[(i+1)%len(pts)]
+		t.joinAt(prev, pts[i], next)
+	}
+	if !closed {
+		t.capAt(pts[1], pts[0])
+		t.capAt(pts[len(pts)-2], pts[len(pts)-1])
+	}
+}
+
+// normal returns the left-hand normal of the segment pq, of length w.
+func (t *stroker) normal(p, q Point) Point {
+	d := q.Sub(p)
+	return Pt(-d.Y, d.X).Mul(t.w / d.Len())
+}
+
+// joinAt adds the join at v between segments from p and to q.
+func (t *stroker) joinAt(p, v, q Point) {
+	n0, n1 := t.normal(p, v), t.normal(v, q)
+	cross := v.Sub(p).Cross(q.Sub(v))
+	if math.Abs(cross) < 1e-9*t.w*t.w && n0.Dot(n1) > 0 {
+		return
+	}
+	// The join fills the gap on the outside of the turn.
+	o0, o1 := n0, n1
+	if cross > 0 {
+		o0, o1 = n0.Mul(-1), n1.Mul(-1)
+	}
+	switch t.join {
+	case MiterJoin:
+		h := o0.Add(o1).Mul(.5)
+		if hl := h.Len(); hl > 0 && t.w/hl <= t.miterLimit {
+			t.polys = append(t.polys, []Point{v, v.Add(o0), v.Add(h.Mul(t.w * t.w / (hl * hl))), v.Add(o1)})
+			return
+		}
+	case RoundJoin:
+		a := math.Atan2(o0.Cross(o1), o0.Dot(o1))
+		t.polys = append(t.polys, append([]Point{v}, t.arc(v, o0, a)...))
+		return
+	}
+	t.polys = append(t.polys, []Point{v, v.Add(o0), v.Add(o1)})
+}
+
+// capAt adds the cap at the end v of the segment from p.
+func (t *stroker) capAt(p, v Point) {
+	o := t.normal(p, v)
+	d := Pt(o.Y, -o.X) // along the segment
+	switch t.cap {
+	case RoundCap:
+		t.polys = append(t.polys, t.arc(v, o.Mul(-1), math.Pi))
+	case SquareCap:
+		t.polys = append(t.polys, []Point{v.Sub(o), v.Sub(o).Add(d), v.Add(o).Add(d), v.Add(o)})
+	}
+}
+
+// arc returns points on the circle around c from c+v through angle radians.
+func (t *stroker) arc(c, v Point, angle float64) []Point {
+	step := 2 * math.Acos(math.Max(-1, 1-flattenTolerance/t.w))
+	n := max(1, int(math.Ceil(math.Abs(angle)/step)))
+	pts := make([]Point, n+1)
+	for i := range pts {
+		sin, cos := math.Sincos(angle * float64(i) / float64(n))
+		pts[i] = c.Add(Pt(v.X*cos-v.Y*sin, v.X*sin+v.Y*cos))
+	}
+	return pts
+}
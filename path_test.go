@@ -0,0 +1,132 @@
+package gui
+
+import (
+	"testing"
+)
+
+func TestStrokeDash(t *testing.T) {
+	line := polyline{pts: []Point{Pt(0, 0), Pt(10, 0)}}
+	square := polyline{pts: []Point{Pt(0, 0), Pt(10, 0), Pt(10, 10), Pt(0, 10)}, closed: true}
+	for _, c := range []struct {
+		name   string
+		l      polyline
+		dashes []float64
+		offset float64
+		want   [][]Point
+	}{
+		{"solid", line, nil, 0, [][]Point{{Pt(0, 0), Pt(10, 0)}}},
+		{"dashed", line, []float64{3, 2}, 0, [][]Point{{Pt(0, 0), Pt(3, 0)}, {Pt(5, 0), Pt(8, 0)}}},
+		{"odd", line, []float64{2}, 0, [][]Point{{Pt(0, 0), Pt(2, 0)}, {Pt(4, 0), Pt(6, 0)}, {Pt(8, 0), Pt(10, 0)}}},
+		{"offset in dash", line, []float64{3, 2}, 1, [][]Point{{Pt(0, 0), Pt(2, 0)}, {Pt(4, 0), Pt(7, 0)}, {Pt(9, 0), Pt(10, 0)}}},
+		{"offset in gap", line, []float64{3, 2}, 4, [][]Point{{Pt(1, 0), Pt(4, 0)}, {Pt(6, 0), Pt(9, 0)}}},
+		{"offset past pattern", line, []float64{3, 2}, 9, [][]Point{{Pt(1, 0), Pt(4, 0)}, {Pt(6, 0), Pt(9, 0)}}},
+		{"offset at end of dash", line, []float64{3, 2}, 3, [][]Point{{Pt(2, 0), Pt(5, 0)}, {Pt(7, 0), Pt(10, 0)}}},
+		{"zero-length dashes", line, []float64{0, 4}, 0, [][]Point{{Pt(0, 0), Pt(0, 0)}, {Pt(4, 0), Pt(4, 0)}, {Pt(8, 0), Pt(8, 0)}}},
+		{"negative offset", line, []float64{3, 2}, -1, [][]Point{{Pt(1, 0), Pt(4, 0)}, {Pt(6, 0), Pt(9, 0)}}},
+		{"around corner", polyline{pts: []Point{Pt(0, 0), Pt(4, 0), Pt(4, 4)}}, []float64{6, 10}, 0, [][]Point{{Pt(0, 0), Pt(4, 0), Pt(4, 2)}}},
+		// The dash that runs through the start of a closed polyline is a
+		// single dash turning the corner there.
+		{"across close", square, []float64{6, 4}, 3, [][]Point{
+			{Pt(0, 3), Pt(0, 0), Pt(3, 0)},
+			{Pt(7, 0), Pt(10, 0), Pt(10, 3)},
+			{Pt(10, 7), Pt(10, 10), Pt(7, 10)},
+			{Pt(3, 10), Pt(0, 10), Pt(0, 7)},
+		}},
+		{"closed ending in gap", square, []float64{6, 4}, 0, [][]Point{
+			{Pt(0, 0), Pt(6, 0)},
+			{Pt(10, 0), Pt(10, 6)},
+			{Pt(10, 10), Pt(4, 10)},
+			{Pt(0, 10), Pt(0, 4)},
+		}},
+	} {
+		got := Stroke{Dashes: c.dashes, DashOffset: c.offset}.dash(c.l)
+		ok := len(got) == len(c.want)
+		for i := 0; ok && i < len(got); i++ {
+			ok = len(got[i].pts) == len(c.want[i]) && !got[i].closed
+			for j := 0; ok && j < len(got[i].pts); j++ {
+				ok = nearPt(got[i].pts[j], c.want[i][j])
+			}
+		}
+		if c.dashes == nil {
+			ok = len(got) == 1 && len(got[0].pts) == 2
+		}
+		if !ok {
+			t.Errorf("%s: got %v; want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// covered returns whether any of the convex polygons polys contains p.
+func covered(polys [][]Point, p Point) bool {
+	for _, poly := range polys {
+		sign := 0.0
+		in := true
+		for i, a := range poly {
+			x := poly[(i+1)%len(poly)].Sub(a).Cross(p.Sub(a))
+			if x*sign < 0 {
+				in = false
+				break
+			}
+			if x != 0 {
+				sign = x
+			}
+		}
+		if in {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStrokeJoinsAndCaps(t *testing.T) {
+	var corner, spike, line, dot, square Path
+	corner.MoveTo(Pt(0, 0))
+	corner.LineTo(Pt(10, 0))
+	corner.LineTo(Pt(10, 10))
+	spike.MoveTo(Pt(0, 0))
+	spike.LineTo(Pt(10, 0))
+	spike.LineTo(Pt(0, 1))
+	line.MoveTo(Pt(0, 0))
+	line.LineTo(Pt(10, 0))
+	dot.MoveTo(Pt(5, 5))
+	dot.LineTo(Pt(5, 5))
+	square.MoveTo(Pt(0, 0))
+	square.LineTo(Pt(10, 0))
+	square.LineTo(Pt(10, 10))
+	square.LineTo(Pt(0, 10))
+	square.Close()
+
+	for _, c := range []struct {
+		name string
+		p    *Path
+		s    Stroke
+		in   []Point
+		out  []Point
+	}{
+		{"miter", &corner, Stroke{Width: 2}, []Point{Pt(10.9, -.9), Pt(10.4, -.4), Pt(5, .9), Pt(9.1, 5)}, []Point{Pt(11.1, -.9), Pt(5, 1.1)}},
+		{"bevel", &corner, Stroke{Width: 2, Join: BevelJoin}, []Point{Pt(10.4, -.4)}, []Point{Pt(10.9, -.9), Pt(10.6, -.6)}},
+		{"round", &corner, Stroke{Width: 2, Join: RoundJoin}, []Point{Pt(10.6, -.6)}, []Point{Pt(10.9, -.9)}},
+		{"miter limit", &spike, Stroke{Width: 2}, []Point{Pt(10.01, -.5)}, []Point{Pt(20, -.5), Pt(11, -.5)}},
+		{"long miter", &spike, Stroke{Width: 2, MiterLimit: 100}, []Point{Pt(20, -.5), Pt(29, -.9)}, []Point{Pt(31, -1)}},
+		{"butt cap", &line, Stroke{Width: 2}, []Point{Pt(.1, .9), Pt(9.9, -.9)}, []Point{Pt(-.1, 0), Pt(10.1, 0)}},
+		{"square cap", &line, Stroke{Width: 2, Cap: SquareCap}, []Point{Pt(-.9, .9), Pt(10.9, -.9)}, []Point{Pt(-1.1, 0), Pt(11.1, 0)}},
+		{"round cap", &line, Stroke{Width: 2, Cap: RoundCap}, []Point{Pt(-.9, 0), Pt(10.6, .6)}, []Point{Pt(-.9, .9), Pt(10.9, -.9)}},
+		{"zero length", &dot, Stroke{Width: 2, Cap: RoundCap}, []Point{Pt(5.6, 5.6), Pt(4.1, 5)}, []Point{Pt(5.9, 5.9)}},
+		{"zero length butt", &dot, Stroke{Width: 2}, nil, []Point{Pt(5, 5)}},
+		{"closed", &square, Stroke{Width: 2}, []Point{Pt(-.9, -.9), Pt(10.9, 10.9)}, []Point{Pt(5, 5), Pt(-1.1, 5)}},
+		{"dash across close", &square, Stroke{Width: 2, Dashes: []float64{6, 4}, DashOffset: 3}, []Point{Pt(-.9, -.9), Pt(2.9, 0)}, []Point{Pt(5, 0), Pt(5, 10)}},
+		{"round dots", &line, Stroke{Width: 2, Cap: RoundCap, Dashes: []float64{0, 5}}, []Point{Pt(0, .9), Pt(-.9, 0), Pt(5.9, 0)}, []Point{Pt(2.5, 0)}},
+	} {
+		polys := c.s.polygons(c.p)
+		for _, p := range c.in {
+			if !covered(polys, p) {
+				t.Errorf("%s: %v is not covered", c.name, p)
+			}
+		}
+		for _, p := range c.out {
+			if covered(polys, p) {
+				t.Errorf("%s: %v is covered", c.name, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,747 @@
+package gui
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// A PDFRenderer is a Renderer that writes a vector PDF document, one page per
+// frame.  Text is drawn as shaped glyphs of embedded TrueType fonts, with
+// its characters recorded so that it can be searched and copied.
+// Fills with a Paint are drawn as images clipped to the filled shape.
+type PDFRenderer struct {
+	w   io.Writer
+	err error
+
+	pages    []pdfPage
+	content  *bytes.Buffer
+	page     Point
+	dev      Affine2D // window to page coordinates
+	m        Affine2D
+	stack    []Affine2D
+	area     Rectangle // the printable area of the page
+	clipOpen bool
+
+	color     Color
+	paint     Paint
+	pointSize float64
+	lineWidth float64
+	set       struct {
+		fill, stroke bool
+		alpha        float64
+		width        float64
+	}
+
+	alphas map[float64]string
+	fonts  []*pdfFont // one per typeface, in order of first use
+	images []pdfImage
+}
+
+// A pdfFont is a typeface used in a document, with the glyphs drawn in it.
+type pdfFont struct {
+	f      *Font
+	widths map[sfnt.GlyphIndex]float64 // in thousandths of an em
+	text   map[sfnt.GlyphIndex]string  // the characters each glyph shows
+}
+
+type pdfImage struct {
+	img         *image.NRGBA
+	interpolate bool
+}
+
+type pdfPage struct {
+	size    Point
+	content []byte
+}
+
+// PageOptions control how WritePDF lays out a View on pages.
+type PageOptions struct {
+	// Size is the size of each page in points (1/72 inch).  Zero means US
+	// Letter.
+	Size Point
+	// Margin is the blank space in points on each side of each page.
+	Margin float64
+	// Scale is the size in points of a unit of the View.  Zero means 1.
+	Scale float64
+}
+
+func NewPDFRenderer(w io.Writer) *PDFRenderer {
+	return &PDFRenderer{w: w, alphas: map[float64]string{}}
+}
+
+// WritePDF writes a PDF document depicting v and its descendants to w.  If v
+// does not fit within the margins of a single page, it is split across as many
+// pages as needed, ordered left to right and then top to bottom.  Like other
+// operations on a View tree, it must be called from the goroutine of the Window
+// holding v, if any.
+func WritePDF(w io.Writer, v View, opts PageOptions) error {
+	page, margin, scale := opts.Size, opts.Margin, opts.Scale
+	if page == ZP {
+		page = Pt(612, 792)
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	area := Rectangle{Pt(margin, margin), page.Sub(Pt(margin, margin))}
+	if area.Empty() {
+		return fmt.Errorf("gui: margin %v too large for page size %v", margin, page)
+	}
+	size := v.base().size.Mul(scale)
+	cols := math.Max(1, math.Ceil(size.X/area.Dx()-1e-9))
+	rows := math.Max(1, math.Ceil(size.Y/area.Dy()-1e-9))
+
+	r := NewPDFRenderer(w)
+	for row := 0.0; row < rows; row++ {
+		for col := 0.0; col < cols; col++ {
+			offset := Pt(margin-col*area.Dx(), margin-size.Y+(row+1)*area.Dy())
+			r.beginPage(page, Affine2D{scale, 0, 0, scale, offset.X, offset.Y}, area)
+			Render(r, v)
+			r.End()
+		}
+	}
+	return r.Close()
+}
+
+// Begin starts a page of the given size in points, showing window coordinates
+// unscaled.
+func (r *PDFRenderer) Begin(size, bufSize Point) {
+	r.beginPage(size, Identity2D, Rectangle{ZP, size})
+}
+
+func (r *PDFRenderer) beginPage(size Point, dev Affine2D, area Rectangle) {
+	r.content = &bytes.Buffer{}
+	r.page = size
+	r.dev = dev
+	r.m = dev
+	r.stack = r.stack[:0]
+	r.area = area
+	r.clipOpen = false
+	r.color = Color{1, 1, 1, 1}
+	r.paint = nil
+	r.pointSize = 1
+	r.lineWidth = 1
+	r.resetState()
+	r.printf("q %s %s %s %s re W n\n", pdfNum(area.Min.X), pdfNum(area.Min.Y), pdfNum(area.Dx()), pdfNum(area.Dy()))
+}
+
+// BeginPartial always fails, since each frame is a separate page.
+func (r *PDFRenderer) BeginPartial(size, bufSize Point, damage Rectangle) bool { return false }
+
+// End finishes the current page.
+func (r *PDFRenderer) End() {
+	if r.clipOpen {
+		r.printf("Q\n")
+	}
+	r.printf("Q\n")
+	r.pages = append(r.pages, pdfPage{r.page, r.content.Bytes()})
+	r.content = nil
+}
+
+// Close writes the document containing the finished pages and returns the
+// first error encountered, if any.
+func (r *PDFRenderer) Close() error {
+	if r.err != nil {
+		return r.err
+	}
+	var objs [][]byte
+	add := func(obj []byte) int {
+		objs = append(objs, obj)
+		return len(objs)
+	}
+	reserve := func() int { return add(nil) }
+
+	catalog := reserve()
+	pages := reserve()
+	resources := reserve()
+	objs[catalog-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	kids := []string{}
+	for _, p := range r.pages {
+		content := add(pdfStream(p.content))
+		page := add([]byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>", pages, pdfNum(p.size.X), pdfNum(p.size.Y), resources, content)))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objs[pages-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	res := "<< /ProcSet [/PDF /Text /ImageC]"
+	if len(r.fonts) > 0 {
+		res += " /Font <<"
+		for i, f := range r.fonts {
+			res += fmt.Sprintf(" /F%d %d 0 R", i+1, writeFont(add, f))
+		}
+		res += " >>"
+	}
+	if len(r.images) > 0 {
+		res += " /XObject <<"
+		for i, img := range r.images {
+			res += fmt.Sprintf(" /Im%d %d 0 R", i, writeImage(add, img))
+		}
+		res += " >>"
+	}
+	if len(r.alphas) > 0 {
+		alphas := make([]float64, 0, len(r.alphas))
+		for a := range r.alphas {
+			alphas = append(alphas, a)
+		}
+		sort.Float64s(alphas)
+		res += " /ExtGState <<"
+		for _, a := range alphas {
+			res += fmt.Sprintf(" /%s << /ca %s /CA %[2]s >>", r.alphas[a], pdfNum(a))
+		}
+		res += " >>"
+	}
+	objs[resources-1] = []byte(res + " >>")
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, catalog, xref)
+	_, err := r.w.Write(b.Bytes())
+	return err
+}
+
+// writeFont adds the objects for embedding pf as a composite font whose
+// character codes are glyph indices, and returns its number.
+func writeFont(add func([]byte) int, pf *pdfFont) int {
+	var (
+		name      string
+		src       bytes.Buffer
+		m         font.Metrics
+		bbox      fixed.Rectangle26_6
+		unitsPerE float64
+		flags     = 4 // symbolic, as glyphs are not selected by character
+		italic    float64
+	)
+	pf.f.use(func(f *sfnt.Font, b *sfnt.Buffer) {
+		name, _ = f.Name(b, sfnt.NameIDPostScript)
+		f.WriteSourceTo(b, &src)
+		unitsPerE = float64(f.UnitsPerEm())
+		if post := f.PostTable(); post != nil {
+			italic = post.ItalicAngle
+			if post.IsFixedPitch {
+				flags |= 1
+			}
+		}
+		if italic != 0 {
+			flags |= 64
+		}
+		ppem := fixed.Int26_6(f.UnitsPerEm()) << 6
+		m, _ = f.Metrics(b, ppem, font.HintingNone)
+		bbox, _ = f.Bounds(b, ppem, font.HintingNone)
+	})
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%", r) {
+			return -1
+		}
+		return r
+	}, name)
+	u := func(x fixed.Int26_6) string { return pdfNum(fixedFloat(x) * 1000 / unitsPerE) }
+
+	gids := make([]sfnt.GlyphIndex, 0, len(pf.widths))
+	for g := range pf.widths {
+		gids = append(gids, g)
+	}
+	slices.Sort(gids)
+	var widths []string
+	for _, g := range gids {
+		widths = append(widths, fmt.Sprintf("%d [%s]", g, pdfNum(pf.widths[g])))
+	}
+
+	file := add(pdfStream(src.Bytes()))
+	desc := add([]byte(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%s %s %s %s] /ItalicAngle %s /Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %d 0 R >>",
+		name, flags, u(bbox.Min.X), u(-bbox.Max.Y), u(bbox.Max.X), u(-bbox.Min.Y), pdfNum(italic), u(m.Ascent), u(-m.Descent), u(m.CapHeight), file)))
+	cid := add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		name, desc, strings.Join(widths, " "))))
+	toUnicode := add(pdfStream(toUnicodeCMap(gids, pf.text)))
+	return add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cid, toUnicode)))
+}
+
+// toUnicodeCMap returns a CMap that maps the glyphs gids to the characters
+// they show.
+func toUnicodeCMap(gids []sfnt.GlyphIndex, text map[sfnt.GlyphIndex]string) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	var entries []string
+	for _, g := range gids {
+		if t := text[g]; t != "" {
+			var u strings.Builder
+			for _, c := range utf16.Encode([]rune(t)) {
+				fmt.Fprintf(&u, "%04X", c)
+			}
+			entries = append(entries, fmt.Sprintf("<%04X> <%s>", g, u.String()))
+		}
+	}
+	// A CMap section holds at most 100 entries.
+	for len(entries) > 0 {
+		n := min(len(entries), 100)
+		fmt.Fprintf(&b, "%d beginbfchar\n%s\nendbfchar\n", n, strings.Join(entries[:n], "\n"))
+		entries = entries[n:]
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// writeImage adds the objects for an image XObject showing img and returns its
+// number.
+func writeImage(add func([]byte) int, im pdfImage) int {
+	img := im.img
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	rgb := make([]byte, 0, 3*w*h)
+	alpha := make([]byte, 0, w*h)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):]
+		for x := 0; x < w; x++ {
+			rgb = append(rgb, row[4*x:4*x+3]...)
+			alpha = append(alpha, row[4*x+3])
+		}
+	}
+	dims := fmt.Sprintf("/Width %d /Height %d /BitsPerComponent 8 /Interpolate %t", w, h, im.interpolate)
+	smask := add(pdfStream(alpha, "/Type /XObject /Subtype /Image /ColorSpace /DeviceGray "+dims))
+	return add(pdfStream(rgb, fmt.Sprintf("/Type /XObject /Subtype /Image /ColorSpace /DeviceRGB %s /SMask %d 0 R", dims, smask)))
+}
+
+// pdfStream returns a compressed stream object containing data, with any
+// additional dictionary entries.
+func pdfStream(data []byte, entries ...string) []byte {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write(data)
+	w.Close()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< /Length %d /Filter /FlateDecode", z.Len())
+	for _, e := range entries {
+		fmt.Fprintf(&b, " %s", e)
+	}
+	b.WriteString(" >>\nstream\n")
+	b.Write(z.Bytes())
+	b.WriteString("\nendstream")
+	return b.Bytes()
+}
+
+func (r *PDFRenderer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.content, format, args...)
+}
+
+// resetState forgets the graphics state, which is lost when a clip is
+// replaced.
+func (r *PDFRenderer) resetState() {
+	r.set.fill, r.set.stroke = false, false
+	r.set.alpha, r.set.width = 1, 1
+}
+
+func (r *PDFRenderer) SetColor(c Color) {
+	r.color = c
+	r.paint = nil
+	r.set.fill, r.set.stroke = false, false
+}
+
+func (r *PDFRenderer) SetPaint(p Paint)       { r.paint = p }
+func (r *PDFRenderer) SetPointSize(x float64) { r.pointSize = x }
+func (r *PDFRenderer) SetLineWidth(x float64) { r.lineWidth = x }
+
+// scale returns the factor by which the page enlarges window coordinates, by
+// which line widths and point sizes are also multiplied.
+func (r *PDFRenderer) scale() float64 { return math.Sqrt(math.Abs(r.dev.A*r.dev.D - r.dev.B*r.dev.C)) }
+
+func (r *PDFRenderer) setAlpha() { r.useAlpha(r.color.A) }
+
+func (r *PDFRenderer) useAlpha(a float64) {
+	a = math.Round(math.Max(0, math.Min(1, a))*1000) / 1000
+	if a == r.set.alpha {
+		return
+	}
+	name, ok := r.alphas[a]
+	if !ok {
+		name = "GS" + strconv.Itoa(len(r.alphas))
+		r.alphas[a] = name
+	}
+	r.printf("/%s gs\n", name)
+	r.set.alpha = a
+}
+
+func (r *PDFRenderer) setFill() {
+	r.setAlpha()
+	if !r.set.fill {
+		r.printf("%s rg\n", pdfColor(r.color))
+		r.set.fill = true
+	}
+}
+
+func (r *PDFRenderer) setStroke() {
+	r.setAlpha()
+	if !r.set.stroke {
+		r.printf("%s RG\n", pdfColor(r.color))
+		r.set.stroke = true
+	}
+	if w := math.Max(1, r.lineWidth) * r.scale(); w != r.set.width {
+		r.printf("%s w\n", pdfNum(w))
+		r.set.width = w
+	}
+}
+
+// path appends a path through pts, transformed to page coordinates.
+func (r *PDFRenderer) path(pts []Point, close bool) {
+	for i, p := range pts {
+		p = r.m.Apply(p)
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		r.printf("%s %s %s\n", pdfNum(p.X), pdfNum(p.Y), op)
+	}
+	if close {
+		r.printf("h\n")
+	}
+}
+
+func (r *PDFRenderer) DrawPoint(p Point) {
+	r.setFill()
+	c := r.m.Apply(p)
+	rad := math.Max(1, r.pointSize) * r.scale() / 2
+	k := rad * 0.5523 // control point distance for a quarter circle
+	r.printf("%s %s m\n", pdfNum(c.X+rad), pdfNum(c.Y))
+	for i := 0; i < 4; i++ {
+		s0, c0 := math.Sincos(float64(i) * math.Pi / 2)
+		s1, c1 := math.Sincos(float64(i+1) * math.Pi / 2)
+		r.printf("%s %s %s %s %s %s c\n",
+			pdfNum(c.X+rad*c0-k*s0), pdfNum(c.Y+rad*s0+k*c0),
+			pdfNum(c.X+rad*c1+k*s1), pdfNum(c.Y+rad*s1-k*c1),
+			pdfNum(c.X+rad*c1), pdfNum(c.Y+rad*s1))
+	}
+	r.printf("f\n")
+}
+
+func (r *PDFRenderer) DrawLine(p1, p2 Point) { r.DrawLineStrip(p1, p2) }
+
+func (r *PDFRenderer) DrawLineStrip(pts ...Point) {
+	if len(pts) < 2 {
+		return
+	}
+	r.setStroke()
+	r.path(pts, false)
+	r.printf("S\n")
+}
+
+func (r *PDFRenderer) DrawPolygon(pts ...Point) {
+	if len(pts) < 2 {
+		return
+	}
+	r.setStroke()
+	r.path(pts, true)
+	r.printf("S\n")
+}
+
+func (r *PDFRenderer) FillRect(rect Rectangle) {
+	r.FillPolygon(rect.Min, Pt(rect.Max.X, rect.Min.Y), rect.Max, Pt(rect.Min.X, rect.Max.Y))
+}
+
+func (r *PDFRenderer) FillPolygon(pts ...Point) {
+	if len(pts) < 3 {
+		return
+	}
+	if r.paint != nil {
+		r.FillPath(polygonPath(pts), NonZero)
+		return
+	}
+	r.setFill()
+	r.path(pts, true)
+	r.printf("f\n")
+}
+
+func (r *PDFRenderer) FillPath(p *Path, rule FillRule) {
+	if r.paint != nil {
+		r.fillPaint(p, rule)
+		return
+	}
+	r.setFill()
+	d := &pdfPath{r: r}
+	p.trace(r.m, d)
+	d.close()
+	if rule == EvenOdd {
+		r.printf("f*\n")
+	} else {
+		r.printf("f\n")
+	}
+}
+
+// fillPaint fills p with the current paint, which is rasterized into an image
+// at pdfPaintResolution pixels per point and clipped to p.
+func (r *PDFRenderer) fillPaint(p *Path, rule FillRule) {
+	const k = pdfPaintResolution
+	var b boundsSink
+	p.trace(r.m, &b)
+	// Pixels have Y increasing downward, so that rows are in image order.
+	pix := image.Rect(int(math.Floor(b.r.Min.X*k)), int(math.Floor(-b.r.Max.Y*k)), int(math.Ceil(b.r.Max.X*k)), int(math.Ceil(-b.r.Min.Y*k)))
+	if !b.ok || pix.Empty() {
+		return
+	}
+	img := rasterizePaint(r.paint, r.m.Invert().Mul(Scaling(1./k, -1./k)), pix)
+
+	r.printf("q\n")
+	d := &pdfPath{r: r}
+	p.trace(r.m, d)
+	d.close()
+	if rule == EvenOdd {
+		r.printf("W* n\n")
+	} else {
+		r.printf("W n\n")
+	}
+	r.image(pdfImage{img, true}, Affine2D{float64(pix.Dx()) / k, 0, 0, float64(pix.Dy()) / k, float64(pix.Min.X) / k, float64(-pix.Max.Y) / k})
+}
+
+// image draws img, mapping the unit square to page coordinates with m, within
+// a q Q pair that the caller has opened.  It closes the pair.
+func (r *PDFRenderer) image(img pdfImage, m Affine2D) {
+	r.images = append(r.images, img)
+	alpha := r.set.alpha
+	r.useAlpha(1)
+	r.printf("%s %s %s %s %s %s cm /Im%d Do\nQ\n", pdfNum(m.A), pdfNum(m.B), pdfNum(m.C), pdfNum(m.D), pdfNum(m.E), pdfNum(m.F), len(r.images)-1)
+	r.set.alpha = alpha
+}
+
+// pdfPaintResolution is the number of pixels per point at which paints are
+// rasterized, since PDF shadings cannot express all of them.
+const pdfPaintResolution = 2
+
+// A pdfPath is a pathSink that writes path construction operators.
+type pdfPath struct {
+	r    *PDFRenderer
+	cur  Point
+	open bool
+}
+
+func (d *pdfPath) pts(op string, pts ...Point) {
+	for _, p := range pts {
+		d.r.printf("%s %s ", pdfNum(p.X), pdfNum(p.Y))
+	}
+	d.r.printf("%s\n", op)
+	d.cur = pts[len(pts)-1]
+}
+
+func (d *pdfPath) close() {
+	if d.open {
+		d.r.printf("h\n")
+		d.open = false
+	}
+}
+
+func (d *pdfPath) moveTo(p Point) {
+	d.close()
+	d.pts("m", p)
+	d.open = true
+}
+func (d *pdfPath) lineTo(p Point) { d.pts("l", p) }
+
+// quadTo elevates the curve to a cubic, which is all PDF supports.
+func (d *pdfPath) quadTo(b, c Point) {
+	a := d.cur
+	d.pts("c", a.Add(b.Sub(a).Mul(2./3)), c.Add(b.Sub(c).Mul(2./3)), c)
+}
+func (d *pdfPath) cubeTo(b, c, e Point) { d.pts("c", b, c, e) }
+
+func (r *PDFRenderer) DrawBezier(ctrlPts ...Point) {
+	switch len(ctrlPts) {
+	case 0, 1:
+		return
+	case 2:
+		r.DrawLine(ctrlPts[0], ctrlPts[1])
+		return
+	case 3:
+		// Elevate to a cubic.
+		p0, p1, p2 := ctrlPts[0], ctrlPts[1], ctrlPts[2]
+		ctrlPts = []Point{p0, p0.Add(p1.Sub(p0).Mul(2. / 3)), p2.Add(p1.Sub(p2).Mul(2. / 3)), p2}
+	case 4:
+	default:
+		n := 64
+		pts := make([]Point, n+1)
+		tmp := make([]Point, len(ctrlPts))
+		for i := range pts {
+			pts[i] = deCasteljau(ctrlPts, tmp, float64(i)/float64(n))
+		}
+		r.DrawLineStrip(pts...)
+		return
+	}
+	r.setStroke()
+	p := make([]Point, 4)
+	for i := range p {
+		p[i] = r.m.Apply(ctrlPts[i])
+	}
+	r.printf("%s %s m\n%s %s %s %s %s %s c\nS\n", pdfNum(p[0].X), pdfNum(p[0].Y),
+		pdfNum(p[1].X), pdfNum(p[1].Y), pdfNum(p[2].X), pdfNum(p[2].Y), pdfNum(p[3].X), pdfNum(p[3].Y))
+}
+
+// DrawText shows the shaped glyphs of text by their indices in the font.
+// Glyphs on the baseline are shown together, with their kerning as
+// adjustments; a glyph off it, such as a mark, starts a new line of text.
+func (r *PDFRenderer) DrawText(f *Font, text string, p Point) {
+	glyphs, _ := f.layout(text)
+	if len(glyphs) == 0 {
+		return
+	}
+	r.setFill()
+	i, pf := r.font(f)
+	pf.addText(text, glyphs)
+	size := f.Size()
+	r.printf("BT /F%d %s Tf", i, pdfNum(size))
+	pen, y := 0.0, math.NaN()
+	for _, g := range glyphs {
+		if g.y != y {
+			if !math.IsNaN(y) {
+				r.printf("] TJ")
+			}
+			m := r.m.Translate(p.Add(Pt(g.x, g.y)))
+			r.printf(" %s %s %s %s %s %s Tm [", pdfNum(m.A), pdfNum(m.B), pdfNum(m.C), pdfNum(m.D), pdfNum(m.E), pdfNum(m.F))
+			pen, y = g.x, g.y
+		}
+		if d := (g.x - pen) * 1000 / size; math.Abs(d) >= .001 {
+			r.printf("%s", pdfNum(-d))
+		}
+		r.printf("<%04X>", g.index)
+		pen = g.x + pf.widths[g.index]*size/1000
+	}
+	r.printf("] TJ ET\n")
+}
+
+// font returns the number of f's typeface among the page resources and its
+// pdfFont, adding it if it is new.
+func (r *PDFRenderer) font(f *Font) (int, *pdfFont) {
+	for i, pf := range r.fonts {
+		if pf.f.face == f.face {
+			return i + 1, pf
+		}
+	}
+	pf := &pdfFont{f: f, widths: map[sfnt.GlyphIndex]float64{}, text: map[sfnt.GlyphIndex]string{}}
+	r.fonts = append(r.fonts, pf)
+	return len(r.fonts), pf
+}
+
+// addText records the widths of the glyphs of text and the characters they
+// show.  The characters of a cluster go to its first glyph.
+func (pf *pdfFont) addText(text string, glyphs []glyph) {
+	var clusters []int
+	for _, g := range glyphs {
+		clusters = append(clusters, g.cluster)
+	}
+	slices.Sort(clusters)
+	clusters = slices.Compact(append(clusters, len(text)))
+	seen := map[int]bool{}
+	pf.f.use(func(f *sfnt.Font, b *sfnt.Buffer) {
+		upem := fixed.Int26_6(f.UnitsPerEm()) << 6
+		for _, g := range glyphs {
+			if _, ok := pf.widths[g.index]; !ok {
+				adv, _ := f.GlyphAdvance(b, g.index, upem, font.HintingNone)
+				pf.widths[g.index] = fixedFloat(adv) * 1000 / float64(f.UnitsPerEm())
+			}
+			if seen[g.cluster] {
+				continue
+			}
+			seen[g.cluster] = true
+			if pf.text[g.index] == "" {
+				i, _ := slices.BinarySearch(clusters, g.cluster)
+				pf.text[g.index] = strings.Map(func(r rune) rune {
+					if isBidiControl(r) {
+						return -1
+					}
+					return r
+				}, text[g.cluster:clusters[i+1]])
+			}
+		}
+	})
+}
+
+// DrawImage draws a copy of the pixels of img tinted by the current color,
+// clipped to dst.
+func (r *PDFRenderer) DrawImage(img *Image, dst, src Rectangle) {
+	m := img.tinted(src, r.color)
+	if m.Rect.Empty() {
+		return
+	}
+	r.printf("q\n")
+	r.path(dst.corners(), true)
+	r.printf("W n\n")
+	r.image(pdfImage{m, img.Filter == Linear}, r.m.Mul(imageRect(m.Rect, dst, src)))
+}
+
+// Layers are not supported; their contents are written as vector graphics,
+// without their Views' opacity and blend mode.
+func (r *PDFRenderer) BeginLayer(l *Layer) bool { return false }
+func (r *PDFRenderer) EndLayer()                {}
+func (r *PDFRenderer) DrawLayer(l *Layer, opacity float64, blend BlendMode) bool {
+	return false
+}
+
+func (r *PDFRenderer) PushTransform() { r.stack = append(r.stack, r.m) }
+func (r *PDFRenderer) PopTransform() {
+	r.m = r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+}
+func (r *PDFRenderer) Translate(p Point)    { r.m = r.m.Translate(p) }
+func (r *PDFRenderer) Scale(x, y float64)   { r.m = r.m.Scale(x, y) }
+func (r *PDFRenderer) Rotate(rot float64)   { r.m = r.m.Rotate(2 * math.Pi * rot) }
+func (r *PDFRenderer) Transform(m Affine2D) { r.m = r.m.Mul(m) }
+
+// Clip clips to the same area as the scissor rectangle in gl21Renderer,
+// within the printable area of the page.
+func (r *PDFRenderer) Clip(rect Rectangle) {
+	if r.clipOpen {
+		r.printf("Q\n")
+		r.resetState()
+	}
+	r.printf("q\n")
+	m := r.m
+	r.m = r.dev
+	r.path([]Point{rect.Min, Pt(rect.Max.X+1, rect.Min.Y), rect.Max.Add(Pt(1, 1)), Pt(rect.Min.X, rect.Max.Y+1)}, true)
+	r.m = m
+	r.printf("W n\n")
+	r.clipOpen = true
+}
+
+// ClipPolygon clips to the polygon within the printable area of the page.
+func (r *PDFRenderer) ClipPolygon(pts ...Point) {
+	if r.clipOpen {
+		r.printf("Q\n")
+		r.resetState()
+	}
+	r.printf("q\n")
+	m := r.m
+	r.m = r.dev
+	r.path(pts, true)
+	r.m = m
+	r.printf("W n\n")
+	r.clipOpen = true
+}
+
+func pdfNum(x float64) string {
+	return strconv.FormatFloat(math.Round(x*1000)/1000, 'f', -1, 64)
+}
+
+func pdfColor(c Color) string {
+	b := func(x float64) string { return pdfNum(math.Max(0, math.Min(1, x))) }
+	return b(c.R) + " " + b(c.G) + " " + b(c.B)
+}
@@ -0,0 +1,199 @@
+package gui
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// A FillRule decides which parts of a self-intersecting or nested shape are
+// inside it.
+type FillRule int
+
+const (
+	// NonZero fills points around which the shape winds a non-zero number of
+	// times, so holes must wind in the opposite direction to their outline.
+	NonZero FillRule = iota
+	// EvenOdd fills points around which the shape winds an odd number of
+	// times, so any nested subpath makes a hole.
+	EvenOdd
+)
+
+// A coverageRasterizer computes the anti-aliased coverage of a path in device
+// coordinates for Renderers to fill through.  It is a pathSink.
+type coverageRasterizer struct {
+	edges      []rasterEdge
+	start, cur Point
+	open       bool
+	bounds     boundsSink
+}
+
+// A rasterEdge is a line segment of a path with y0 < y1.
+type rasterEdge struct {
+	x0, y0, x1, y1 float64
+	dir            int // +1 if the path goes down along the edge, -1 if up
+}
+
+// rasterSamples is the number of sample rows per pixel.  Coverage across a
+// sample row is computed exactly.
+const rasterSamples = 16
+
+func (z *coverageRasterizer) reset() {
+	z.edges = z.edges[:0]
+	z.open = false
+	z.bounds = boundsSink{}
+}
+
+func (z *coverageRasterizer) moveTo(p Point) {
+	z.close()
+	z.start, z.cur, z.open = p, p, true
+	z.bounds.add(p)
+}
+
+func (z *coverageRasterizer) lineTo(p Point) {
+	z.edge(z.cur, p)
+	z.cur = p
+	z.bounds.add(p)
+}
+
+func (z *coverageRasterizer) quadTo(b, c Point) {
+	for _, p := range flattenBezier(nil, []Point{z.cur, b, c}) {
+		z.lineTo(p)
+	}
+}
+
+func (z *coverageRasterizer) cubeTo(b, c, d Point) {
+	for _, p := range flattenBezier(nil, []Point{z.cur, b, c, d}) {
+		z.lineTo(p)
+	}
+}
+
+func (z *coverageRasterizer) close() {
+	if z.open {
+		z.edge(z.cur, z.start)
+		z.open = false
+	}
+}
+
+func (z *coverageRasterizer) edge(p, q Point) {
+	switch {
+	case p.Y < q.Y:
+		z.edges = append(z.edges, rasterEdge{p.X, p.Y, q.X, q.Y, 1})
+	case p.Y > q.Y:
+		z.edges = append(z.edges, rasterEdge{q.X, q.Y, p.X, p.Y, -1})
+	}
+}
+
+// draw returns the coverage of the path within clip, using rule.  The
+// returned mask's bounds are the pixels that may be covered; it is nil if
+// there are none.
+func (z *coverageRasterizer) draw(rule FillRule, clip image.Rectangle) *image.Alpha {
+	z.close()
+	if len(z.edges) == 0 {
+		return nil
+	}
+	r := z.bounds.r
+	b := image.Rect(int(math.Floor(r.Min.X)), int(math.Floor(r.Min.Y)), int(math.Ceil(r.Max.X)), int(math.Ceil(r.Max.Y))).Intersect(clip)
+	if b.Empty() {
+		return nil
+	}
+	mask := image.NewAlpha(b)
+
+	sort.Slice(z.edges, func(i, j int) bool { return z.edges[i].y0 < z.edges[j].y0 })
+	// acc accumulates coverage differences along a row, with extra cells for
+	// spans reaching the right edge.
+	acc := make([]float64, b.Dx()+2)
+	var active []rasterEdge
+	var xs []crossing
+	next := 0
+	for py := b.Min.Y; py < b.Max.Y; py++ {
+		clear(acc)
+		for s := 0; s < rasterSamples; s++ {
+			y := float64(py) + (float64(s)+.5)/rasterSamples
+			for next < len(z.edges) && z.edges[next].y0 <= y {
+				active = append(active, z.edges[next])
+				next++
+			}
+			xs = xs[:0]
+			n := 0
+			for _, e := range active {
+				if e.y1 <= y {
+					continue
+				}
+				active[n] = e
+				n++
+				if e.y0 <= y {
+					xs = append(xs, crossing{e.x0 + (y-e.y0)/(e.y1-e.y0)*(e.x1-e.x0), e.dir})
+				}
+			}
+			active = active[:n]
+			sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+
+			w := 0
+			for i, c := range xs {
+				w += c.dir
+				inside := w != 0
+				if rule == EvenOdd {
+					inside = w%2 != 0
+				}
+				if inside && i+1 < len(xs) {
+					addSpan(acc, c.x-float64(b.Min.X), xs[i+1].x-float64(b.Min.X))
+				}
+			}
+		}
+		a := 0.0
+		row := mask.Pix[(py-b.Min.Y)*mask.Stride:]
+		for x := 0; x < b.Dx(); x++ {
+			a += acc[x]
+			row[x] = uint8(math.Max(0, math.Min(1, a/rasterSamples))*255 + .5)
+		}
+	}
+	return mask
+}
+
+type crossing struct {
+	x   float64
+	dir int
+}
+
+// addSpan adds the coverage of a sample row spanning x0 to x1 to the
+// difference array acc.
+func addSpan(acc []float64, x0, x1 float64) {
+	n := float64(len(acc) - 2)
+	x0, x1 = math.Max(0, math.Min(n, x0)), math.Max(0, math.Min(n, x1))
+	if x1 <= x0 {
+		return
+	}
+	i0, i1 := int(x0), int(x1)
+	f0, f1 := x0-float64(i0), x1-float64(i1)
+	if i0 == i1 {
+		acc[i0] += f1 - f0
+		acc[i0+1] -= f1 - f0
+		return
+	}
+	// The first pixel is partly covered, those up to i1 fully and i1 partly.
+	acc[i0] += 1 - f0
+	acc[i0+1] += f0
+	acc[i1] -= 1 - f1
+	acc[i1+1] -= f1
+}
+
+// convex returns whether the polygon pts is convex, so that GPU Renderers can
+// fill it directly rather than through a coverageRasterizer.
+func convex(pts []Point) bool {
+	sign, turn := 0.0, 0.0
+	for i := range pts {
+		a, b, c := pts[i], pts[(i+1)%len(pts)], pts[(i+2)%len(pts)]
+		d0, d1 := b.Sub(a), c.Sub(b)
+		x := d0.Cross(d1)
+		if x*sign < 0 {
+			return false
+		}
+		if x != 0 {
+			sign = x
+		}
+		turn += math.Atan2(x, d0.Dot(d1))
+	}
+	// A star's turns all have the same sign but add up to more than one turn.
+	return math.Abs(turn) < 2*math.Pi+1e-6
+}
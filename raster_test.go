@@ -0,0 +1,120 @@
+package gui
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// star returns the points of a five-pointed star drawn in one stroke, so that
+// it winds twice around its center.
+func star(c Point, r float64) []Point {
+	var pts []Point
+	for i := 0; i < 5; i++ {
+		sin, cos := math.Sincos(math.Pi/2 + float64(i)*4*math.Pi/5)
+		pts = append(pts, c.Add(Pt(cos, sin).Mul(r)))
+	}
+	return pts
+}
+
+// coverage rasterizes the polygons polys with rule and returns the mask.
+func coverage(rule FillRule, clip image.Rectangle, polys ...[]Point) *image.Alpha {
+	var z coverageRasterizer
+	for _, poly := range polys {
+		for i, p := range poly {
+			if i == 0 {
+				z.moveTo(p)
+			} else {
+				z.lineTo(p)
+			}
+		}
+	}
+	return z.draw(rule, clip)
+}
+
+func TestCoverageRasterizer(t *testing.T) {
+	all := image.Rect(-100, -100, 100, 100)
+	square := func(x0, y0, x1, y1 float64) []Point {
+		return []Point{Pt(x0, y0), Pt(x1, y0), Pt(x1, y1), Pt(x0, y1)}
+	}
+	for _, c := range []struct {
+		name  string
+		rule  FillRule
+		polys [][]Point
+		at    map[image.Point]uint8
+	}{
+		{"star nonzero", NonZero, [][]Point{star(Pt(20, 20), 15)}, map[image.Point]uint8{
+			{20, 20}: 255, // the center, wound twice
+			{20, 30}: 255, // a point, wound once
+			{20, 10}: 0,   // the notch between the lower points
+			{5, 5}:   0,
+		}},
+		{"star evenodd", EvenOdd, [][]Point{star(Pt(20, 20), 15)}, map[image.Point]uint8{
+			{20, 20}: 0,
+			{20, 30}: 255,
+			{20, 10}: 0, // the notch between the lower points
+			{5, 5}:   0,
+		}},
+		{"half pixels", NonZero, [][]Point{square(.5, 0, 3.25, 2)}, map[image.Point]uint8{
+			{0, 0}: 128,
+			{1, 1}: 255,
+			{3, 0}: 64,
+			{4, 0}: 0,
+		}},
+		{"hole nonzero", NonZero, [][]Point{square(0, 0, 10, 10), square(3, 3, 7, 7)}, map[image.Point]uint8{
+			{5, 5}: 255,
+			{1, 1}: 255,
+		}},
+		{"hole evenodd", EvenOdd, [][]Point{square(0, 0, 10, 10), square(3, 3, 7, 7)}, map[image.Point]uint8{
+			{5, 5}: 0,
+			{1, 1}: 255,
+		}},
+		{"reversed hole nonzero", NonZero, [][]Point{square(0, 0, 10, 10), square(7, 3, 3, 7)}, map[image.Point]uint8{
+			{5, 5}: 0,
+			{1, 1}: 255,
+		}},
+	} {
+		mask := coverage(c.rule, all, c.polys...)
+		for p, want := range c.at {
+			if got := mask.AlphaAt(p.X, p.Y).A; int(got)-int(want) > 1 || int(want)-int(got) > 1 {
+				t.Errorf("%s: coverage at %v is %d; want %d", c.name, p, got, want)
+			}
+		}
+	}
+}
+
+func TestCoverageRasterizerBounds(t *testing.T) {
+	s := star(Pt(20, 20), 15)
+	mask := coverage(NonZero, image.Rect(-100, -100, 100, 100), s)
+	if want := image.Rect(5, 7, 35, 35); mask.Rect != want {
+		t.Errorf("bounds are %v; want %v", mask.Rect, want)
+	}
+	clip := image.Rect(0, 0, 20, 20)
+	if mask := coverage(NonZero, clip, s); mask.Rect != image.Rect(5, 7, 20, 20) {
+		t.Errorf("clipped bounds are %v; want %v", mask.Rect, image.Rect(5, 7, 20, 20))
+	}
+	if mask := coverage(NonZero, image.Rect(50, 50, 60, 60), s); mask != nil {
+		t.Errorf("mask outside the clip is %v; want nil", mask.Rect)
+	}
+	if mask := coverage(NonZero, clip); mask != nil {
+		t.Errorf("mask of an empty path is %v; want nil", mask.Rect)
+	}
+}
+
+func TestConvex(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		pts  []Point
+		want bool
+	}{
+		{"square", []Point{Pt(0, 0), Pt(1, 0), Pt(1, 1), Pt(0, 1)}, true},
+		{"clockwise", []Point{Pt(0, 0), Pt(0, 1), Pt(1, 1), Pt(1, 0)}, true},
+		{"collinear", []Point{Pt(0, 0), Pt(1, 0), Pt(2, 0), Pt(2, 1)}, true},
+		{"dart", []Point{Pt(0, 0), Pt(2, 1), Pt(0, 2), Pt(1, 1)}, false},
+		{"star", star(ZP, 1), false},
+	} {
+		if got := convex(c.pts); got != c.want {
+			t.Errorf("%s: convex is %v; want %v", c.name, got, c.want)
+		}
+	}
+}
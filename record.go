@@ -0,0 +1,197 @@
+package gui
+
+import (
+	"github.com/gordonklaus/glfw"
+
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// An InputEvent is a piece of input delivered to a Window, as written by a
+// Recorder, one JSON object per line, and read by Replay.
+type InputEvent struct {
+	// Time is the time since recording started.
+	Time time.Duration `json:"t"`
+	// Type is one of "key", "char", "mouse", "scroll" or "resize".
+	Type string `json:"type"`
+	// Action is "press", "repeat" or "release" for key events and "press",
+	// "move" or "release" for mouse events.
+	Action string `json:"action,omitempty"`
+
+	Key     int     `json:"key,omitempty"`
+	Char    string  `json:"char,omitempty"`
+	Button  int     `json:"button,omitempty"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	DX      float64 `json:"dx,omitempty"`
+	DY      float64 `json:"dy,omitempty"`
+	Width   int     `json:"width,omitempty"`
+	Height  int     `json:"height,omitempty"`
+	Shift   bool    `json:"shift,omitempty"`
+	Ctrl    bool    `json:"ctrl,omitempty"`
+	Alt     bool    `json:"alt,omitempty"`
+	Super   bool    `json:"super,omitempty"`
+	Command bool    `json:"command,omitempty"`
+}
+
+// A Recorder writes the input delivered to a Window.
+type Recorder struct {
+	w     *Window
+	enc   *json.Encoder
+	start time.Time
+	err   error
+}
+
+// Record starts recording all input delivered to w, including input injected
+// by the Inject methods, to out.  Positions are recorded in w's coordinates.
+func (w *Window) Record(out io.Writer) *Recorder {
+	r := &Recorder{w: w, enc: json.NewEncoder(out)}
+	w.Do(func() {
+		r.start = w.clock.now()
+		w.recorder = r
+	})
+	return r
+}
+
+// Stop stops recording and returns the first error encountered writing the
+// recording, if any.
+func (r *Recorder) Stop() error {
+	r.w.Do(func() {
+		if r.w.recorder == r {
+			r.w.recorder = nil
+		}
+	})
+	return r.err
+}
+
+func (w *Window) record(e InputEvent) {
+	r := w.recorder
+	if r == nil || r.err != nil {
+		return
+	}
+	e.Time = w.clock.now().Sub(r.start)
+	r.err = r.enc.Encode(e)
+}
+
+func (w *Window) recordKey(k KeyEvent) {
+	w.record(InputEvent{Type: "key", Action: keyActions[k.action], Key: k.Key, Shift: k.Shift, Ctrl: k.Ctrl, Alt: k.Alt, Super: k.Super, Command: k.Command})
+}
+
+func (w *Window) recordMouse(m MouseEvent) {
+	e := InputEvent{Type: "mouse", Button: m.Button, X: m.Pos.X, Y: m.Pos.Y}
+	switch {
+	case m.Press:
+		e.Action = "press"
+	case m.Move:
+		e.Action = "move"
+	case m.Release:
+		e.Action = "release"
+	}
+	w.record(e)
+}
+
+var keyActions = map[int]string{glfw.Press: "press", glfw.Repeat: "repeat", glfw.Release: "release"}
+
+// Replay reads input recorded by a Recorder from in and delivers it to w,
+// reproducing the recorded timing.  A headless Window's clock is advanced
+// between events, making the replay deterministic; otherwise, Replay sleeps.
+// It must not be called from w's goroutine.
+func (w *Window) Replay(in io.Reader) error {
+	dec := json.NewDecoder(in)
+	last := time.Duration(0)
+	for {
+		var e InputEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d := e.Time - last; d > 0 {
+			if c, ok := w.clock.(*virtualClock); ok {
+				w.Do(func() { c.advance(d) })
+			} else {
+				time.Sleep(d)
+			}
+		}
+		last = e.Time
+
+		var err error
+		if e.Type == "resize" && w.w != nil {
+			err = w.replayResize(e.Width, e.Height)
+		} else {
+			w.Do(func() { err = w.replay(e) })
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// replayResizeTimeout is how long replayResize waits for the window system.
+const replayResizeTimeout = 2 * time.Second
+
+// replayResize resizes a Window that is not headless and waits until it has
+// been resized, so that the events after it are delivered at the new size.
+// The window system resizes the window asynchronously, and calls back from
+// the main goroutine, so this must not be called from w's goroutine.
+func (w *Window) replayResize(width, height int) error {
+	doMain(func() { w.w.SetSize(width, height) })
+	want := Pt(float64(width), float64(height))
+	for deadline := time.Now().Add(replayResizeTimeout); ; time.Sleep(time.Millisecond) {
+		var size Point
+		w.Do(func() { size = w.size })
+		if size == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("gui: window was not resized to %dx%d", width, height)
+		}
+	}
+}
+
+func (w *Window) replay(e InputEvent) error {
+	switch e.Type {
+	case "key":
+		k := KeyEvent{Key: e.Key, Shift: e.Shift, Ctrl: e.Ctrl, Alt: e.Alt, Super: e.Super, Command: e.Command}
+		switch e.Action {
+		case "press":
+			k.action = glfw.Press
+		case "repeat":
+			k.action = glfw.Repeat
+			k.Repeat = true
+		case "release":
+			k.action = glfw.Release
+		default:
+			return fmt.Errorf("gui: unknown key action %q", e.Action)
+		}
+		w.keyEvent(k)
+	case "char":
+		for _, r := range e.Char {
+			w.char(r)
+		}
+	case "mouse":
+		m := MouseEvent{Pos: Pt(e.X, e.Y), Button: e.Button}
+		switch e.Action {
+		case "press":
+			m.Press = true
+		case "move":
+			m.Move = true
+		case "release":
+			m.Release = true
+		default:
+			return fmt.Errorf("gui: unknown mouse action %q", e.Action)
+		}
+		w.mouseEvent(m)
+	case "scroll":
+		w.scroll(ScrollEvent{Pt(e.X, e.Y), Pt(e.DX, e.DY), e.Shift, e.Ctrl, e.Alt, e.Super, e.Command})
+	case "resize":
+		ax, ay := float64(w.bufWidth)/w.size.X, float64(w.bufHeight)/w.size.Y
+		w.resized(e.Width, e.Height)
+		w.framebufferResized(int(ax*float64(e.Width)), int(ay*float64(e.Height)))
+	default:
+		return fmt.Errorf("gui: unknown input event type %q", e.Type)
+	}
+	return nil
+}
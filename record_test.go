@@ -0,0 +1,142 @@
+package gui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A logView logs the input it receives, with the time of its Window's clock.
+type logView struct {
+	*ViewBase
+	log []string
+}
+
+func newLogView() *logView {
+	v := &logView{}
+	v.ViewBase = NewView(v)
+	return v
+}
+
+func (v *logView) logf(format string, args ...any) {
+	t := v.win().clock.now().Sub(time.Unix(0, 0))
+	v.log = append(v.log, fmt.Sprintf("%v "+format, append([]any{t}, args...)...))
+}
+
+func (v *logView) KeyPress(k KeyEvent) {
+	v.logf("press %d %q repeat=%v ctrl=%v", k.Key, k.Text, k.Repeat, k.Ctrl)
+}
+func (v *logView) KeyRelease(k KeyEvent) { v.logf("release %d", k.Key) }
+func (v *logView) Mouse(m MouseEvent)    { v.logf("mouse %+v", m) }
+func (v *logView) Scroll(s ScrollEvent)  { v.logf("scroll %+v", s) }
+func (v *logView) Resize(w, h float64) {
+	v.ViewBase.Resize(w, h)
+	if v.win() != nil {
+		v.logf("resize %vx%v", w, h)
+	}
+}
+
+func newLogWindow() (*Window, *logView) {
+	var v *logView
+	w := NewHeadlessWindow(nil, 100, 80, func(w *Window) {
+		v = newLogView()
+		w.SetCentralView(v)
+	})
+	return w, v
+}
+
+func TestRecordReplay(t *testing.T) {
+	w, v := newLogWindow()
+	defer w.Close()
+	var buf bytes.Buffer
+	r := w.Record(&buf)
+	w.InjectKeyPress(KeyEvent{Key: KeyA})
+	w.InjectChar('a')
+	w.Advance(250 * time.Millisecond)
+	w.InjectKeyPress(KeyEvent{Key: KeyA, Repeat: true})
+	w.InjectChar('a')
+	w.InjectKeyRelease(KeyEvent{Key: KeyA})
+	w.Advance(time.Second)
+	w.InjectMouse(MouseEvent{Pos: Pt(10, 20), Move: true})
+	w.InjectMouse(MouseEvent{Pos: Pt(10, 20), Press: true, Button: 1})
+	w.Advance(100 * time.Millisecond)
+	w.InjectMouse(MouseEvent{Pos: Pt(30, 25), Move: true})
+	w.InjectMouse(MouseEvent{Pos: Pt(30, 25), Release: true, Button: 1})
+	w.InjectScroll(ScrollEvent{Pos: Pt(5, 5), Delta: Pt(0, -2), Shift: true})
+	w.InjectKeyPress(KeyEvent{Key: KeyLeft, Ctrl: true})
+	w.Advance(time.Second)
+	w.Do(func() {
+		w.resized(120, 90)
+		w.framebufferResized(120, 90)
+	})
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	w.InjectChar('b') // not recorded
+
+	w2, v2 := newLogWindow()
+	defer w2.Close()
+	if err := w2.Replay(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var log, log2 []string
+	w.Do(func() { log = v.log })
+	w2.Do(func() { log2 = v2.log })
+	log = log[:len(log)-1]
+	if !slices.Equal(log, log2) {
+		t.Errorf("replayed input\n\t%s\nwant\n\t%s", strings.Join(log2, "\n\t"), strings.Join(log, "\n\t"))
+	}
+	var size Point
+	w2.Do(func() { size = w2.size })
+	if size != Pt(120, 90) {
+		t.Errorf("replayed window size is %v; want (120, 90)", size)
+	}
+}
+
+func TestRecordFormat(t *testing.T) {
+	w, _ := newLogWindow()
+	defer w.Close()
+	var buf bytes.Buffer
+	r := w.Record(&buf)
+	w.Advance(1500 * time.Millisecond)
+	w.InjectMouse(MouseEvent{Pos: Pt(10, 20), Press: true, Button: 2})
+	w.InjectChar('é')
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	for _, want := range []InputEvent{
+		{Time: 1500 * time.Millisecond, Type: "mouse", Action: "press", Button: 2, X: 10, Y: 20},
+		{Time: 1500 * time.Millisecond, Type: "char", Char: "é"},
+	} {
+		var e InputEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e != want {
+			t.Errorf("recorded %+v; want %+v", e, want)
+		}
+	}
+	if dec.More() {
+		t.Error("recorded extra events")
+	}
+}
+
+func TestReplayError(t *testing.T) {
+	w, _ := newLogWindow()
+	defer w.Close()
+	for _, in := range []string{
+		`{"t":0,"type":"gesture"}`,
+		`{"t":0,"type":"key","action":"tap"}`,
+		`{"t":0,"type":"mouse","action":"click"}`,
+		`{"t":0,`,
+	} {
+		if err := w.Replay(strings.NewReader(in)); err == nil {
+			t.Errorf("replaying %s succeeded", in)
+		}
+	}
+}
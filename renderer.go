@@ -0,0 +1,136 @@
+package gui
+
+// A Renderer implements the drawing functions in gl.go for a particular
+// backend.  While a View tree is being painted, the package drawing functions
+// dispatch to its Renderer, so Paint methods need not know which backend they
+// are drawing to.
+//
+// Unless stated otherwise, coordinates are in the current transform, which is
+// set up by the View tree to be the painted View's inner coordinates.
+type Renderer interface {
+	// Begin starts a frame of the given size in window coordinates, backed by
+	// bufSize pixels.  It resets the transform and clip and clears the frame.
+	Begin(size, bufSize Point)
+	// BeginPartial starts a frame like Begin, except that it keeps the
+	// previous frame's contents outside damage, which is in window
+	// coordinates.  Only damage is cleared, and drawing outside it is
+	// discarded whatever the clip.  BeginPartial returns false, and does
+	// nothing, if the Renderer has no previous frame of the same size.
+	BeginPartial(size, bufSize Point, damage Rectangle) bool
+	// End finishes the frame started by Begin or BeginPartial.
+	End()
+
+	// SetColor sets the current color and replaces any paint set by
+	// SetPaint.
+	SetColor(Color)
+	// SetPaint sets the paint used by FillRect, FillPolygon and FillPath.  A
+	// nil Paint fills with the current color.
+	SetPaint(Paint)
+	SetPointSize(float64)
+	SetLineWidth(float64)
+
+	DrawPoint(Point)
+	DrawLine(p1, p2 Point)
+	DrawLineStrip(...Point)
+	DrawPolygon(...Point)
+	FillRect(Rectangle)
+	// FillPolygon fills a polygon, which need not be convex, using the
+	// NonZero rule.
+	FillPolygon(...Point)
+	// FillPath fills p using rule, anti-aliased by its coverage of each
+	// pixel.
+	FillPath(p *Path, rule FillRule)
+	DrawBezier(...Point)
+	// DrawText draws text in font f with its baseline starting at p.
+	DrawText(f *Font, text string, p Point)
+	// DrawImage draws the part src of img, which is in its pixels and not
+	// empty, stretched over dst and tinted by the current color.
+	DrawImage(img *Image, dst, src Rectangle)
+
+	PushTransform()
+	PopTransform()
+	Translate(Point)
+	Scale(x, y float64)
+	// Rotate rotates the current transform by rot full turns.
+	Rotate(rot float64)
+	// Transform applies m and then the current transform.
+	Transform(m Affine2D)
+
+	// Clip restricts drawing to r, which is in window coordinates.  It
+	// replaces any previous clip.
+	Clip(r Rectangle)
+	// ClipPolygon restricts drawing to the convex polygon pts, which is in
+	// window coordinates.  It replaces any previous clip.
+	ClipPolygon(pts ...Point)
+
+	// BeginLayer redirects drawing to an offscreen image of l, covering
+	// {0, l.Size} in the current transform at the resolution it gives.
+	// Until the matching EndLayer, drawing starts afresh as if in a frame of
+	// l.Size: the transform and clip are reset and window coordinates are
+	// the layer's.  BeginLayer returns false, and does nothing, if the
+	// Renderer does not support layers.
+	BeginLayer(l *Layer) bool
+	// EndLayer finishes the layer started by BeginLayer and restores the
+	// transform, clip and color.
+	EndLayer()
+	// DrawLayer draws the image of l over {0, l.Size} in the current
+	// transform, with its alpha multiplied by opacity, combined with what is
+	// beneath it according to blend.  It returns false, and draws nothing, if
+	// the Renderer has no image of l's Version at the resolution of the
+	// current transform.
+	DrawLayer(l *Layer, opacity float64, blend BlendMode) bool
+}
+
+// painting is the state of the innermost View tree being painted.  Like the
+// View tree itself, it is not safe to paint from several goroutines at once.
+var painting struct {
+	r Renderer
+	// damage is the area being painted, in the coordinates of the root's
+	// frame.  Views outside it are not painted.
+	damage Rectangle
+	// alpha multiplies the alpha of the colors and paints set by SetColor
+	// and SetPaint.  It is less than 1 within Views whose opacity could not
+	// be applied through a layer.
+	alpha float64
+}
+
+// paintScratch holds buffers that paint reuses for each View's ancestors and
+// clip polygon, from one frame to the next.
+var paintScratch struct {
+	chain         []View
+	clip, clipBuf []Point
+}
+
+// CurrentRenderer returns the Renderer that the View tree being painted is
+// drawn to, or nil if no painting is in progress.  It is meant to be called
+// from Paint methods.
+func CurrentRenderer() Renderer { return painting.r }
+
+// renderer returns the current Renderer for the drawing functions, which
+// may only be called while a View tree is being painted.
+func renderer() Renderer {
+	if painting.r == nil {
+		panic("gui: drawing function called outside of Paint")
+	}
+	return painting.r
+}
+
+// Render paints v and its descendants to r, in the coordinates of v's frame,
+// so that v's position is at the origin and its transform is not applied.  It
+// does not call r.Begin or r.End.
+func Render(r Renderer, v View) { render(r, v, Rectangle{ZP, v.base().size}) }
+
+// render paints the parts of v and its descendants within damage, which is in
+// the coordinates of v's frame.  It may be called from a Paint method, as to
+// render a thumbnail of another View, and restores the painting in progress
+// when it returns.
+func render(r Renderer, v View, damage Rectangle) {
+	saved := painting
+	painting.r, painting.damage, painting.alpha = r, damage, 1
+	defer func() { painting = saved }()
+
+	r.PushTransform()
+	defer r.PopTransform()
+	r.Transform(frameToParent(v).Invert())
+	v.base().paint(v)
+}
@@ -0,0 +1,666 @@
+package gui
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+
+	"slices"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Text is shaped in runs of a single script.  The glyphs of each rune are
+// looked up, substituted according to the font's GSUB table (ligatures,
+// contextual forms, the joining forms of Arabic, Syriac and other joining
+// scripts) and positioned according to its GPOS table (kerning, marks
+// attached to their bases).  Fonts without GPOS kerning fall back to their
+// kern table, and combining marks that a font does not position are centered
+// over their bases.  A character followed by marks is composed or decomposed
+// first if the font only has glyphs for the other form.
+//
+// Text is first split into runs of one direction and ordered for display by
+// the bidi algorithm, then each of those is split into runs of one script.
+// Right-to-left runs are laid out from right to left, with mirrored
+// characters such as parentheses swapped for their mirror images, and bidi
+// control characters take no glyphs.  In Indic scripts, each syllable's
+// pre-base matras are moved to its start and a reph (a leading ra and halant,
+// when the font forms one) to its end before substitution, and the forms that
+// apply only to parts of a syllable (reph, half forms) are limited to them.
+// The finer positioning rules of the Indic shaping specification, such as
+// below-base and post-base consonants being left out of the base, are not
+// implemented.
+
+// A script is a writing system, with the OpenType tags that select its
+// features in order of preference.
+type script struct {
+	table    *unicode.RangeTable
+	tags     []string
+	features []string // GSUB features beyond the common ones
+	joining  bool     // whether letters take Arabic joining forms
+	indic    bool     // whether syllables are reordered as Indic scripts are
+}
+
+var (
+	commonSubstFeatures = []string{"ccmp", "locl", "rlig", "rclt", "liga", "clig", "calt"}
+	arabicSubstFeatures = []string{"isol", "fina", "fin2", "fin3", "medi", "med2", "init", "mset"}
+	indicSubstFeatures  = []string{"nukt", "akhn", "rphf", "rkrf", "pref", "blwf", "abvf", "half", "pstf", "vatu", "cjct", "pres", "abvs", "blws", "psts", "haln"}
+	posFeatures         = []string{"kern", "mark", "mkmk", "dist", "abvm", "blwm"}
+)
+
+var scripts = []*script{
+	{table: unicode.Latin, tags: []string{"latn"}},
+	{table: unicode.Greek, tags: []string{"grek"}},
+	{table: unicode.Cyrillic, tags: []string{"cyrl"}},
+	{table: unicode.Arabic, tags: []string{"arab"}, features: arabicSubstFeatures, joining: true},
+	{table: unicode.Syriac, tags: []string{"syrc"}, features: arabicSubstFeatures, joining: true},
+	{table: unicode.Nko, tags: []string{"nko "}, features: arabicSubstFeatures, joining: true},
+	{table: unicode.Mandaic, tags: []string{"mand"}, features: arabicSubstFeatures, joining: true},
+	{table: unicode.Mongolian, tags: []string{"mong"}, features: arabicSubstFeatures, joining: true},
+	{table: unicode.Adlam, tags: []string{"adlm"}, features: arabicSubstFeatures, joining: true},
+	{table: unicode.Hebrew, tags: []string{"hebr"}},
+	{table: unicode.Thaana, tags: []string{"thaa"}},
+	{table: unicode.Devanagari, tags: []string{"dev2", "deva"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Bengali, tags: []string{"bng2", "beng"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Gurmukhi, tags: []string{"gur2", "guru"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Gujarati, tags: []string{"gjr2", "gujr"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Oriya, tags: []string{"ory2", "orya"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Tamil, tags: []string{"tml2", "taml"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Telugu, tags: []string{"tel2", "telu"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Kannada, tags: []string{"knd2", "knda"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Malayalam, tags: []string{"mlm2", "mlym"}, features: indicSubstFeatures, indic: true},
+	{table: unicode.Thai, tags: []string{"thai"}},
+	{table: unicode.Hangul, tags: []string{"hang"}},
+	{table: unicode.Han, tags: []string{"hani"}},
+	{table: unicode.Hiragana, tags: []string{"kana"}},
+	{table: unicode.Katakana, tags: []string{"kana"}},
+}
+
+// defaultScript is the script of text in no known script.
+var defaultScript = &script{tags: []string{"DFLT"}}
+
+// scriptOf returns the script of r, or nil if r is common to several scripts,
+// such as a digit, punctuation or a combining mark.
+func scriptOf(r rune) *script {
+	if r < 0x80 && !unicode.IsLetter(r) {
+		return nil
+	}
+	for _, s := range scripts {
+		if unicode.Is(s.table, r) {
+			return s
+		}
+	}
+	return nil
+}
+
+// A textRun is a span of text in one script.
+type textRun struct {
+	start, end int // byte offsets
+	script     *script
+}
+
+// itemize splits text into runs of a single script.  Characters common to
+// several scripts belong to the run around them.
+func itemize(text string) []textRun {
+	var runs []textRun
+	for i, r := range text {
+		s := scriptOf(r)
+		if n := len(runs); n > 0 {
+			last := &runs[n-1]
+			if last.script == nil || s == nil || s == last.script {
+				if last.script == nil {
+					last.script = s
+				}
+				continue
+			}
+			last.end = i
+		}
+		runs = append(runs, textRun{start: i, script: s})
+	}
+	if n := len(runs); n > 0 {
+		runs[n-1].end = len(text)
+	}
+	for i := range runs {
+		if runs[i].script == nil {
+			runs[i].script = defaultScript
+		}
+	}
+	return runs
+}
+
+// Arabic joining types.
+const (
+	joinNone        = 'U'
+	joinRight       = 'R' // joins to the previous letter only
+	joinLeft        = 'L' // joins to the next letter only
+	joinDual        = 'D'
+	joinCausing     = 'C'
+	joinTransparent = 'T'
+	// The letters of the Syriac joining groups Alaph and Dalath Rish join as
+	// joinRight, but Alaph takes other forms after them.
+	joinAlaph      = 'a'
+	joinDalathRish = 'd'
+)
+
+//go:generate go run gen_joining.go
+
+// joining returns the Arabic joining type of r.
+func joining(r rune) byte {
+	i := sort.Search(len(arabicJoining), func(i int) bool { return arabicJoining[i].hi >= r })
+	if i < len(arabicJoining) && arabicJoining[i].lo <= r {
+		return arabicJoining[i].typ
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return joinTransparent
+	}
+	return joinNone
+}
+
+// A shapeGlyph is a glyph being shaped, in font units.
+type shapeGlyph struct {
+	index   sfnt.GlyphIndex
+	cluster int    // the byte offset in the text of the first rune it came from
+	class   int    // its GDEF class
+	form    string // the Arabic joining form feature that applies to it, if any
+	adv     int
+	dx, dy  int  // the offset from where it would otherwise be placed
+	attach  int  // 1 + the index of the glyph a mark is attached to, or 0
+	x, y    int  // its origin, once laid out
+	rtl     bool // whether it was laid out from right to left
+	reph    bool // whether it is the ra or halant of a reph
+	final   bool // whether it is in a consonant ending its syllable with a halant
+}
+
+// applies reports whether the feature applies to g.
+func (g *shapeGlyph) applies(feature string) bool {
+	switch feature {
+	case "rphf":
+		return g.reph
+	case "half":
+		return !g.reph && !g.final
+	case "rkrf", "pref", "blwf", "pstf", "vatu", "cjct":
+		return !g.reph
+	}
+	switch feature {
+	case "isol", "fina", "fin2", "fin3", "medi", "med2", "init":
+		return g.form == feature
+	}
+	return true
+}
+
+// A shaper holds the glyphs of a run being shaped.
+type shaper struct {
+	ot  *otLayout
+	buf []shapeGlyph
+}
+
+type shapingKey struct {
+	text string
+	dir  Direction
+}
+
+// A shaping is the result of shaping a text.
+type shaping struct {
+	glyphs []shapeGlyph // in the order they are displayed
+	adv    int
+}
+
+// maxShapings is the number of shaped texts a fontFace remembers.
+const maxShapings = 1024
+
+// shape returns the glyphs of text laid out in direction dir, in font units,
+// in the order they are displayed, and its total advance.  f.mu must be held.
+func (f *fontFace) shape(text string, dir Direction) ([]shapeGlyph, int) {
+	k := shapingKey{text, dir}
+	if s, ok := f.shapings[k]; ok {
+		return s.glyphs, s.adv
+	}
+	var s shaping
+	for _, b := range bidiRuns(text, dir) {
+		runs := itemize(text[b.start:b.end])
+		for i := range runs {
+			runs[i].start += b.start
+			runs[i].end += b.start
+		}
+		if b.rtl() {
+			slices.Reverse(runs)
+		}
+		for _, run := range runs {
+			glyphs, adv := f.shapeRun(text, run, b.rtl())
+			for _, g := range glyphs {
+				g.x += s.adv
+				s.glyphs = append(s.glyphs, g)
+			}
+			s.adv += adv
+		}
+	}
+	if f.shapings == nil || len(f.shapings) >= maxShapings {
+		f.shapings = map[shapingKey]shaping{}
+	}
+	f.shapings[k] = s
+	return s.glyphs, s.adv
+}
+
+// shapeRun shapes a run of text and lays it out from x = 0, from right to
+// left if rtl is true.
+func (f *fontFace) shapeRun(text string, run textRun, rtl bool) ([]shapeGlyph, int) {
+	s := &shaper{ot: f.ot}
+	var joins []byte
+	var runes []rune
+	var add func(r rune, cluster int)
+	add = func(r rune, cluster int) {
+		if isBidiControl(r) {
+			return
+		}
+		if run.script.indic {
+			// Split matras are decomposed so that their pre-base part can be
+			// reordered.
+			if d := []rune(norm.NFD.String(string(r))); len(d) == 2 && indicCategory(d[0]) == indicPreMatra {
+				add(d[0], cluster)
+				add(d[1], cluster)
+				return
+			}
+		}
+		if m := mirror(r); rtl && m != r {
+			if g, _ := f.f.GlyphIndex(&f.buf, m); g != 0 {
+				r = m
+			}
+		}
+		g, _ := f.f.GlyphIndex(&f.buf, r)
+		class := baseGlyph
+		if isMark(r) {
+			class = markGlyph
+		}
+		s.buf = append(s.buf, shapeGlyph{index: g, cluster: cluster, class: s.classOf(g, class)})
+		joins = append(joins, joining(r))
+		runes = append(runes, r)
+	}
+	for i := run.start; i < run.end; {
+		// A character and the marks following it, which are composed or
+		// decomposed if the font lacks glyphs for them as they are.
+		_, n := utf8.DecodeRuneInString(text[i:run.end])
+		j := i + n
+		for j < run.end {
+			r, n := utf8.DecodeRuneInString(text[j:run.end])
+			if !isMark(r) {
+				break
+			}
+			j += n
+		}
+		if rs, ok := f.normalize(text[i:j]); ok {
+			for _, r := range rs {
+				add(r, i)
+			}
+		} else {
+			for k, r := range text[i:j] {
+				add(r, i+k)
+			}
+		}
+		i = j
+	}
+	if run.script.joining {
+		s.join(joins)
+	}
+	subst := f.ot.featureLookups(false, run.script)
+	if run.script.indic {
+		reph := slices.ContainsFunc(subst, func(fl featureLookup) bool { return slices.Contains(fl.features, "rphf") })
+		s.reorderIndic(runes, reph)
+	}
+
+	for _, fl := range subst {
+		s.substitute(fl)
+	}
+	upem := f.unitsPerEm()
+	for i := range s.buf {
+		a, _ := f.f.GlyphAdvance(&f.buf, s.buf[i].index, upem, font.HintingNone)
+		s.buf[i].adv = int(a >> 6)
+	}
+	kerned, marked := false, false
+	for _, fl := range f.ot.featureLookups(true, run.script) {
+		s.position(fl)
+		kerned = kerned || slices.Contains(fl.features, "kern")
+		marked = marked || slices.Contains(fl.features, "mark")
+	}
+	if !kerned {
+		f.kernFallback(s, rtl)
+	}
+	if !marked {
+		f.markFallback(s)
+	}
+	return s.layout(rtl)
+}
+
+func isMark(r rune) bool { return unicode.In(r, unicode.Mn, unicode.Me) }
+
+// normalize returns the runes of seq composed or decomposed so that the font
+// has glyphs for all of them, reporting false if it has glyphs for seq as it is
+// or for neither form.
+func (f *fontFace) normalize(seq string) ([]rune, bool) {
+	has := func(s string) bool {
+		for _, r := range s {
+			if g, _ := f.f.GlyphIndex(&f.buf, r); g == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if has(seq) {
+		return nil, false
+	}
+	for _, form := range []norm.Form{norm.NFC, norm.NFD} {
+		if s := form.String(seq); has(s) {
+			return []rune(s), true
+		}
+	}
+	return nil, false
+}
+
+// unitsPerEm returns the ppem at which metrics are in font units, times 64.
+func (f *fontFace) unitsPerEm() fixed.Int26_6 {
+	return fixed.Int26_6(f.f.UnitsPerEm()) << 6
+}
+
+// featureLookups returns the GSUB or GPOS lookups for a script.
+func (l *otLayout) featureLookups(gpos bool, s *script) []featureLookup {
+	k := otKey{gpos, s.tags[0]}
+	if ls, ok := l.lookups[k]; ok {
+		return ls
+	}
+	var ls []featureLookup
+	if gpos {
+		ls = l.gpos.lookups(s.tags, posFeatures)
+	} else {
+		ls = l.gsub.lookups(s.tags, append(slices.Clip(commonSubstFeatures), s.features...))
+	}
+	l.lookups[k] = ls
+	return ls
+}
+
+// join gives each letter the Arabic form for how it joins its neighbors,
+// given the joining types of the runes of the run.
+func (s *shaper) join(joins []byte) {
+	prev, state := -1, 0
+	for i, j := range joins {
+		col := 0
+		switch j {
+		case joinTransparent:
+			continue
+		case joinLeft:
+			col = 1
+		case joinRight:
+			col = 2
+		case joinDual, joinCausing:
+			col = 3
+		case joinAlaph:
+			col = 4
+		case joinDalathRish:
+			col = 5
+		}
+		t := joinStates[state][col]
+		if prev >= 0 && t.prev != "" {
+			s.buf[prev].form = t.prev
+		}
+		s.buf[i].form = t.cur
+		prev, state = i, t.next
+	}
+	for i, j := range joins {
+		if j == joinCausing {
+			s.buf[i].form = ""
+		}
+	}
+}
+
+// joinStates is the state machine by which join chooses forms, as in
+// HarfBuzz.  It is indexed by the state and then by the joining type of the
+// next letter: none, left, right, dual or causing, Alaph, and Dalath Rish.
+// Each transition gives the form the previous letter changes to, if any, the
+// form of the next letter and the next state.
+var joinStates = [7][6]struct {
+	prev, cur string
+	next      int
+}{
+	// 0: the previous letter does not join the next.
+	{{"", "", 0}, {"", "isol", 2}, {"", "isol", 1}, {"", "isol", 2}, {"", "isol", 1}, {"", "isol", 6}},
+	// 1: the previous letter is right-joining or an isolated Alaph.
+	{{"", "", 0}, {"", "isol", 2}, {"", "isol", 1}, {"", "isol", 2}, {"", "fin2", 5}, {"", "isol", 6}},
+	// 2: the previous letter is isolated and joins the next.
+	{{"", "", 0}, {"", "isol", 2}, {"init", "fina", 1}, {"init", "fina", 3}, {"init", "fina", 4}, {"init", "fina", 6}},
+	// 3: the previous letter is final and joins the next.
+	{{"", "", 0}, {"", "isol", 2}, {"medi", "fina", 1}, {"medi", "fina", 3}, {"medi", "fina", 4}, {"medi", "fina", 6}},
+	// 4: the previous letter is a final Alaph.
+	{{"", "", 0}, {"", "isol", 2}, {"med2", "isol", 1}, {"med2", "isol", 2}, {"med2", "fin2", 5}, {"med2", "isol", 6}},
+	// 5: the previous letter is an Alaph in its second or third final form.
+	{{"", "", 0}, {"", "isol", 2}, {"isol", "isol", 1}, {"isol", "isol", 2}, {"isol", "fin2", 5}, {"isol", "isol", 6}},
+	// 6: the previous letter is Dalath or Rish.
+	{{"", "", 0}, {"", "isol", 2}, {"", "isol", 1}, {"", "isol", 2}, {"", "fin3", 5}, {"", "isol", 6}},
+}
+
+// Indic character categories, for finding syllables.
+const (
+	indicOther     = iota
+	indicConsonant // or independent vowel
+	indicRa
+	indicHalant
+	indicNukta
+	indicPreMatra // a dependent vowel written before its consonants
+	indicMatra    // or another dependent sign
+	indicModifier // candrabindu, anusvara or visarga
+	indicJoiner   // zero width joiner or non-joiner
+)
+
+// preBaseMatras are the dependent vowels of the Indic blocks that are written
+// before the consonants they follow in the text.
+var preBaseMatras = map[rune]bool{
+	0x093F: true, 0x094E: true, // Devanagari
+	0x09BF: true, 0x09C7: true, 0x09C8: true, // Bengali
+	0x0A3F: true,                             // Gurmukhi
+	0x0ABF: true,                             // Gujarati
+	0x0B47: true,                             // Oriya
+	0x0BC6: true, 0x0BC7: true, 0x0BC8: true, // Tamil
+	0x0D46: true, 0x0D47: true, 0x0D48: true, // Malayalam
+}
+
+// indicCategory returns the category of r in the Indic blocks from Devanagari
+// to Malayalam, which share a layout: ra, nukta and halant are at the same
+// offset in each block of 128 code points, and the signs at offsets 1 to 3
+// modify the whole syllable.
+func indicCategory(r rune) int {
+	switch {
+	case r == 0x200C || r == 0x200D:
+		return indicJoiner
+	case r < 0x0900 || r > 0x0D7F:
+		return indicOther
+	case preBaseMatras[r]:
+		return indicPreMatra
+	}
+	o := r & 0x7F
+	if unicode.IsLetter(r) {
+		if o == 0x30 {
+			return indicRa
+		}
+		return indicConsonant
+	}
+	if !unicode.In(r, unicode.Mn, unicode.Mc) {
+		return indicOther
+	}
+	switch {
+	case o == 0x4D:
+		return indicHalant
+	case o == 0x3C:
+		return indicNukta
+	case o <= 0x03:
+		return indicModifier
+	}
+	return indicMatra
+}
+
+// reorderIndic puts the glyphs of each syllable of an Indic run, whose runes
+// are given, in the order that fonts expect: pre-base matras first and, if
+// reph is true, a leading ra and halant after everything but the syllable's
+// modifiers.  The glyphs of a reordered syllable are merged into one cluster.
+func (s *shaper) reorderIndic(runes []rune, reph bool) {
+	cats := make([]int, len(runes))
+	for i, r := range runes {
+		cats[i] = indicCategory(r)
+	}
+	for start := 0; start < len(cats); {
+		end := indicSyllable(cats, start)
+		reorderSyllable(s.buf[start:end], cats[start:end], reph)
+		start = end
+	}
+}
+
+// indicSyllable returns the end of the syllable starting at cats[i]: a
+// consonant joined by halants to any following consonants, and its signs.
+func indicSyllable(cats []int, i int) int {
+	if cats[i] != indicConsonant && cats[i] != indicRa {
+		return i + 1
+	}
+	for i++; i < len(cats); i++ {
+		switch cats[i] {
+		case indicConsonant, indicRa:
+			j := i - 1
+			if cats[j] == indicJoiner && j > 0 {
+				j--
+			}
+			if cats[j] != indicHalant {
+				return i
+			}
+		case indicOther:
+			return i
+		}
+	}
+	return i
+}
+
+// reorderSyllable reorders the glyphs of one syllable, whose characters have
+// the categories cats, and marks those that some features do not apply to.
+func reorderSyllable(buf []shapeGlyph, cats []int, reph bool) {
+	n := len(cats)
+	if cats[n-1] == indicHalant {
+		// A final halant is shown, not joined into a half form.
+		for i := n - 1; i >= 0; i-- {
+			buf[i].final = true
+			if cats[i] == indicConsonant || cats[i] == indicRa {
+				break
+			}
+		}
+	}
+	reph = reph && n > 2 && cats[0] == indicRa && cats[1] == indicHalant && (cats[2] == indicConsonant || cats[2] == indicRa)
+	var pre, rest []shapeGlyph
+	first, m := 0, 0 // m is where the reph goes in rest: before any trailing modifiers
+	if reph {
+		buf[0].reph, buf[1].reph = true, true
+		first = 2
+	}
+	for i := first; i < n; i++ {
+		switch cats[i] {
+		case indicPreMatra:
+			pre = append(pre, buf[i])
+		case indicModifier:
+			rest = append(rest, buf[i])
+		default:
+			rest = append(rest, buf[i])
+			m = len(rest)
+		}
+	}
+	if !reph && len(pre) == 0 {
+		return
+	}
+	cluster := buf[0].cluster
+	out := slices.Concat(pre, rest[:m], buf[:first], rest[m:])
+	for i := range out {
+		out[i].cluster = cluster
+	}
+	copy(buf, out)
+}
+
+// kernFallback kerns the glyphs with the font's kern table.
+func (f *fontFace) kernFallback(s *shaper, rtl bool) {
+	upem := f.unitsPerEm()
+	prev := -1
+	for i := range s.buf {
+		if s.buf[i].class == markGlyph {
+			continue
+		}
+		if prev >= 0 {
+			// The kern goes to the advance of whichever glyph is on the
+			// left, which layout places first.
+			left, right := prev, i
+			if rtl {
+				left, right = i, prev
+			}
+			k, _ := f.f.Kern(&f.buf, s.buf[left].index, s.buf[right].index, upem, font.HintingNone)
+			s.buf[left].adv += int(k >> 6)
+		}
+		prev = i
+	}
+}
+
+// markFallback centers the marks that have not been attached over their
+// bases, above or below them if they would otherwise overlap.
+func (f *fontFace) markFallback(s *shaper) {
+	upem := f.unitsPerEm()
+	bounds := func(g sfnt.GlyphIndex) (x0, y0, x1, y1 int) {
+		b, _, _ := f.f.GlyphBounds(&f.buf, g, upem, font.HintingNone)
+		// Flip Y to increase upward.
+		return int(b.Min.X >> 6), int(-b.Max.Y >> 6), int(b.Max.X >> 6), int(-b.Min.Y >> 6)
+	}
+	gap := int(f.f.UnitsPerEm()) / 20
+	base := -1
+	for i := range s.buf {
+		g := &s.buf[i]
+		if g.class != markGlyph {
+			base = i
+			continue
+		}
+		if g.attach != 0 || base < 0 {
+			continue
+		}
+		bx0, by0, bx1, by1 := bounds(s.buf[base].index)
+		mx0, my0, mx1, my1 := bounds(g.index)
+		g.attach = base + 1
+		g.dx = (bx0+bx1)/2 - (mx0+mx1)/2
+		switch {
+		case my0 >= 0: // above the baseline
+			g.dy = max(0, by1+gap-my0)
+		case my1 <= 0: // below it
+			g.dy = min(0, by0-gap-my1)
+		}
+	}
+}
+
+// layout places the glyphs from x = 0, reverses them if the run is written
+// from right to left, and returns them with the run's advance.
+func (s *shaper) layout(rtl bool) ([]shapeGlyph, int) {
+	x := 0
+	place := func(g *shapeGlyph) {
+		g.rtl = rtl
+		if g.attach == 0 {
+			g.x, g.y = x+g.dx, g.dy
+			x += g.adv
+		}
+	}
+	if rtl {
+		for i := len(s.buf) - 1; i >= 0; i-- {
+			place(&s.buf[i])
+		}
+	} else {
+		for i := range s.buf {
+			place(&s.buf[i])
+		}
+	}
+	// Marks follow their bases in the text, so their bases are placed first.
+	for i := range s.buf {
+		if g := &s.buf[i]; g.attach != 0 {
+			b := s.buf[g.attach-1]
+			g.x, g.y = b.x+g.dx, b.y+g.dy
+		}
+	}
+	if rtl {
+		slices.Reverse(s.buf)
+	}
+	return s.buf, x
+}
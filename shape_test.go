@@ -0,0 +1,123 @@
+package gui
+
+import (
+	"golang.org/x/image/font/sfnt"
+
+	"slices"
+	"testing"
+)
+
+func TestReorderIndic(t *testing.T) {
+	for _, c := range []struct {
+		text, want string
+		reph       bool
+	}{
+		{"कि", "िक", true},
+		{"हिंदी", "िहंदी", true},
+		{"क्षि", "िक्ष", true},
+		{"र्क", "कर्", true},
+		{"र्कि", "िकर्", true},
+		{"र्कं", "कर्ं", true},
+		{"र्क", "र्क", false},
+		{"र्", "र्", true},
+		{"र्‍क", "र्‍क", true},
+		{"\u0995\u09C7\u09BE", "\u09C7\u0995\u09BE", true}, // কো, decomposed as by shapeRun
+		{"ab", "ab", true},
+	} {
+		s := &shaper{}
+		var runes []rune
+		for i, r := range []rune(c.text) {
+			s.buf = append(s.buf, shapeGlyph{index: sfnt.GlyphIndex(r), cluster: i})
+			runes = append(runes, r)
+		}
+		s.reorderIndic(runes, c.reph)
+		var got []rune
+		for _, g := range s.buf {
+			got = append(got, rune(g.index))
+		}
+		if string(got) != c.want {
+			t.Errorf("reorderIndic(%q, %v) = %q; want %q", c.text, c.reph, string(got), c.want)
+		}
+	}
+}
+
+func TestIndicFeatureMasks(t *testing.T) {
+	s := &shaper{}
+	runes := []rune("र्कक्")
+	for i, r := range runes {
+		s.buf = append(s.buf, shapeGlyph{index: sfnt.GlyphIndex(r), cluster: i})
+	}
+	s.reorderIndic(runes, true)
+	// कर्क्: the moved reph forms only with rphf, and the final क् takes no
+	// half form.
+	for i, want := range []struct{ rphf, half bool }{{false, true}, {true, false}, {true, false}, {false, false}, {false, false}} {
+		g := s.buf[i]
+		if g.applies("rphf") != want.rphf || g.applies("half") != want.half {
+			t.Errorf("glyph %d (%c): rphf %v, half %v; want %v, %v", i, rune(g.index), g.applies("rphf"), g.applies("half"), want.rphf, want.half)
+		}
+	}
+}
+
+func TestJoining(t *testing.T) {
+	for _, c := range []struct {
+		r    rune
+		want byte
+	}{
+		{'a', joinNone},
+		{0x0621, joinNone}, // hamza
+		{0x0627, joinRight},
+		{0x0628, joinDual},
+		{0x0640, joinCausing}, // tatweel
+		{0x064B, joinTransparent},
+		{0x0600, joinNone}, // a format character that does not join
+		{0x0710, joinAlaph},
+		{0x0712, joinDual},
+		{0x072A, joinDalathRish},
+		{0x0750, joinDual},
+		{0x08A0, joinDual},
+		{0x07CA, joinDual}, // N'Ko
+		{0xA872, joinLeft},
+		{0x200C, joinNone},
+		{0x200D, joinCausing},
+		{0x0301, joinTransparent},
+	} {
+		if got := joining(c.r); got != c.want {
+			t.Errorf("joining(%U) = %c; want %c", c.r, got, c.want)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want []string
+	}{
+		{"بيت", []string{"init", "medi", "fina"}},
+		{"باب", []string{"init", "fina", "isol"}},
+		{"بًب", []string{"init", "", "fina"}},
+		{"ب ب", []string{"isol", "", "isol"}},
+		{"‍ب", []string{"", "fina"}},
+		{"ب‌ب", []string{"isol", "", "isol"}},
+		{"ܒܐ", []string{"init", "fina"}},
+		{"ܒܐܒ", []string{"init", "med2", "isol"}},
+		{"ܘܐ", []string{"isol", "fin2"}},
+		{"ܕܐ", []string{"isol", "fin3"}},
+		{"ܐܐ", []string{"isol", "fin2"}},
+		{"ܐ", []string{"isol"}},
+		{"ꡲꡀ", []string{"init", "fina"}},
+	} {
+		var joins []byte
+		for _, r := range c.text {
+			joins = append(joins, joining(r))
+		}
+		s := &shaper{buf: make([]shapeGlyph, len(joins))}
+		s.join(joins)
+		var got []string
+		for _, g := range s.buf {
+			got = append(got, g.form)
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("join(%q) = %q; want %q", c.text, got, c.want)
+		}
+	}
+}
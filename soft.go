@@ -0,0 +1,503 @@
+package gui
+
+import (
+	"golang.org/x/image/vector"
+
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// A SoftRenderer is a Renderer that rasterizes in pure Go into an image.RGBA,
+// so that View trees can be rendered without a GPU or an OpenGL context.
+type SoftRenderer struct {
+	img   *image.RGBA
+	dev   Affine2D // window to pixel coordinates
+	m     Affine2D
+	stack []Affine2D
+	clip  image.Rectangle
+	// clipMask, if not nil, is the coverage of a polygonal clip within clip.
+	clipMask *image.Alpha
+	// bounds are the pixels being drawn, to which every clip is limited.
+	bounds image.Rectangle
+
+	color     *image.Uniform
+	paint     Paint
+	pointSize float64
+	lineWidth float64
+
+	z   vector.Rasterizer
+	cov coverageRasterizer
+
+	layers map[*Layer]softLayer
+	saved  []softState // the state saved by BeginLayer
+}
+
+type softLayer struct {
+	img     *image.RGBA
+	version uint64
+}
+
+type softState struct {
+	layer     *Layer
+	img       *image.RGBA
+	dev, m    Affine2D
+	stack     []Affine2D
+	clip      image.Rectangle
+	clipMask  *image.Alpha
+	bounds    image.Rectangle
+	color     *image.Uniform
+	paint     Paint
+	pointSize float64
+	lineWidth float64
+}
+
+func NewSoftRenderer() *SoftRenderer {
+	return &SoftRenderer{}
+}
+
+// Image returns the image drawn to since the last call to Begin.  Its Y axis
+// increases downward, so its first row is the top of the frame.
+func (r *SoftRenderer) Image() *image.RGBA { return r.img }
+
+// RenderImage renders v and its descendants into a new image of v's size
+// multiplied by scale.  Like other operations on a View tree, it must be
+// called from the goroutine of the Window holding v, if any.
+func RenderImage(v View, scale float64) *image.RGBA {
+	size := v.base().size
+	r := NewSoftRenderer()
+	r.Begin(size, Pt(math.Ceil(size.X*scale), math.Ceil(size.Y*scale)))
+	Render(r, v)
+	r.End()
+	return r.Image()
+}
+
+func (r *SoftRenderer) Begin(size, bufSize Point) {
+	b := image.Rect(0, 0, int(bufSize.X), int(bufSize.Y))
+	if r.img == nil || r.img.Rect != b {
+		r.img = image.NewRGBA(b)
+	} else {
+		clear(r.img.Pix)
+	}
+	r.begin(size, bufSize, b)
+}
+
+// BeginPartial keeps the image drawn since the previous call to Begin.
+func (r *SoftRenderer) BeginPartial(size, bufSize Point, damage Rectangle) bool {
+	dev := Affine2D{bufSize.X / size.X, 0, 0, -bufSize.Y / size.Y, 0, bufSize.Y}
+	if r.img == nil || r.img.Rect != image.Rect(0, 0, int(bufSize.X), int(bufSize.Y)) || r.dev != dev {
+		return false
+	}
+	p, q := dev.Apply(damage.Min), dev.Apply(damage.Max)
+	// damage's edges lie on pixel boundaries, up to rounding error.
+	b := image.Rect(int(math.Floor(p.X+1e-6)), int(math.Floor(q.Y+1e-6)), int(math.Ceil(q.X-1e-6)), int(math.Ceil(p.Y-1e-6))).Intersect(r.img.Rect)
+	draw.Draw(r.img, b, image.Transparent, image.Point{}, draw.Src)
+	r.begin(size, bufSize, b)
+	return true
+}
+
+// begin resets the state for a frame that draws only the pixels b.
+func (r *SoftRenderer) begin(size, bufSize Point, b image.Rectangle) {
+	for l := range r.layers {
+		if l.released.Load() {
+			delete(r.layers, l)
+		}
+	}
+	r.saved = r.saved[:0]
+	r.dev = Affine2D{bufSize.X / size.X, 0, 0, -bufSize.Y / size.Y, 0, bufSize.Y}
+	r.m = r.dev
+	r.stack = r.stack[:0]
+	r.clip = b
+	r.clipMask = nil
+	r.bounds = b
+	r.SetColor(Color{1, 1, 1, 1})
+	r.pointSize = 1
+	r.lineWidth = 1
+}
+
+func (r *SoftRenderer) End() {}
+
+func (r *SoftRenderer) SetColor(c Color) {
+	r.color = image.NewUniform(color.NRGBA64{unitUint16(c.R), unitUint16(c.G), unitUint16(c.B), unitUint16(c.A)})
+	r.paint = nil
+}
+
+func (r *SoftRenderer) SetPaint(p Paint) { r.paint = p }
+
+func unitUint16(x float64) uint16 { return uint16(math.Max(0, math.Min(1, x))*0xffff + .5) }
+
+func (r *SoftRenderer) SetPointSize(x float64) { r.pointSize = x }
+func (r *SoftRenderer) SetLineWidth(x float64) { r.lineWidth = x }
+
+func (r *SoftRenderer) DrawPoint(p Point) {
+	c := r.m.Apply(p)
+	rad := math.Max(1, r.pointSize) / 2
+	r.fill(func(s pathSink) {
+		const n = 16
+		for i := 0; i < n; i++ {
+			sin, cos := math.Sincos(2 * math.Pi * float64(i) / n)
+			q := c.Add(Pt(cos, sin).Mul(rad))
+			if i == 0 {
+				s.moveTo(q)
+			} else {
+				s.lineTo(q)
+			}
+		}
+	})
+}
+
+func (r *SoftRenderer) DrawLine(p1, p2 Point) { r.DrawLineStrip(p1, p2) }
+
+func (r *SoftRenderer) DrawLineStrip(pts ...Point) {
+	dev := make([]Point, len(pts))
+	for i, p := range pts {
+		dev[i] = r.m.Apply(p)
+	}
+	r.stroke(dev)
+}
+
+func (r *SoftRenderer) DrawPolygon(pts ...Point) {
+	if len(pts) > 0 {
+		r.DrawLineStrip(append(pts[:len(pts):len(pts)], pts[0])...)
+	}
+}
+
+func (r *SoftRenderer) FillRect(rect Rectangle) {
+	r.FillPolygon(rect.Min, Pt(rect.Max.X, rect.Min.Y), rect.Max, Pt(rect.Min.X, rect.Max.Y))
+}
+
+func (r *SoftRenderer) FillPolygon(pts ...Point) {
+	if r.paint != nil {
+		r.FillPath(polygonPath(pts), NonZero)
+		return
+	}
+	r.fill(func(s pathSink) {
+		for i, p := range pts {
+			if i == 0 {
+				s.moveTo(r.m.Apply(p))
+			} else {
+				s.lineTo(r.m.Apply(p))
+			}
+		}
+	})
+}
+
+func (r *SoftRenderer) FillPath(p *Path, rule FillRule) {
+	r.cov.reset()
+	p.trace(r.m, &r.cov)
+	if mask := r.cov.draw(rule, r.clip); mask != nil {
+		r.maskClip(mask)
+		if r.paint != nil {
+			draw.Draw(r.img, mask.Rect, paintMask(r.paint, r.m.Invert(), mask), mask.Rect.Min, draw.Over)
+			return
+		}
+		draw.DrawMask(r.img, mask.Rect, r.color, image.Point{}, mask, mask.Rect.Min, draw.Over)
+	}
+}
+
+// DrawImage fills dst with an imagePaint.
+func (r *SoftRenderer) DrawImage(img *Image, dst, src Rectangle) {
+	c := r.color.C.(color.NRGBA64)
+	tint := Color{float64(c.R) / 0xffff, float64(c.G) / 0xffff, float64(c.B) / 0xffff, float64(c.A) / 0xffff}
+	paint := r.paint
+	r.paint = &imagePaint{img, dst, src, tint}
+	r.FillRect(dst)
+	r.paint = paint
+}
+
+func (r *SoftRenderer) DrawBezier(ctrlPts ...Point) {
+	if len(ctrlPts) == 0 {
+		return
+	}
+	dev := make([]Point, len(ctrlPts))
+	steps := 0.0
+	for i, p := range ctrlPts {
+		dev[i] = r.m.Apply(p)
+		if i > 0 {
+			steps += dev[i].Sub(dev[i-1]).Len()
+		}
+	}
+	n := int(math.Ceil(steps/4)) + 1
+	pts := make([]Point, n+1)
+	tmp := make([]Point, len(dev))
+	for i := range pts {
+		pts[i] = deCasteljau(dev, tmp, float64(i)/float64(n))
+	}
+	r.stroke(pts)
+}
+
+// deCasteljau evaluates the Bézier curve with control points p at t, using
+// tmp (which must be as long as p) as scratch space.
+func deCasteljau(p, tmp []Point, t float64) Point {
+	copy(tmp, p)
+	for n := len(tmp) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			tmp[i] = tmp[i].Mul(1 - t).Add(tmp[i+1].Mul(t))
+		}
+	}
+	return tmp[0]
+}
+
+func (r *SoftRenderer) DrawText(f *Font, text string, p Point) {
+	r.fill(func(s pathSink) {
+		f.outline(text, r.m.Translate(p), s)
+	})
+}
+
+func (r *SoftRenderer) PushTransform() { r.stack = append(r.stack, r.m) }
+func (r *SoftRenderer) PopTransform() {
+	r.m = r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+}
+func (r *SoftRenderer) Translate(p Point)    { r.m = r.m.Translate(p) }
+func (r *SoftRenderer) Scale(x, y float64)   { r.m = r.m.Scale(x, y) }
+func (r *SoftRenderer) Rotate(rot float64)   { r.m = r.m.Rotate(2 * math.Pi * rot) }
+func (r *SoftRenderer) Transform(m Affine2D) { r.m = r.m.Mul(m) }
+
+// Clip clips to the same pixels as the scissor rectangle in gl21Renderer.
+func (r *SoftRenderer) Clip(rect Rectangle) {
+	dy := r.img.Rect.Dy()
+	ax, ay := r.dev.A, -r.dev.D
+	x, y := int(ax*rect.Min.X), int(ay*rect.Min.Y)
+	w, h := int(ax*(rect.Dx()+1)), int(ay*(rect.Dy()+1))
+	r.clip = image.Rect(x, dy-y-h, x+w, dy-y).Intersect(r.bounds)
+	r.clipMask = nil
+}
+
+// ClipPolygon clips to the anti-aliased coverage of the polygon.
+func (r *SoftRenderer) ClipPolygon(pts ...Point) {
+	r.cov.reset()
+	for i, p := range pts {
+		if i == 0 {
+			r.cov.moveTo(r.dev.Apply(p))
+		} else {
+			r.cov.lineTo(r.dev.Apply(p))
+		}
+	}
+	r.clipMask = r.cov.draw(NonZero, r.bounds)
+	r.clip = image.Rectangle{}
+	if r.clipMask != nil {
+		r.clip = r.clipMask.Rect
+	}
+}
+
+func (r *SoftRenderer) BeginLayer(l *Layer) bool {
+	if l.Size.X <= 0 || l.Size.Y <= 0 {
+		return false
+	}
+	w, h := layerPixels(l, r.m)
+	r.saved = append(r.saved, softState{l, r.img, r.dev, r.m, r.stack, r.clip, r.clipMask, r.bounds, r.color, r.paint, r.pointSize, r.lineWidth})
+	r.img = image.NewRGBA(image.Rect(0, 0, w, h))
+	r.dev = Affine2D{float64(w) / l.Size.X, 0, 0, -float64(h) / l.Size.Y, 0, float64(h)}
+	r.m = r.dev
+	r.stack = nil
+	r.clip = r.img.Rect
+	r.clipMask = nil
+	r.bounds = r.img.Rect
+	r.SetColor(Color{1, 1, 1, 1})
+	r.pointSize = 1
+	r.lineWidth = 1
+	return true
+}
+
+func (r *SoftRenderer) EndLayer() {
+	s := r.saved[len(r.saved)-1]
+	r.saved = r.saved[:len(r.saved)-1]
+	if r.layers == nil {
+		r.layers = map[*Layer]softLayer{}
+	}
+	r.layers[s.layer] = softLayer{r.img, s.layer.Version}
+	r.img, r.dev, r.m, r.stack, r.clip, r.clipMask, r.bounds = s.img, s.dev, s.m, s.stack, s.clip, s.clipMask, s.bounds
+	r.color, r.paint, r.pointSize, r.lineWidth = s.color, s.paint, s.pointSize, s.lineWidth
+}
+
+// DrawLayer copies the layer's pixels directly if they line up with the
+// frame's, and otherwise fills its rectangle with them as an ImagePattern.
+// With an opacity or blend mode, it does so onto a transparent image, which it
+// then composites.
+func (r *SoftRenderer) DrawLayer(l *Layer, opacity float64, blend BlendMode) bool {
+	e, ok := r.layers[l]
+	if !ok || e.version != l.Version {
+		return false
+	}
+	w, h := layerPixels(l, r.m)
+	if w != e.img.Rect.Dx() || h != e.img.Rect.Dy() {
+		return false
+	}
+	if opacity == 1 && blend == BlendNormal {
+		r.drawLayer(l, e)
+		return true
+	}
+	dst := r.img
+	r.img = image.NewRGBA(r.clip)
+	r.drawLayer(l, e)
+	composite(dst, r.img, opacity, blend)
+	r.img = dst
+	return true
+}
+
+// drawLayer draws e, the image of l, over what has been drawn.
+func (r *SoftRenderer) drawLayer(l *Layer, e softLayer) {
+	w, h := e.img.Rect.Dx(), e.img.Rect.Dy()
+	m := r.m
+	o := m.Apply(Pt(0, l.Size.Y))
+	x, y := math.Round(o.X), math.Round(o.Y)
+	const eps = 1e-9
+	if m.B == 0 && m.C == 0 && math.Abs(m.A*l.Size.X-float64(w)) < eps && math.Abs(m.D*l.Size.Y+float64(h)) < eps && math.Abs(o.X-x) < eps && math.Abs(o.Y-y) < eps {
+		off := image.Pt(int(x), int(y))
+		b := e.img.Rect.Add(off).Intersect(r.clip)
+		if r.clipMask == nil {
+			draw.Draw(r.img, b, e.img, b.Min.Sub(off), draw.Over)
+		} else {
+			draw.DrawMask(r.img, b, e.img, b.Min.Sub(off), r.clipMask, b.Min, draw.Over)
+		}
+		return
+	}
+	paint := r.paint
+	r.paint = &ImagePattern{e.img, Rectangle{ZP, l.Size}, Pad}
+	r.FillRect(Rectangle{ZP, l.Size})
+	r.paint = paint
+}
+
+// composite blends src, scaled by opacity, onto dst.
+func composite(dst, src *image.RGBA, opacity float64, blend BlendMode) {
+	b := src.Rect.Intersect(dst.Rect)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			s, d := src.Pix[src.PixOffset(x, y):][:4], dst.Pix[dst.PixOffset(x, y):][:4]
+			if s[3] == 0 {
+				continue
+			}
+			sa, da := float64(s[3])/0xff*opacity, float64(d[3])/0xff
+			for i := range d {
+				d[i] = unitUint8(blend.blend(float64(s[i])/0xff*opacity, sa, float64(d[i])/0xff, da))
+			}
+		}
+	}
+}
+
+// maskClip multiplies the coverage mask by the polygonal clip, if any.
+func (r *SoftRenderer) maskClip(mask *image.Alpha) {
+	if r.clipMask == nil {
+		return
+	}
+	b := mask.Rect
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := mask.Pix[mask.PixOffset(b.Min.X, y):]
+		clip := r.clipMask.Pix[r.clipMask.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			row[x] = uint8((int(row[x])*int(clip[x]) + 127) / 255)
+		}
+	}
+}
+
+// stroke draws a line strip through the device-space points pts.
+func (r *SoftRenderer) stroke(pts []Point) {
+	w := math.Max(1, r.lineWidth) / 2
+	r.fill(func(s pathSink) {
+		for i := 1; i < len(pts); i++ {
+			p1, p2 := pts[i-1], pts[i]
+			d := p2.Sub(p1)
+			l := d.Len()
+			if l == 0 {
+				continue
+			}
+			n := Pt(-d.Y, d.X).Mul(w / l)
+			s.moveTo(p1.Add(n))
+			s.lineTo(p2.Add(n))
+			s.lineTo(p2.Sub(n))
+			s.lineTo(p1.Sub(n))
+		}
+	})
+}
+
+// A pathSink receives a path in device coordinates.  Subpaths are implicitly
+// closed.
+type pathSink interface {
+	moveTo(Point)
+	lineTo(Point)
+	quadTo(b, c Point)
+	cubeTo(b, c, d Point)
+}
+
+// fill fills the path traced by trace with the current color using the
+// non-zero winding rule.  trace is called twice, first to find the path's
+// bounds and then to rasterize it.
+func (r *SoftRenderer) fill(trace func(pathSink)) {
+	var b boundsSink
+	trace(&b)
+	if !b.ok {
+		return
+	}
+	bounds := image.Rect(int(math.Floor(b.r.Min.X)), int(math.Floor(b.r.Min.Y)), int(math.Ceil(b.r.Max.X)), int(math.Ceil(b.r.Max.Y))).Intersect(r.clip)
+	if bounds.Empty() {
+		return
+	}
+	r.z.Reset(bounds.Dx(), bounds.Dy())
+	z := &rasterSink{z: &r.z, off: Pt(float64(bounds.Min.X), float64(bounds.Min.Y))}
+	trace(z)
+	z.close()
+	if r.clipMask == nil {
+		r.z.Draw(r.img, bounds, r.color, image.Point{})
+		return
+	}
+	mask := image.NewAlpha(bounds)
+	r.z.Draw(mask, bounds, image.Opaque, image.Point{})
+	r.maskClip(mask)
+	draw.DrawMask(r.img, bounds, r.color, image.Point{}, mask, bounds.Min, draw.Over)
+}
+
+type boundsSink struct {
+	r  Rectangle
+	ok bool
+}
+
+func (s *boundsSink) add(p Point) {
+	if !s.ok {
+		s.r = Rectangle{p, p}
+		s.ok = true
+	} else {
+		s.r = s.r.Union(Rectangle{p, p})
+	}
+}
+func (s *boundsSink) moveTo(p Point)       { s.add(p) }
+func (s *boundsSink) lineTo(p Point)       { s.add(p) }
+func (s *boundsSink) quadTo(b, c Point)    { s.add(b); s.add(c) }
+func (s *boundsSink) cubeTo(b, c, d Point) { s.add(b); s.add(c); s.add(d) }
+
+type rasterSink struct {
+	z    *vector.Rasterizer
+	off  Point
+	open bool
+}
+
+func (s *rasterSink) xy(p Point) (float32, float32) {
+	p = p.Sub(s.off)
+	return float32(p.X), float32(p.Y)
+}
+
+func (s *rasterSink) close() {
+	if s.open {
+		s.z.ClosePath()
+		s.open = false
+	}
+}
+
+func (s *rasterSink) moveTo(p Point) {
+	s.close()
+	s.z.MoveTo(s.xy(p))
+	s.open = true
+}
+func (s *rasterSink) lineTo(p Point) { s.z.LineTo(s.xy(p)) }
+func (s *rasterSink) quadTo(b, c Point) {
+	bx, by := s.xy(b)
+	cx, cy := s.xy(c)
+	s.z.QuadTo(bx, by, cx, cy)
+}
+func (s *rasterSink) cubeTo(b, c, d Point) {
+	bx, by := s.xy(b)
+	cx, cy := s.xy(c)
+	dx, dy := s.xy(d)
+	s.z.CubeTo(bx, by, cx, cy, dx, dy)
+}
@@ -0,0 +1,160 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// A rectView fills its frame with a color.
+type rectView struct {
+	*ViewBase
+	c Color
+}
+
+func newRectView(c Color, x, y, w, h float64) *rectView {
+	v := &rectView{c: c}
+	v.ViewBase = NewView(v)
+	v.Resize(w, h)
+	v.Move(Pt(x, y))
+	return v
+}
+
+func (v *rectView) Paint() {
+	SetColor(v.c)
+	FillRect(InnerRect(v))
+}
+
+var (
+	black = Color{0, 0, 0, 1}
+	red   = Color{1, 0, 0, 1}
+	green = Color{0, 1, 0, 1}
+	blue  = Color{0, 0, 1, 1}
+)
+
+// pixelAt returns the color of img at p, in the coordinates of a frame of
+// height h rendered at scale.
+func pixelAt(img *image.RGBA, p Point, h, scale float64) color.RGBA {
+	return img.RGBAAt(int(p.X*scale), int((h-p.Y)*scale))
+}
+
+// nearColor returns whether c is within tol of want in every channel.
+func nearColor(c, want color.RGBA, tol int) bool {
+	d := func(a, b uint8) bool { return int(a)-int(b) <= tol && int(b)-int(a) <= tol }
+	return d(c.R, want.R) && d(c.G, want.G) && d(c.B, want.B) && d(c.A, want.A)
+}
+
+func TestRenderImage(t *testing.T) {
+	root := newRectView(black, 0, 0, 40, 30)
+	root.Add(newRectView(red, 10, 5, 20, 10))
+	for _, scale := range []float64{1, 2, 1.5} {
+		img := RenderImage(root, scale)
+		if got, want := img.Rect.Size(), image.Pt(int(40*scale), int(30*scale)); got != want {
+			t.Fatalf("at scale %v, size is %v; want %v", scale, got, want)
+		}
+		for _, c := range []struct {
+			p    Point
+			want color.RGBA
+		}{
+			{Pt(20, 10), color.RGBA{255, 0, 0, 255}},
+			{Pt(11, 6), color.RGBA{255, 0, 0, 255}},
+			{Pt(29, 14), color.RGBA{255, 0, 0, 255}},
+			{Pt(5, 10), color.RGBA{0, 0, 0, 255}},
+			{Pt(20, 3), color.RGBA{0, 0, 0, 255}},
+			{Pt(20, 17), color.RGBA{0, 0, 0, 255}},
+			{Pt(35, 25), color.RGBA{0, 0, 0, 255}},
+		} {
+			if got := pixelAt(img, c.p, 30, scale); got != c.want {
+				t.Errorf("at scale %v, pixel at %v is %v; want %v", scale, c.p, got, c.want)
+			}
+		}
+	}
+}
+
+func TestRenderImageClip(t *testing.T) {
+	root := newRectView(black, 0, 0, 40, 40)
+	clipped := newRectView(red, 10, 10, 20, 20)
+	clipped.Add(newRectView(green, -10, -10, 20, 20))
+	root.Add(clipped)
+	img := RenderImage(root, 1)
+	if got := pixelAt(img, Pt(5, 5), 40, 1); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("pixel outside the clip is %v; want black", got)
+	}
+	if got := pixelAt(img, Pt(15, 15), 40, 1); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("pixel inside the clip is %v; want green", got)
+	}
+
+	clipped.NoClip = true
+	img = RenderImage(root, 1)
+	if got := pixelAt(img, Pt(5, 5), 40, 1); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("with NoClip, pixel outside the frame is %v; want green", got)
+	}
+	clipped.NoClip = false
+
+	// A rotated frame clips to a polygon.
+	clipped.SetTransform(Rotation(math.Pi / 4))
+	img = RenderImage(root, 1)
+	if got := pixelAt(img, Pt(28, 12), 40, 1); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("pixel outside the rotated clip is %v; want black", got)
+	}
+	if got := pixelAt(img, Pt(10, 20), 40, 1); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("pixel inside the rotated clip is %v; want green", got)
+	}
+}
+
+func TestRenderImageOpacity(t *testing.T) {
+	root := newRectView(black, 0, 0, 40, 30)
+	group := newRectView(red, 10, 5, 20, 20)
+	group.Add(newRectView(green, 5, 5, 10, 10))
+	root.Add(group)
+	SetOpacity(group, .5)
+	img := RenderImage(root, 1)
+	// The group is faded as a whole, so the red beneath the green does not
+	// show through it.
+	for _, c := range []struct {
+		p    Point
+		want color.RGBA
+	}{
+		{Pt(12, 7), color.RGBA{128, 0, 0, 255}},
+		{Pt(20, 15), color.RGBA{0, 128, 0, 255}},
+		{Pt(5, 5), color.RGBA{0, 0, 0, 255}},
+	} {
+		if got := pixelAt(img, c.p, 30, 1); !nearColor(got, c.want, 1) {
+			t.Errorf("pixel at %v is %v; want %v", c.p, got, c.want)
+		}
+	}
+}
+
+func TestSoftRendererBeginPartial(t *testing.T) {
+	root := newRectView(black, 0, 0, 40, 30)
+	child := newRectView(red, 10, 5, 20, 10)
+	root.Add(child)
+	size := Pt(40, 30)
+	r := NewSoftRenderer()
+	if r.BeginPartial(size, size, Rectangle{ZP, size}) {
+		t.Fatal("BeginPartial succeeded without a previous frame")
+	}
+	r.Begin(size, size)
+	Render(r, root)
+	r.End()
+
+	root.c, child.c = blue, green
+	damage := Rectangle{Pt(10, 5), Pt(30, 15)}
+	if !r.BeginPartial(size, size, damage) {
+		t.Fatal("BeginPartial failed")
+	}
+	render(r, root, damage)
+	r.End()
+	img := r.Image()
+	if got := pixelAt(img, Pt(20, 10), 30, 1); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("damaged pixel is %v; want green", got)
+	}
+	if got := pixelAt(img, Pt(5, 20), 30, 1); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("undamaged pixel is %v; want the previous frame's black", got)
+	}
+
+	if r.BeginPartial(Pt(20, 15), size, damage) {
+		t.Error("BeginPartial succeeded with a different size")
+	}
+}
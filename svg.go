@@ -0,0 +1,367 @@
+package gui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// An SVGRenderer is a Renderer that writes an SVG document.  Geometry is
+// written in the document's coordinates, with the transforms of the View tree
+// already applied, so line widths and point sizes are unaffected by them as in
+// the other Renderers.
+type SVGRenderer struct {
+	w   *bufio.Writer
+	err error
+
+	size  Point
+	dev   Affine2D // window to document coordinates
+	m     Affine2D
+	stack []Affine2D
+
+	clips     int
+	clipOpen  bool
+	paints    int
+	color     Color
+	paint     Paint
+	pointSize float64
+	lineWidth float64
+}
+
+func NewSVGRenderer(w io.Writer) *SVGRenderer {
+	return &SVGRenderer{w: bufio.NewWriter(w)}
+}
+
+// WriteSVG writes an SVG document depicting v and its descendants to w.  Like
+// other operations on a View tree, it must be called from the goroutine of the
+// Window holding v, if any.
+func WriteSVG(w io.Writer, v View) error {
+	r := NewSVGRenderer(w)
+	size := v.base().size
+	r.Begin(size, size)
+	Render(r, v)
+	r.End()
+	return r.Err()
+}
+
+// Err returns the first error encountered writing the document, if any.
+func (r *SVGRenderer) Err() error { return r.err }
+
+func (r *SVGRenderer) printf(format string, args ...interface{}) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.w, format, args...)
+	}
+}
+
+func (r *SVGRenderer) Begin(size, bufSize Point) {
+	r.size = size
+	r.dev = Affine2D{1, 0, 0, -1, 0, size.Y}
+	r.m = r.dev
+	r.stack = r.stack[:0]
+	r.clips = 0
+	r.clipOpen = false
+	r.paints = 0
+	r.color = Color{1, 1, 1, 1}
+	r.paint = nil
+	r.pointSize = 1
+	r.lineWidth = 1
+	r.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	r.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %[1]s %[2]s\">\n", svgNum(size.X), svgNum(size.Y))
+}
+
+// BeginPartial always fails, since each frame is a separate document.
+func (r *SVGRenderer) BeginPartial(size, bufSize Point, damage Rectangle) bool { return false }
+
+func (r *SVGRenderer) End() {
+	if r.clipOpen {
+		r.printf("</g>\n")
+	}
+	r.printf("</svg>\n")
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+}
+
+func (r *SVGRenderer) SetColor(c Color)       { r.color, r.paint = c, nil }
+func (r *SVGRenderer) SetPaint(p Paint)       { r.paint = p }
+func (r *SVGRenderer) SetPointSize(x float64) { r.pointSize = x }
+func (r *SVGRenderer) SetLineWidth(x float64) { r.lineWidth = x }
+
+func (r *SVGRenderer) fill() string {
+	return fmt.Sprintf(`fill="%s" fill-opacity="%s"`, svgColor(r.color), svgNum(r.color.A))
+}
+
+// paintFill returns the attributes for a fill with the current paint, first
+// writing the paint's definition.  b is the fill's bounds in document
+// coordinates.
+func (r *SVGRenderer) paintFill(b Rectangle) string {
+	if r.paint == nil {
+		return r.fill()
+	}
+	r.paints++
+	id := fmt.Sprintf("paint%d", r.paints)
+	m := fmt.Sprintf("matrix(%s %s %s %s %s %s)", svgNum(r.m.A), svgNum(r.m.B), svgNum(r.m.C), svgNum(r.m.D), svgNum(r.m.E), svgNum(r.m.F))
+	switch p := r.paint.(type) {
+	case *LinearGradient:
+		r.printf("<linearGradient id=\"%s\" gradientUnits=\"userSpaceOnUse\" gradientTransform=\"%s\" x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\" spreadMethod=\"%s\">\n",
+			id, m, svgNum(p.Start.X), svgNum(p.Start.Y), svgNum(p.End.X), svgNum(p.End.Y), svgSpread(p.Spread))
+		r.stops(p.Stops)
+		r.printf("</linearGradient>\n")
+	case *RadialGradient:
+		f := p.Center.Add(p.focus())
+		r.printf("<radialGradient id=\"%s\" gradientUnits=\"userSpaceOnUse\" gradientTransform=\"%s\" cx=\"%s\" cy=\"%s\" r=\"%s\" fx=\"%s\" fy=\"%s\" spreadMethod=\"%s\">\n",
+			id, m, svgNum(p.Center.X), svgNum(p.Center.Y), svgNum(p.Radius), svgNum(f.X), svgNum(f.Y), svgSpread(p.Spread))
+		r.stops(p.Stops)
+		r.printf("</radialGradient>\n")
+	default:
+		// Other paints have no SVG equivalent, so they are drawn as an image
+		// at twice the document's resolution.
+		const k = 2
+		pix := image.Rect(int(math.Floor(b.Min.X*k)), int(math.Floor(b.Min.Y*k)), int(math.Ceil(b.Max.X*k)), int(math.Ceil(b.Max.Y*k)))
+		if pix.Empty() {
+			return `fill="none"`
+		}
+		var data bytes.Buffer
+		png.Encode(&data, rasterizePaint(p, r.m.Invert().Mul(Scaling(1./k, 1./k)), pix))
+		x, y, w, h := svgNum(float64(pix.Min.X)/k), svgNum(float64(pix.Min.Y)/k), svgNum(float64(pix.Dx())/k), svgNum(float64(pix.Dy())/k)
+		r.printf("<pattern id=\"%s\" patternUnits=\"userSpaceOnUse\" x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\">", id, x, y, w, h)
+		r.printf("<image x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" preserveAspectRatio=\"none\" xlink:href=\"data:image/png;base64,%s\"/></pattern>\n",
+			x, y, w, h, base64.StdEncoding.EncodeToString(data.Bytes()))
+	}
+	return fmt.Sprintf(`fill="url(#%s)"`, id)
+}
+
+func (r *SVGRenderer) stops(stops []ColorStop) {
+	for _, s := range stops {
+		r.printf("<stop offset=\"%s\" stop-color=\"%s\" stop-opacity=\"%s\"/>\n", svgNum(s.Offset), svgColor(s.Color), svgNum(s.Color.A))
+	}
+}
+
+func svgSpread(s Spread) string {
+	switch s {
+	case Repeat:
+		return "repeat"
+	case Reflect:
+		return "reflect"
+	}
+	return "pad"
+}
+
+func (r *SVGRenderer) stroke() string {
+	return fmt.Sprintf(`fill="none" stroke="%s" stroke-opacity="%s" stroke-width="%s"`, svgColor(r.color), svgNum(r.color.A), svgNum(r.lineWidth))
+}
+
+func (r *SVGRenderer) points(pts []Point) string {
+	s := make([]string, len(pts))
+	for i, p := range pts {
+		p = r.m.Apply(p)
+		s[i] = svgNum(p.X) + "," + svgNum(p.Y)
+	}
+	return strings.Join(s, " ")
+}
+
+func (r *SVGRenderer) DrawPoint(p Point) {
+	p = r.m.Apply(p)
+	r.printf("<circle cx=\"%s\" cy=\"%s\" r=\"%s\" %s/>\n", svgNum(p.X), svgNum(p.Y), svgNum(r.pointSize/2), r.fill())
+}
+
+func (r *SVGRenderer) DrawLine(p1, p2 Point) { r.DrawLineStrip(p1, p2) }
+
+func (r *SVGRenderer) DrawLineStrip(pts ...Point) {
+	r.printf("<polyline points=\"%s\" %s/>\n", r.points(pts), r.stroke())
+}
+
+func (r *SVGRenderer) DrawPolygon(pts ...Point) {
+	r.printf("<polygon points=\"%s\" %s/>\n", r.points(pts), r.stroke())
+}
+
+func (r *SVGRenderer) FillRect(rect Rectangle) {
+	r.FillPolygon(rect.Min, Pt(rect.Max.X, rect.Min.Y), rect.Max, Pt(rect.Min.X, rect.Max.Y))
+}
+
+func (r *SVGRenderer) FillPolygon(pts ...Point) {
+	var b boundsSink
+	for _, p := range pts {
+		b.add(r.m.Apply(p))
+	}
+	r.printf("<polygon points=\"%s\" %s/>\n", r.points(pts), r.paintFill(b.r))
+}
+
+func (r *SVGRenderer) FillPath(p *Path, rule FillRule) {
+	var d svgPath
+	var b boundsSink
+	p.trace(r.m, &d)
+	p.trace(r.m, &b)
+	d.close()
+	fillRule := "nonzero"
+	if rule == EvenOdd {
+		fillRule = "evenodd"
+	}
+	r.printf("<path d=\"%s\" fill-rule=\"%s\" %s/>\n", strings.TrimSpace(d.String()), fillRule, r.paintFill(b.r))
+}
+
+// An svgPath is a pathSink that writes SVG path data.
+type svgPath struct {
+	strings.Builder
+	open bool
+}
+
+func (d *svgPath) pts(cmd string, pts ...Point) {
+	d.WriteString(cmd)
+	for _, p := range pts {
+		d.WriteString(" " + svgNum(p.X) + "," + svgNum(p.Y))
+	}
+	d.WriteString(" ")
+}
+
+func (d *svgPath) close() {
+	if d.open {
+		d.WriteString("Z ")
+		d.open = false
+	}
+}
+
+func (d *svgPath) moveTo(p Point) {
+	d.close()
+	d.pts("M", p)
+	d.open = true
+}
+func (d *svgPath) lineTo(p Point)       { d.pts("L", p) }
+func (d *svgPath) quadTo(b, c Point)    { d.pts("Q", b, c) }
+func (d *svgPath) cubeTo(b, c, e Point) { d.pts("C", b, c, e) }
+
+func (r *SVGRenderer) DrawBezier(ctrlPts ...Point) {
+	if len(ctrlPts) < 2 {
+		return
+	}
+	if len(ctrlPts) > 4 {
+		n := 64
+		pts := make([]Point, n+1)
+		tmp := make([]Point, len(ctrlPts))
+		for i := range pts {
+			pts[i] = deCasteljau(ctrlPts, tmp, float64(i)/float64(n))
+		}
+		r.DrawLineStrip(pts...)
+		return
+	}
+	cmd := [...]string{2: "L", 3: "Q", 4: "C"}[len(ctrlPts)]
+	r.printf("<path d=\"M%s %s%s\" %s/>\n", r.points(ctrlPts[:1]), cmd, r.points(ctrlPts[1:]), r.stroke())
+}
+
+// DrawText writes a text element in the font's family and size.  The text
+// is laid out by the SVG viewer, so its advance may differ slightly.
+// Right-to-left text is marked as such, anchored at its left end.
+func (r *SVGRenderer) DrawText(f *Font, text string, p Point) {
+	m := r.m.Translate(p).Scale(1, -1)
+	style := ""
+	if weight, italic := f.style(); weight != 0 && weight != WeightRegular || italic {
+		style = fmt.Sprintf(` font-weight="%d"`, weight)
+		if italic {
+			style += ` font-style="italic"`
+		}
+	}
+	if paragraphLevel(text, f.Direction()) == 1 {
+		style += ` direction="rtl" text-anchor="end"`
+	}
+	r.printf("<text transform=\"matrix(%s %s %s %s %s %s)\" font-family=\"%s\" font-size=\"%s\"%s xml:space=\"preserve\" %s>",
+		svgNum(m.A), svgNum(m.B), svgNum(m.C), svgNum(m.D), svgNum(m.E), svgNum(m.F), svgEscape(f.family()), svgNum(f.Size()), style, r.fill())
+	r.printf("%s</text>\n", svgEscape(text))
+}
+
+// DrawImage writes a copy of the pixels of img tinted by the current color,
+// clipped to dst if src does not lie on pixel boundaries.
+func (r *SVGRenderer) DrawImage(img *Image, dst, src Rectangle) {
+	m := img.tinted(src, r.color)
+	if m.Rect.Empty() {
+		return
+	}
+	var data bytes.Buffer
+	png.Encode(&data, m)
+	// The SVG image's first row is at the top of the unit square, which is
+	// upside down in the image's coordinates.
+	t := r.m.Mul(imageRect(m.Rect, dst, src)).Mul(Affine2D{1, 0, 0, -1, 0, 1})
+	attrs := ""
+	if img.Filter == Nearest {
+		attrs = ` style="image-rendering:pixelated"`
+	}
+	b := m.Rect
+	clip := float64(b.Min.X) != src.Min.X || float64(b.Min.Y) != src.Min.Y || float64(b.Max.X) != src.Max.X || float64(b.Max.Y) != src.Max.Y
+	if clip {
+		r.clips++
+		r.printf("<clipPath id=\"clip%d\"><polygon points=\"%s\"/></clipPath>\n", r.clips, r.points(dst.corners()))
+		r.printf("<g clip-path=\"url(#clip%d)\">\n", r.clips)
+	}
+	r.printf("<image width=\"1\" height=\"1\" preserveAspectRatio=\"none\" transform=\"matrix(%s %s %s %s %s %s)\"%s xlink:href=\"data:image/png;base64,%s\"/>\n",
+		svgNum(t.A), svgNum(t.B), svgNum(t.C), svgNum(t.D), svgNum(t.E), svgNum(t.F), attrs, base64.StdEncoding.EncodeToString(data.Bytes()))
+	if clip {
+		r.printf("</g>\n")
+	}
+}
+
+// Layers are not supported; their contents are written as vector graphics,
+// without their Views' opacity and blend mode.
+func (r *SVGRenderer) BeginLayer(l *Layer) bool { return false }
+func (r *SVGRenderer) EndLayer()                {}
+func (r *SVGRenderer) DrawLayer(l *Layer, opacity float64, blend BlendMode) bool {
+	return false
+}
+
+func (r *SVGRenderer) PushTransform() { r.stack = append(r.stack, r.m) }
+func (r *SVGRenderer) PopTransform() {
+	r.m = r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+}
+func (r *SVGRenderer) Translate(p Point)    { r.m = r.m.Translate(p) }
+func (r *SVGRenderer) Scale(x, y float64)   { r.m = r.m.Scale(x, y) }
+func (r *SVGRenderer) Rotate(rot float64)   { r.m = r.m.Rotate(2 * math.Pi * rot) }
+func (r *SVGRenderer) Transform(m Affine2D) { r.m = r.m.Mul(m) }
+
+// Clip starts a group clipped to the same area as the scissor rectangle in
+// gl21Renderer.
+func (r *SVGRenderer) Clip(rect Rectangle) {
+	if r.clipOpen {
+		r.printf("</g>\n")
+	}
+	r.clips++
+	p := r.dev.Apply(Pt(rect.Min.X, rect.Max.Y+1))
+	r.printf("<clipPath id=\"clip%d\"><rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\"/></clipPath>\n", r.clips, svgNum(p.X), svgNum(p.Y), svgNum(rect.Dx()+1), svgNum(rect.Dy()+1))
+	r.printf("<g clip-path=\"url(#clip%d)\">\n", r.clips)
+	r.clipOpen = true
+}
+
+// ClipPolygon starts a group clipped to the polygon.
+func (r *SVGRenderer) ClipPolygon(pts ...Point) {
+	if r.clipOpen {
+		r.printf("</g>\n")
+	}
+	r.clips++
+	m := r.m
+	r.m = r.dev
+	r.printf("<clipPath id=\"clip%d\"><polygon points=\"%s\"/></clipPath>\n", r.clips, r.points(pts))
+	r.m = m
+	r.printf("<g clip-path=\"url(#clip%d)\">\n", r.clips)
+	r.clipOpen = true
+}
+
+func svgNum(x float64) string {
+	return strconv.FormatFloat(math.Round(x*1000)/1000, 'f', -1, 64)
+}
+
+func svgColor(c Color) string {
+	b := func(x float64) uint8 { return uint8(math.Max(0, math.Min(1, x))*255 + .5) }
+	return fmt.Sprintf("#%02x%02x%02x", b(c.R), b(c.G), b(c.B))
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -1,22 +1,15 @@
 package gui
 
 import (
-	"github.com/gordonklaus/ftgl"
-	"github.com/gordonklaus/glfw"
-	gl "github.com/chsc/gogl/gl21"
-
-	"go/build"
 	"math"
-	"os"
-	"path/filepath"
-	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 type Text struct {
 	*ViewBase
 	text                string
-	font                ftgl.Font
+	font                *Font
 	textColor           Color
 	frameSize           float64
 	frameColor          Color
@@ -27,59 +20,73 @@ type Text struct {
 
 	blinkCursor bool
 	cursor      bool
-	stopCursor  chan chan bool
+	stopCursor  func()
+
+	// at and anchor are the byte offsets of the cursor and of the other end
+	// of the selection, which is empty if they are equal.
+	at, anchor int
 }
 
 func NewText(text string) *Text {
 	t := &Text{}
 	t.ViewBase = NewView(t)
-	t.font = getFont()
+	t.font = DefaultFont()
 	t.textColor = Color{1, 1, 1, 1}
 	t.backgroundColor = Color{0, 0, 0, 1}
-	t.stopCursor = make(chan chan bool)
 	t.SetText(text)
 	return t
 }
 
-var fontCache = struct {
-	sync.Mutex
-	m map[*glfw.Window]ftgl.Font
-}{m: map[*glfw.Window]ftgl.Font{}}
+func (t Text) Text() string { return t.text }
 
-// Must be called from a thread holding an OpenGL context, i.e., a window callback thread.
-func getFont() ftgl.Font {
-	w := glfw.GetCurrentContext()
-	if w == nil {
-		panic("no current context")
-	}
-	fontCache.Lock()
-	defer fontCache.Unlock()
-	font := fontCache.m[w]
-	if font.Nil() {
-		font = ftgl.NewTextureFont(filepath.Join(pkgDir(), "Times New Roman.ttf"))
-		font.SetFaceSize(18, 1)
-		fontCache.m[w] = font
+// SetText sets t's text and moves the cursor to its end.
+func (t *Text) SetText(text string) {
+	t.text = text
+	t.at, t.anchor = len(text), len(text)
+	t.Resize(math.Max(1, 2*t.frameSize+t.layoutFont().Advance(t.text)), 2*t.frameSize-t.font.Descender()+t.font.Ascender())
+	if t.TextChanged != nil {
+		t.TextChanged(text)
 	}
-	return font
 }
 
-func pkgDir() string {
-	for _, dir := range build.Default.SrcDirs() {
-		dir := filepath.Join(dir, "github.com/gordonklaus/gui")
-		if _, err := os.Stat(dir); err == nil {
-			return dir
-		}
+// Selection returns the byte offsets of the start and end of the selected
+// text.  They are equal, at the cursor, if no text is selected.
+func (t *Text) Selection() (start, end int) {
+	return min(t.at, t.anchor), max(t.at, t.anchor)
+}
+
+// Select selects the text between byte offsets start and end, which are
+// rounded to the nearest characters, and moves the cursor to end.
+func (t *Text) Select(start, end int) {
+	t.anchor, t.at = t.charBoundary(start), t.charBoundary(end)
+	Repaint(t)
+}
+
+// charBoundary returns the start of the character at or before byte offset i,
+// clamped to the text.
+func (t *Text) charBoundary(i int) int {
+	i = max(0, min(i, len(t.text)))
+	for i > 0 && i < len(t.text) && !utf8.RuneStart(t.text[i]) {
+		i--
 	}
-	panic("unreachable")
+	return i
 }
 
-func (t Text) Text() string { return t.text }
-func (t *Text) SetText(text string) {
-	t.text = text
-	t.Resize(math.Max(1, 2*t.frameSize+t.font.Advance(t.text)), 2*t.frameSize-t.font.Descender()+t.font.Ascender())
-	if t.TextChanged != nil {
-		t.TextChanged(text)
+// layoutFont returns t's font laying out text in t's layout direction.
+func (t *Text) layoutFont() *Font {
+	if d := LayoutDirection(t); d != DirectionAuto && d != t.font.Direction() {
+		return t.font.WithDirection(d)
 	}
+	return t.font
+}
+
+// Font returns the Font t is drawn in.
+func (t *Text) Font() *Font { return t.font }
+
+// SetFont sets the Font t is drawn in and resizes t to fit its text.
+func (t *Text) SetFont(f *Font) {
+	t.font = f
+	t.Resize(math.Max(1, 2*t.frameSize+t.layoutFont().Advance(t.text)), 2*t.frameSize-t.font.Descender()+t.font.Ascender())
 }
 
 func (t *Text) SetTextColor(c Color) {
@@ -99,7 +106,7 @@ func (t *Text) SetFrameColor(c Color) {
 
 func (t *Text) SetFrameSize(size float64) {
 	t.frameSize = size
-	t.Resize(2*t.frameSize+t.font.Advance(t.text), 2*t.frameSize-t.font.Descender()+t.font.Ascender())
+	t.Resize(2*t.frameSize+t.layoutFont().Advance(t.text), 2*t.frameSize-t.font.Descender()+t.font.Ascender())
 }
 
 func (t *Text) TookKeyFocus() { t.ShowCursor() }
@@ -112,27 +119,10 @@ func (t *Text) ShowCursor() {
 	t.blinkCursor = true
 	t.cursor = true
 	Repaint(t)
-	go func() {
-		tick := time.NewTicker(time.Second / 2)
-		defer tick.Stop()
-		for {
-			select {
-			case <-tick.C:
-				select {
-				case DoChan(t) <- func() {
-					t.cursor = !t.cursor
-					Repaint(t)
-				}:
-				case ch := <-t.stopCursor:
-					ch <- true
-					return
-				}
-			case ch := <-t.stopCursor:
-				ch <- true
-				return
-			}
-		}
-	}()
+	t.stopCursor = every(t, time.Second/2, func() {
+		t.cursor = !t.cursor
+		Repaint(t)
+	})
 }
 
 func (t *Text) HideCursor() {
@@ -140,27 +130,48 @@ func (t *Text) HideCursor() {
 		return
 	}
 	t.blinkCursor = false
-	ch := make(chan bool)
-	t.stopCursor <- ch
-	<-ch
+	t.stopCursor()
+	t.stopCursor = nil
 	t.cursor = false
 	Repaint(t)
 }
 
+// KeyPress edits t's text.  The cursor moves through the text in logical
+// order, one character at a time, so in right-to-left text and in text that
+// mixes directions it may jump about on screen.  The right arrow key moves
+// it forward in left-to-right text and backward in right-to-left text, and
+// the left arrow key the opposite way.  With Shift, they extend the
+// selection.
 func (t *Text) KeyPress(event KeyEvent) {
 	if len(event.Text) > 0 {
-		text := t.text + event.Text
-		if t.Validate == nil || t.Validate(&text) {
-			t.SetText(text)
-		}
+		t.replaceSelection(event.Text)
 	}
+	forward := paragraphLevel(t.text, t.layoutFont().Direction()) == 0
 	switch event.Key {
 	case KeyBackspace:
-		if len(t.text) > 0 {
-			text := t.text[:len(t.text)-1]
-			if t.Validate == nil || t.Validate(&text) {
-				t.SetText(text)
-			}
+		if t.at == t.anchor && t.at > 0 {
+			// Backspace deletes a single rune, so that a mark can be removed
+			// from the character it was typed onto.
+			_, n := utf8.DecodeLastRuneInString(t.text[:t.at])
+			t.anchor = t.at - n
+		}
+		t.replaceSelection("")
+	case KeyDelete:
+		if t.at == t.anchor {
+			t.anchor = nextChar(t.text, t.at)
+		}
+		t.replaceSelection("")
+	case KeyLeft:
+		t.moveCursor(!forward, event.Shift)
+	case KeyRight:
+		t.moveCursor(forward, event.Shift)
+	case KeyHome:
+		t.moveCursorTo(0, event.Shift)
+	case KeyEnd:
+		t.moveCursorTo(len(t.text), event.Shift)
+	case KeyA:
+		if event.Command {
+			t.Select(0, len(t.text))
 		}
 	case KeyEnter:
 		if t.Accept != nil {
@@ -173,6 +184,173 @@ func (t *Text) KeyPress(event KeyEvent) {
 	}
 }
 
+// moveCursor moves the cursor one character forward or backward in the text,
+// extending the selection if extend is true.  Without extend, a selection is
+// collapsed to its start or end.
+func (t *Text) moveCursor(forward, extend bool) {
+	start, end := t.Selection()
+	switch {
+	case start != end && !extend && forward:
+		t.moveCursorTo(end, false)
+	case start != end && !extend:
+		t.moveCursorTo(start, false)
+	case forward:
+		t.moveCursorTo(nextChar(t.text, t.at), extend)
+	default:
+		t.moveCursorTo(prevChar(t.text, t.at), extend)
+	}
+}
+
+func (t *Text) moveCursorTo(i int, extend bool) {
+	t.at = i
+	if !extend {
+		t.anchor = i
+	}
+	t.restartCursor()
+}
+
+// replaceSelection replaces the selected text, or inserts at the cursor if
+// there is none, and moves the cursor after the new text.
+func (t *Text) replaceSelection(s string) {
+	start, end := t.Selection()
+	if start == end && s == "" {
+		return
+	}
+	edited := t.text[:start] + s + t.text[end:]
+	text, at := edited, start+len(s)
+	if t.Validate != nil && !t.Validate(&text) {
+		return
+	}
+	if text != edited {
+		// Validate changed the text, so the cursor might not be where the
+		// new text ends.
+		at = len(text)
+	}
+	t.SetText(text)
+	t.at, t.anchor = at, at
+	t.restartCursor()
+}
+
+// restartCursor shows the cursor after it has moved, so that it does not
+// blink out of sight.
+func (t *Text) restartCursor() {
+	if t.blinkCursor {
+		t.HideCursor()
+		t.ShowCursor()
+	} else {
+		Repaint(t)
+	}
+}
+
+// nextChar returns the byte offset of the character after the one at i: the
+// next rune and the combining marks that follow it.
+func nextChar(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	_, n := utf8.DecodeRuneInString(text[i:])
+	for i += n; i < len(text); i += n {
+		var r rune
+		r, n = utf8.DecodeRuneInString(text[i:])
+		if !isMark(r) {
+			break
+		}
+	}
+	return i
+}
+
+// prevChar returns the byte offset of the character before the one at i.
+func prevChar(text string, i int) int {
+	for i > 0 {
+		r, n := utf8.DecodeLastRuneInString(text[:i])
+		i -= n
+		if !isMark(r) {
+			break
+		}
+	}
+	return i
+}
+
+// A charSpan is the horizontal extent of a character of a line of text, as
+// it is drawn.
+type charSpan struct {
+	start, end int // byte offsets
+	x0, x1     float64
+	rtl        bool
+}
+
+// charSpans returns the extents of the characters of text drawn in f, in the
+// order they appear in the text.  The characters of a ligature share its
+// extent equally.
+func charSpans(f *Font, text string) []charSpan {
+	glyphs, _ := f.layout(text)
+	type box struct {
+		x0, x1 float64
+		rtl    bool
+	}
+	boxes := map[int]*box{}
+	for _, g := range glyphs {
+		if b := boxes[g.cluster]; b != nil {
+			b.x0, b.x1 = min(b.x0, g.x), max(b.x1, g.x+g.adv)
+		} else {
+			boxes[g.cluster] = &box{g.x, g.x + g.adv, g.rtl}
+		}
+	}
+
+	var spans []charSpan
+	b, first := &box{}, 0
+	split := func() {
+		group := spans[first:]
+		w := (b.x1 - b.x0) / float64(len(group))
+		for k := range group {
+			s := &group[k]
+			s.x0, s.x1, s.rtl = b.x0+w*float64(k), b.x0+w*float64(k+1), b.rtl
+			if b.rtl {
+				s.x0, s.x1 = b.x1-w*float64(k+1), b.x1-w*float64(k)
+			}
+		}
+		first = len(spans)
+	}
+	for i := 0; i < len(text); {
+		if nb, ok := boxes[i]; ok {
+			if len(spans) > first {
+				split()
+			}
+			b = nb
+		}
+		j := nextChar(text, i)
+		spans = append(spans, charSpan{start: i, end: j})
+		i = j
+	}
+	if len(spans) > first {
+		split()
+	}
+	return spans
+}
+
+// cursorX returns where the cursor at byte offset i is drawn, given the
+// spans of the characters of the text: at the leading edge of the character
+// there, or at the trailing edge of the last character if i is the end of the
+// text.
+func cursorX(spans []charSpan, i int) float64 {
+	for _, s := range spans {
+		if s.start == i {
+			if s.rtl {
+				return s.x1
+			}
+			return s.x0
+		}
+	}
+	if len(spans) == 0 {
+		return 0
+	}
+	last := spans[len(spans)-1]
+	if last.rtl {
+		return last.x0
+	}
+	return last.x1
+}
+
 func (t *Text) Paint() {
 	SetColor(t.backgroundColor)
 	FillRect(InnerRect(t).Inset(t.frameSize))
@@ -182,14 +360,27 @@ func (t *Text) Paint() {
 		DrawRect(InnerRect(t))
 	}
 
-	if t.cursor {
-		SetColor(t.textColor)
-		SetLineWidth(2)
-		x := t.frameSize + t.font.Advance(t.text)
-		DrawLine(Pt(x, t.frameSize), Pt(x, Height(t)-2*t.frameSize))
+	f := t.layoutFont()
+	if t.blinkCursor {
+		spans := charSpans(f, t.text)
+		// The selection may be several pieces on screen if it spans text of
+		// both directions.
+		start, end := t.Selection()
+		c := t.textColor
+		SetColor(Color{c.R, c.G, c.B, c.A / 3})
+		for _, s := range spans {
+			if s.start >= start && s.end <= end {
+				FillRect(Rectangle{Pt(t.frameSize+s.x0, t.frameSize), Pt(t.frameSize+s.x1, Height(t)-t.frameSize)})
+			}
+		}
+		if t.cursor {
+			SetColor(t.textColor)
+			SetLineWidth(2)
+			x := t.frameSize + cursorX(spans, t.at)
+			DrawLine(Pt(x, t.frameSize), Pt(x, Height(t)-2*t.frameSize))
+		}
 	}
 
 	SetColor(t.textColor)
-	gl.Translated(gl.Double(t.frameSize), gl.Double(t.frameSize-t.font.Descender()), 0)
-	t.font.Render(t.text)
+	DrawText(f, t.text, Pt(t.frameSize, t.frameSize-t.font.Descender()))
 }
@@ -2,7 +2,8 @@ package gui
 
 import (
 	. "github.com/gordonklaus/util"
-	gl "github.com/chsc/gogl/gl21"
+	"math"
+	"time"
 )
 
 type View interface {
@@ -17,6 +18,7 @@ type View interface {
 	Resize(width, height float64)
 	Scale(x, y float64)
 	Pan(Point)
+	SetTransform(Affine2D)
 
 	TookKeyFocus()
 	LostKeyFocus()
@@ -41,6 +43,11 @@ type MouserView interface {
 	View
 }
 
+type HovererView interface {
+	Hoverer
+	View
+}
+
 type Scroller interface {
 	Scroll(ScrollEvent)
 }
@@ -65,11 +72,26 @@ type ViewBase struct {
 	size     Point
 	pan      Point
 	scale    Point
-	NoClip   bool
+	// transform is applied about pos, after pan and scale.
+	transform Affine2D
+	NoClip    bool
+	// Cached makes v a cached layer: v and its descendants are rendered
+	// offscreen, and the rendering is reused until Repaint is called on one of
+	// them.  Descendants are clipped to v's frame even if they have NoClip.
+	Cached bool
+	layer  *Layer
+	// overflows is set if a descendant had NoClip when v was last painted or
+	// was added with one.
+	overflows bool
+	// opacity and blend are applied to v and its descendants as a group,
+	// through a layer.
+	opacity float64
+	blend   BlendMode
+	dir     Direction
 }
 
 func NewView(self View) *ViewBase {
-	v := &ViewBase{scale: Pt(1, 1)}
+	v := &ViewBase{scale: Pt(1, 1), transform: Identity2D, opacity: 1}
 	if self == nil {
 		self = v
 	}
@@ -94,14 +116,17 @@ func (v *ViewBase) Add(u View) {
 	}
 	v.children = append(v.children, u)
 	u.base().parent = v.Self
+	v.overflows = v.overflows || unclipped(u)
 	Repaint(v.Self)
 }
 func (v *ViewBase) Remove(u View) {
+	Repaint(u)
 	SliceRemove(&v.children, u)
 	u.base().parent = nil
 	Repaint(v.Self)
 }
 func (v *ViewBase) Close() {
+	v.releaseLayer()
 	if v.parent != nil {
 		v.parent.Remove(v.Self)
 	}
@@ -110,6 +135,58 @@ func (v *ViewBase) Close() {
 func Show(v View) { v.base().hidden = false; Repaint(v) }
 func Hide(v View) { v.base().hidden = true; Repaint(v) }
 
+// SetOpacity sets the opacity, from 0 to 1, with which v and its descendants
+// are painted.  They are painted together into a layer, as for Cached, which
+// is then painted with the opacity, so that overlapping descendants do not
+// show through each other.
+func SetOpacity(v View, opacity float64) {
+	v.base().opacity = math.Max(0, math.Min(1, opacity))
+	recomposite(v)
+}
+
+// Opacity returns v's opacity, which is 1 unless set by SetOpacity.
+func Opacity(v View) float64 { return v.base().opacity }
+
+// SetBlendMode sets how v and its descendants are combined with what is
+// painted beneath them.  Like SetOpacity, it applies to them as a group
+// through a layer.
+func SetBlendMode(v View, b BlendMode) {
+	v.base().blend = b
+	recomposite(v)
+}
+
+// Blending returns the BlendMode set by SetBlendMode.
+func Blending(v View) BlendMode { return v.base().blend }
+
+// SetLayoutDirection sets the direction in which v and its descendants lay
+// out their contents, such as the text of a Text.  DirectionAuto, the
+// default, inherits the direction of v's parent.
+func SetLayoutDirection(v View, d Direction) {
+	v.base().dir = d
+	repaintTree(v)
+}
+
+// repaintTree schedules v and its descendants to be repainted, invalidating
+// any of their layers.
+func repaintTree(v View) {
+	Repaint(v)
+	for _, c := range v.base().children {
+		repaintTree(c)
+	}
+}
+
+// LayoutDirection returns the direction in which v lays out its contents:
+// the nearest one set by SetLayoutDirection on v or an ancestor, or
+// DirectionAuto if there is none.
+func LayoutDirection(v View) Direction {
+	for ; v != nil; v = Parent(v) {
+		if d := v.base().dir; d != DirectionAuto {
+			return d
+		}
+	}
+	return DirectionAuto
+}
+
 func Raise(v View) {
 	if Parent(v) != nil {
 		p := Parent(v).base()
@@ -136,13 +213,29 @@ func Lower(v View) {
 }
 
 func Pos(v View) Point           { return v.base().pos }
-func (v *ViewBase) Move(p Point) { v.pos = p; Repaint(v.Self) }
-func MoveCenter(v View, p Point) { v.Move(p.Sub(v.base().size.Div(2))) }
+func (v *ViewBase) Move(p Point) { recomposite(v.Self); v.pos = p; recomposite(v.Self) }
+func MoveCenter(v View, p Point) { v.Move(p.Sub(v.base().transform.Apply(v.base().size.Div(2)))) }
 func MoveOrigin(v View, p Point) { v.Move(p.Add(v.base().pan)) }
 
-func (v *ViewBase) Resize(width, height float64) { v.size = Pt(width, height); Repaint(v.Self) }
-func (v *ViewBase) Pan(p Point)                  { v.pan = p; Repaint(v.Self) }
-func (v *ViewBase) Scale(x, y float64)           { v.scale = Pt(x, y); Repaint(v.Self) }
+func (v *ViewBase) Resize(width, height float64) {
+	Repaint(v.Self)
+	v.size = Pt(width, height)
+	Repaint(v.Self)
+}
+func (v *ViewBase) Pan(p Point)        { v.pan = p; Repaint(v.Self) }
+func (v *ViewBase) Scale(x, y float64) { v.scale = Pt(x, y); Repaint(v.Self) }
+
+// SetTransform sets a transform, such as a rotation or skew, that v's frame
+// undergoes about its position.  It applies to v's painting, hit-testing and
+// clipping alike.
+func (v *ViewBase) SetTransform(m Affine2D) {
+	recomposite(v.Self)
+	v.transform = m
+	recomposite(v.Self)
+}
+
+// Transform returns the transform set by SetTransform.
+func Transform(v View) Affine2D { return v.base().transform }
 
 func Size(v View) (width, height float64) { return v.base().size.XY() }
 func Width(v View) float64                { return v.base().size.X }
@@ -164,13 +257,16 @@ func ResizeToFit(v View, margin float64) {
 	v.Pan(r.Min)
 }
 
+// OuterRect returns the bounds of v's frame in its parent's coordinates.
 func OuterRect(v View) Rectangle {
 	b := v.base()
-	return Rectangle{b.pos, b.pos.Add(b.size)}
+	return frameToParent(v).ApplyRect(Rectangle{ZP, b.size})
 }
+
+// InnerRect returns v's frame in its own coordinates.
 func InnerRect(v View) Rectangle {
-	r := OuterRect(v)
-	return Rectangle{MapFromParent(r.Min, v), MapFromParent(r.Max, v)}
+	b := v.base()
+	return Translation(b.pan.Mul(-1)).Scale(b.scale.X, b.scale.Y).Invert().ApplyRect(Rectangle{ZP, b.size})
 }
 
 func SetKeyFocus(v View) {
@@ -216,44 +312,153 @@ func MouseParent(v View, m MouseEvent) {
 	}
 }
 
+// Repaint schedules the area that v paints to be repainted in the next frame
+// of its Window.  Only Views within the areas repainted since the previous
+// frame are painted.
 func Repaint(v View) {
+	invalidateLayers(v)
 	if w := v.win(); w != nil {
-		w.repaint()
+		w.damage(paintBounds(v))
 	}
 }
 
-func (v *ViewBase) paint() {
-	if v.hidden {
+// recomposite schedules v to be repainted from its layer, whose contents
+// have not changed, as when v is only moved or transformed within its parent.
+func recomposite(v View) {
+	invalidateLayers(Parent(v))
+	if w := v.win(); w != nil {
+		w.damage(paintBounds(v))
+	}
+}
+
+// paintBounds returns a rectangle in window coordinates containing everything
+// that v and its descendants paint.
+func paintBounds(v View) Rectangle {
+	if b := v.base(); b.NoClip || b.overflows {
+		return Rectangle{Pt(math.Inf(-1), math.Inf(-1)), Pt(math.Inf(1), math.Inf(1))}
+	}
+	m := frameToWindow(v)
+	var r Rectangle
+	for i, p := range (Rectangle{ZP, v.base().size}).corners() {
+		p = m.Apply(p)
+		if i == 0 {
+			r = Rectangle{p, p}
+		} else {
+			r = r.Union(Rectangle{p, p})
+		}
+	}
+	// Clips extend a unit beyond a frame's maximum edges.
+	return r.Inset(-1)
+}
+
+// unclipped returns whether v or any of its descendants has NoClip, so that it
+// may paint outside v's frame.  It walks the whole tree, so paintBounds
+// relies instead on what paint found.
+func unclipped(v View) bool {
+	if v.base().NoClip {
+		return true
+	}
+	for _, c := range v.base().children {
+		if unclipped(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// frameToWindow returns the transform from v's frame to the coordinates of
+// the frame of its root ancestor, which are window coordinates if v is in a
+// Window.
+func frameToWindow(v View) Affine2D {
+	m := frameToParent(v)
+	for ; Parent(v) != nil; v = Parent(v) {
+		m = toParent(Parent(v)).Mul(m)
+	}
+	return frameToParent(v).Invert().Mul(m)
+}
+
+// paint paints v and its descendants to the current Renderer, clipped to the
+// bounds of v and its ancestors up to root.
+func (v *ViewBase) paint(root View) {
+	if v.hidden || v.opacity == 0 && v.Self != root {
 		return
 	}
 
-	w := v.Self.win()
-	r := OuterRect(w)
-	r = Rectangle{Map(r.Min, w, v.Self), Map(r.Max, w, v.Self)}
-	noclip := false
-	for v := View(v); v != nil; v = Parent(v) {
-		r = Rectangle{MapToParent(r.Min, v), MapToParent(r.Max, v)}
-		noclip = noclip || v.base().NoClip
-		if !noclip {
-			r = r.Intersect(OuterRect(v))
-			if r.Empty() {
+	// The clip is the intersection of the damaged area and the frames of root
+	// and of v and its ancestors below the first with NoClip, in root's
+	// frame's coordinates.
+	chain := paintScratch.chain[:0]
+	clipped := -1 // the views below the first with NoClip are chain[:clipped]
+	for u := v.Self; u != nil; u = Parent(u) {
+		if clipped < 0 && u.base().NoClip {
+			clipped = len(chain)
+		}
+		chain = append(chain, u)
+		if u == root {
+			break
+		}
+	}
+	if clipped < 0 {
+		clipped = len(chain)
+	}
+	paintScratch.chain = chain
+	clip, buf := append(paintScratch.clip[:0], painting.damage.corners()...), paintScratch.clipBuf
+	rectilinear := true
+	m := frameToParent(root).Invert()
+	for i := len(chain) - 1; i >= 0; i-- {
+		u := chain[i]
+		if i < clipped || u == root {
+			f := m.Mul(frameToParent(u))
+			rectilinear = rectilinear && f.Rectilinear()
+			frame := Rectangle{ZP, u.base().size}.corners()
+			for i, p := range frame {
+				frame[i] = f.Apply(p)
+			}
+			clip, buf = clipConvex(clip, buf, frame)
+			paintScratch.clip, paintScratch.clipBuf = clip, buf
+			if polygonArea(clip) == 0 {
 				return
 			}
 		}
+		m = m.Mul(toParent(u))
+	}
+	rend := painting.r
+	if rectilinear {
+		r := Rectangle{clip[0], clip[0]}
+		for _, p := range clip[1:] {
+			r = r.Union(Rectangle{p, p})
+		}
+		rend.Clip(r)
+	} else {
+		rend.ClipPolygon(clip...)
 	}
-	ax := float64(w.bufWidth) / w.size.X
-	ay := float64(w.bufHeight) / w.size.Y
-	gl.Scissor(gl.Int(ax*r.Min.X), gl.Int(ay*r.Min.Y), gl.Sizei(ax*(r.Dx()+1)), gl.Sizei(ay*(r.Dy()+1)))
 
-	gl.PushMatrix()
-	defer gl.PopMatrix()
-	d := MapToParent(ZP, v)
-	gl.Translated(gl.Double(d.X), gl.Double(d.Y), 0)
-	gl.Scaled(gl.Double(v.scale.X), gl.Double(v.scale.Y), 1)
+	rend.PushTransform()
+	defer rend.PopTransform()
+	if !v.layered() {
+		v.releaseLayer()
+	} else if v.Self != root {
+		rend.Transform(frameToParent(v.Self))
+		v.paintLayer(root)
+		return
+	}
+	rend.Transform(toParent(v.Self))
+	v.paintContents(root)
+}
+
+// layered returns whether v is painted through a layer.
+func (v *ViewBase) layered() bool {
+	return v.Cached || v.opacity < 1 || v.blend != BlendNormal
+}
 
+// paintContents paints v and its descendants in v's coordinates.
+func (v *ViewBase) paintContents(root View) {
 	v.Self.Paint()
+	v.overflows = false
 	for _, child := range v.children {
-		child.base().paint()
+		c := child.base()
+		c.paint(root)
+		v.overflows = v.overflows || c.NoClip || c.overflows
 	}
 }
 func (v ViewBase) Paint() {}
@@ -266,6 +471,15 @@ func Do(v View, f func()) {
 	w.Do(f)
 }
 
+// every calls f on v's Window goroutine every d until stop is called.
+func every(v View, d time.Duration, f func()) (stop func()) {
+	w := v.win()
+	if w == nil {
+		panic("gui.every called on windowless View")
+	}
+	return w.clock.every(d, f)
+}
+
 func DoChan(v View) chan<- func() {
 	w := v.win()
 	if w == nil {
@@ -290,18 +504,24 @@ func viewAtFunc(v View, p Point, f func(View) View) View {
 }
 
 func MapToParent(p Point, v View) Point {
-	b := v.base()
-	p.X *= b.scale.X
-	p.Y *= b.scale.Y
-	return p.Sub(b.pan).Add(b.pos)
+	return toParent(v).Apply(p)
 }
 
 func MapFromParent(p Point, v View) Point {
+	return toParent(v).Invert().Apply(p)
+}
+
+// toParent returns the transform from v's coordinates to its parent's.
+func toParent(v View) Affine2D {
 	b := v.base()
-	p = p.Sub(b.pos).Add(b.pan)
-	p.X /= b.scale.X
-	p.Y /= b.scale.Y
-	return p
+	return frameToParent(v).Translate(b.pan.Mul(-1)).Scale(b.scale.X, b.scale.Y)
+}
+
+// frameToParent returns the transform from v's frame, whose origin is v's
+// position, to its parent's coordinates.
+func frameToParent(v View) Affine2D {
+	b := v.base()
+	return Translation(b.pos).Mul(b.transform)
 }
 
 func Map(p Point, from, to View) Point {
@@ -0,0 +1,65 @@
+package gui
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPaintBounds(t *testing.T) {
+	root := newRectView(black, 0, 0, 40, 40)
+	a := newRectView(red, 10, 10, 10, 10)
+	root.Add(a)
+	bounded := func(v View) bool { return !math.IsInf(paintBounds(v).Min.X, -1) }
+	if got, want := paintBounds(a), (Rectangle{Pt(9, 9), Pt(21, 21)}); got != want {
+		t.Errorf("paintBounds = %v; want %v", got, want)
+	}
+
+	// An added subtree that overflows is found before it is painted.
+	b := newRectView(green, 0, 0, 5, 5)
+	g := newRectView(blue, 5, 5, 20, 20)
+	g.NoClip = true
+	b.Add(g)
+	a.Add(b)
+	if bounded(a) {
+		t.Error("paintBounds is bounded with a new NoClip descendant")
+	}
+	RenderImage(root, 1)
+	if bounded(root) || bounded(a) || bounded(b) {
+		t.Error("painting did not find the NoClip descendant")
+	}
+
+	// Painting finds that nothing overflows any more.
+	g.NoClip = false
+	RenderImage(root, 1)
+	if !bounded(root) || !bounded(a) || !bounded(b) {
+		t.Error("paintBounds is unbounded after NoClip is cleared")
+	}
+}
+
+// A clipRenderer only tracks transforms and clips.
+type clipRenderer struct{ Renderer }
+
+func (clipRenderer) PushTransform()       {}
+func (clipRenderer) PopTransform()        {}
+func (clipRenderer) Transform(Affine2D)   {}
+func (clipRenderer) Clip(Rectangle)       {}
+func (clipRenderer) ClipPolygon(...Point) {}
+
+func TestPaintAllocs(t *testing.T) {
+	allocs := func(n int) float64 {
+		root := NewView(nil)
+		root.Resize(100, 100)
+		v := root
+		for range n {
+			c := NewView(nil)
+			c.Resize(50, 50)
+			c.SetTransform(Rotation(.1))
+			v.Add(c)
+			v = c
+		}
+		return testing.AllocsPerRun(10, func() { Render(clipRenderer{}, root) })
+	}
+	if one, many := allocs(1), allocs(20); many > one {
+		t.Errorf("painting 20 nested views allocates %v times; want no more than the %v for one", many, one)
+	}
+}
@@ -2,22 +2,28 @@ package gui
 
 import (
 	"github.com/gordonklaus/glfw"
-	gl "github.com/chsc/gogl/gl21"
+	"math"
 	"runtime"
+	"time"
 )
 
 type Window struct {
-	w *glfw.Window
+	w *glfw.Window // nil for a headless Window
 	*ViewBase
 	centralView View
 	keyFocus    View
 	mouseIn     MouserView
 	mouser      map[int]MouserView
+	key         KeyEvent // the latest key event, which char completes
+	recorder    *Recorder
 	close       bool
 	paint       chan bool
 	do          chan func()
 
-	bufWidth, bufHeight gl.Sizei
+	renderer            Renderer
+	dirty               Rectangle // the area to repaint in the next frame, in window coordinates
+	clock               clock
+	bufWidth, bufHeight int
 }
 
 func NewWindow(self View, title string, init func(w *Window)) {
@@ -26,10 +32,22 @@ func NewWindow(self View, title string, init func(w *Window)) {
 		self = w
 	}
 	doMain(func() {
+		if CoreProfile {
+			glfw.WindowHint(glfw.ContextVersionMajor, 3)
+			glfw.WindowHint(glfw.ContextVersionMinor, 3)
+			glfw.WindowHint(glfw.OpenglProfile, glfw.OpenglCoreProfile)
+			glfw.WindowHint(glfw.OpenglForwardCompatible, 1)
+		}
 		w.w = glfw.NewWindow(960, 520, title)
 		windows = append([]*Window{w}, windows...)
 	})
 	w.ViewBase = NewView(self)
+	if CoreProfile {
+		w.renderer = &gl33Renderer{}
+	} else {
+		w.renderer = &gl21Renderer{}
+	}
+	w.clock = realClock{w}
 	w.mouser = make(map[int]MouserView)
 	w.paint = make(chan bool, 1)
 	w.do = make(chan func())
@@ -37,6 +55,34 @@ func NewWindow(self View, title string, init func(w *Window)) {
 	go doMain(w.registerCallbacks)
 }
 
+// NewHeadlessWindow creates a Window of the given size that is not backed by
+// an OS window and does not need Run.  It paints with a SoftRenderer, and its
+// clock only moves when Advance is called, so it behaves deterministically in
+// tests.  init is called on the Window's goroutine, and NewHeadlessWindow
+// returns after it has finished.
+func NewHeadlessWindow(self View, width, height int, init func(w *Window)) *Window {
+	w := &Window{}
+	if self == nil {
+		self = w
+	}
+	w.ViewBase = NewView(self)
+	w.renderer = NewSoftRenderer()
+	w.clock = &virtualClock{t: time.Unix(0, 0)}
+	w.mouser = make(map[int]MouserView)
+	w.paint = make(chan bool, 1)
+	w.do = make(chan func())
+	done := make(chan bool)
+	go func() {
+		init(w)
+		w.resized(width, height)
+		w.framebufferResized(width, height)
+		done <- true
+		w.loop()
+	}()
+	<-done
+	return w
+}
+
 func (w *Window) run(init func(w *Window)) {
 	runtime.LockOSThread()
 	glfw.MakeContextCurrent(w.w)
@@ -48,28 +94,49 @@ func (w *Window) run(init func(w *Window)) {
 	w.resized(w.w.Size())
 	w.framebufferResized(w.w.FramebufferSize())
 
-	gl.Enable(gl.SCISSOR_TEST)
-	gl.Enable(gl.BLEND)
-	gl.Enable(gl.POINT_SMOOTH)
-	gl.Enable(gl.LINE_SMOOTH)
-	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	w.loop()
+}
 
+func (w *Window) loop() {
 	for !w.close {
 		select {
 		case f := <-w.do:
 			f()
 		case <-w.paint:
-			gl.MatrixMode(gl.MODELVIEW)
-			gl.LoadIdentity()
-
-			gl.Scissor(0, 0, w.bufWidth, w.bufHeight)
-			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-			w.base().paint()
-			w.w.SwapBuffers()
+			w.paintFrame()
+			w.swapBuffers()
 		}
 	}
 }
 
+// paintFrame repaints the area damaged since the previous frame, or the whole
+// Window if its Renderer cannot keep the previous frame.
+func (w *Window) paintFrame() {
+	bufSize := Pt(float64(w.bufWidth), float64(w.bufHeight))
+	full := Rectangle{ZP, w.size}
+	damage := w.dirty.Intersect(full)
+	w.dirty = ZR
+	// Round out to whole pixels, which are cleared before being repainted.
+	ax, ay := bufSize.X/w.size.X, bufSize.Y/w.size.Y
+	damage.Min = Pt(math.Floor(damage.Min.X*ax)/ax, math.Floor(damage.Min.Y*ay)/ay)
+	damage.Max = Pt(math.Ceil(damage.Max.X*ax)/ax, math.Ceil(damage.Max.Y*ay)/ay)
+	if damage.Empty() {
+		damage = ZR
+	}
+	if full.In(damage) || !w.renderer.BeginPartial(w.size, bufSize, damage) {
+		w.renderer.Begin(w.size, bufSize)
+		damage = full
+	}
+	render(w.renderer, w.Self, damage)
+	w.renderer.End()
+}
+
+func (w *Window) swapBuffers() {
+	if w.w != nil {
+		w.w.SwapBuffers()
+	}
+}
+
 func (w *Window) registerCallbacks() {
 	w.w.OnFocus(func(focused bool) {
 		if focused {
@@ -87,76 +154,144 @@ func (w *Window) registerCallbacks() {
 	w.w.OnResize(func(width, height int) { w.Do(func() { w.resized(width, height) }) })
 	w.w.OnFramebufferResize(func(width, height int) { w.Do(func() { w.framebufferResized(width, height) }) })
 
-	k := KeyEvent{}
 	w.w.OnKey(func(key, scancode, action, mods int) {
 		w.Do(func() {
-			k.Key = key
-			k.action = action
-			k.Repeat = action == glfw.Repeat
+			k := KeyEvent{Key: key, action: action, Repeat: action == glfw.Repeat}
 			k.Shift = mods&glfw.ModShift != 0
 			k.Ctrl = mods&glfw.ModControl != 0
 			k.Alt = mods&glfw.ModAlt != 0
 			k.Super = mods&glfw.ModSuper != 0
 			k.Command = commandKey(k)
-			if key >= KeyEscape || action == glfw.Release {
-				k.Text = ""
-				if w.keyFocus != nil {
-					if k.action != glfw.Release {
-						w.keyFocus.KeyPress(k)
-					} else {
-						w.keyFocus.KeyRelease(k)
-					}
-				}
-			}
+			w.keyEvent(k)
 		})
 	})
 	w.w.OnChar(func(char rune) {
-		w.Do(func() {
-			if char < KeyEscape {
-				k.Text = string(char)
-				if w.keyFocus != nil {
-					if k.action != glfw.Release {
-						w.keyFocus.KeyPress(k)
-					} else {
-						w.keyFocus.KeyRelease(k)
-					}
-				}
-			}
-		})
+		w.Do(func() { w.char(char) })
 	})
 
 	m := MouseEvent{}
 	w.w.OnMouseMove(func(x, y float64) {
 		m.Pos = Pt(x, y)
-		m.Move, m.Press, m.Release, m.Drag = true, false, false, false
+		m.Move, m.Press, m.Release = true, false, false
 		w.mouse(m)
 	})
 	w.w.OnMouseButton(func(button, action, mods int) {
 		m.Button = button
-		m.Move, m.Press, m.Release, m.Drag = false, action == glfw.Press, action == glfw.Release, false
+		m.Move, m.Press, m.Release = false, action == glfw.Press, action == glfw.Release
 		w.mouse(m)
 	})
 	w.w.OnScroll(func(dx, dy float64) {
+		p := m.Pos
 		w.Do(func() {
-			s := ScrollEvent{m.Pos, Pt(dx, -dy), k.Shift, k.Ctrl, k.Alt, k.Super, k.Command}
-			s.Pos = w.mapToWindow(s.Pos)
-			v, _ := viewAtFunc(w.Self, s.Pos, func(v View) View {
-				v, _ = v.(ScrollerView)
-				return v
-			}).(ScrollerView)
-			if v != nil {
-				s.Pos = Map(s.Pos, w.Self, v)
-				v.Scroll(s)
-			}
+			k := w.key
+			w.scroll(ScrollEvent{w.mapToWindow(p), Pt(dx, -dy), k.Shift, k.Ctrl, k.Alt, k.Super, k.Command})
 		})
 	})
 }
 
+// mouse handles a mouse event whose position is in screen coordinates.
+func (w *Window) mouse(m MouseEvent) {
+	w.Do(func() {
+		m.Pos = w.mapToWindow(m.Pos)
+		w.mouseEvent(m)
+	})
+}
+
+// InjectKeyPress delivers k to the key focus as if its key had been pressed,
+// or repeated if k.Repeat is set.  As with keyboard input, presses of keys that
+// produce text are only delivered by a following InjectChar.
+//
+// The Inject methods deliver events exactly as OS input is delivered and
+// return after the event has been handled.  They must not be called from the
+// Window's goroutine.
+func (w *Window) InjectKeyPress(k KeyEvent) {
+	k.action = glfw.Press
+	if k.Repeat {
+		k.action = glfw.Repeat
+	}
+	k.Command = k.Command || commandKey(k)
+	w.Do(func() { w.keyEvent(k) })
+}
+
+// InjectKeyRelease delivers k to the key focus as if its key had been released.
+func (w *Window) InjectKeyRelease(k KeyEvent) {
+	k.action = glfw.Release
+	k.Repeat = false
+	k.Command = k.Command || commandKey(k)
+	w.Do(func() { w.keyEvent(k) })
+}
+
+// InjectChar delivers the text produced by the most recent key press.  If
+// that key has since been released, it is delivered as a press.
+func (w *Window) InjectChar(char rune) {
+	w.Do(func() {
+		if w.key.action == glfw.Release {
+			w.key.action = glfw.Press
+		}
+		w.char(char)
+	})
+}
+
+// InjectMouse delivers a mouse press, move or release, according to m.Press,
+// m.Move and m.Release.  m.Pos is in the Window's coordinates.  The event is
+// routed to Mousers, including Enter, Leave and Drag events, as if the mouse
+// had moved there.
+func (w *Window) InjectMouse(m MouseEvent) {
+	m.Enter, m.Leave, m.Drag = false, false, false
+	w.Do(func() { w.mouseEvent(m) })
+}
+
+// InjectScroll delivers s to the Scroller under s.Pos, which is in the Window's
+// coordinates.
+func (w *Window) InjectScroll(s ScrollEvent) {
+	s.Command = s.Command || commandKey(KeyEvent{Ctrl: s.Ctrl, Super: s.Super})
+	w.Do(func() { w.scroll(s) })
+}
+
+// keyEvent handles a key press, repeat or release.  Presses of keys that
+// produce text are delivered by char instead.
+func (w *Window) keyEvent(k KeyEvent) {
+	w.recordKey(k)
+	w.key = k
+	if k.Key >= KeyEscape || k.action == glfw.Release {
+		w.key.Text = ""
+		w.deliverKey()
+	}
+}
+
+func (w *Window) char(char rune) {
+	w.record(InputEvent{Type: "char", Char: string(char)})
+	if char < KeyEscape {
+		w.key.Text = string(char)
+		w.deliverKey()
+	}
+}
+
+func (w *Window) deliverKey() {
+	if w.keyFocus != nil {
+		if w.key.action != glfw.Release {
+			w.keyFocus.KeyPress(w.key)
+		} else {
+			w.keyFocus.KeyRelease(w.key)
+		}
+	}
+}
+
+func (w *Window) scroll(s ScrollEvent) {
+	w.record(InputEvent{Type: "scroll", X: s.Pos.X, Y: s.Pos.Y, DX: s.Delta.X, DY: s.Delta.Y, Shift: s.Shift, Ctrl: s.Ctrl, Alt: s.Alt, Super: s.Super, Command: s.Command})
+	v, _ := viewAtFunc(w.Self, s.Pos, func(v View) View {
+		v, _ = v.(ScrollerView)
+		return v
+	}).(ScrollerView)
+	if v != nil {
+		s.Pos = Map(s.Pos, w.Self, v)
+		v.Scroll(s)
+	}
+}
+
 func (w *Window) resized(width, height int) {
+	w.record(InputEvent{Type: "resize", Width: width, Height: height})
 	wid, hei := float64(width), float64(height)
-	gl.MatrixMode(gl.PROJECTION)
-	gl.LoadIdentity()
-	gl.Ortho(0, gl.Double(wid), 0, gl.Double(hei), -1, 1)
 	w.Self.Resize(wid, hei)
 	if w.centralView != nil {
 		w.centralView.Resize(wid, hei)
@@ -164,67 +299,83 @@ func (w *Window) resized(width, height int) {
 }
 
 func (w *Window) framebufferResized(width, height int) {
-	w.bufWidth, w.bufHeight = gl.Sizei(width), gl.Sizei(height)
-	gl.Viewport(0, 0, w.bufWidth, w.bufHeight)
+	w.bufWidth, w.bufHeight = width, height
+	w.damage(Rectangle{ZP, w.size})
 }
 
-func (w *Window) mouse(m MouseEvent) {
-	w.Do(func() {
-		switch {
-		case m.Press:
-			m.Pos = w.mapToWindow(m.Pos)
-			v, _ := viewAtFunc(w.Self, m.Pos, func(v View) View {
-				v, _ = v.(MouserView)
-				return v
-			}).(MouserView)
-			if v != nil {
-				w.mouser[m.Button] = v
-				m.Pos = Map(m.Pos, w.Self, v)
-				v.Mouse(m)
-			}
-		case m.Move:
-			m.Pos = w.mapToWindow(m.Pos)
-			m.Move = false
-			v, _ := viewAtFunc(w.Self, m.Pos, func(v View) View {
-				v, _ = v.(MouserView)
-				return v
-			}).(MouserView)
-			if w.mouseIn != v {
-				p := commonParent(w.mouseIn, v)
-				for v := View(w.mouseIn); v != p && v != nil; v = Parent(v) {
-					if v, ok := v.(MouserView); ok {
-						m := m
-						m.Pos = Map(m.Pos, w.Self, v)
-						m.Leave = true
-						v.Mouse(m)
-					}
-				}
-				for v := View(v); v != p && v != nil; v = Parent(v) {
-					if v, ok := v.(MouserView); ok {
-						m := m
-						m.Pos = Map(m.Pos, w.Self, v)
-						m.Enter = true
-						v.Mouse(m)
-					}
+// mouseEvent handles a mouse event whose position is in window coordinates.
+func (w *Window) mouseEvent(m MouseEvent) {
+	w.recordMouse(m)
+	switch {
+	case m.Press:
+		v, _ := viewAtFunc(w.Self, m.Pos, func(v View) View {
+			v, _ = v.(MouserView)
+			return v
+		}).(MouserView)
+		if v != nil {
+			w.mouser[m.Button] = v
+			m.Pos = Map(m.Pos, w.Self, v)
+			v.Mouse(m)
+		}
+	case m.Move:
+		m.Move = false
+		v, _ := viewAtFunc(w.Self, m.Pos, func(v View) View {
+			v, _ = v.(MouserView)
+			return v
+		}).(MouserView)
+		if w.mouseIn != v {
+			p := commonParent(w.mouseIn, v)
+			for v := View(w.mouseIn); v != p && v != nil; v = Parent(v) {
+				if v, ok := v.(MouserView); ok {
+					m := m
+					m.Pos = Map(m.Pos, w.Self, v)
+					m.Leave = true
+					v.Mouse(m)
 				}
-				w.mouseIn = v
-			}
-			for button, v := range w.mouser {
-				m := m
-				m.Pos = Map(m.Pos, w.Self, v)
-				m.Drag = true
-				m.Button = button
-				v.Mouse(m)
 			}
-		case m.Release:
-			m.Pos = w.mapToWindow(m.Pos)
-			if v, ok := w.mouser[m.Button]; ok {
-				m.Pos = Map(m.Pos, w.Self, v)
-				v.Mouse(m)
-				delete(w.mouser, m.Button)
+			for v := View(v); v != p && v != nil; v = Parent(v) {
+				if v, ok := v.(MouserView); ok {
+					m := m
+					m.Pos = Map(m.Pos, w.Self, v)
+					m.Enter = true
+					v.Mouse(m)
+				}
 			}
+			w.mouseIn = v
 		}
-	})
+		if h, _ := viewAtFunc(w.Self, m.Pos, func(v View) View {
+			v, _ = v.(HovererView)
+			return v
+		}).(HovererView); h != nil && !w.dragging(h) {
+			m := m
+			m.Pos = Map(m.Pos, w.Self, h)
+			m.Move = true
+			h.Hover(m)
+		}
+		for button, v := range w.mouser {
+			m := m
+			m.Pos = Map(m.Pos, w.Self, v)
+			m.Drag = true
+			m.Button = button
+			v.Mouse(m)
+		}
+	case m.Release:
+		if v, ok := w.mouser[m.Button]; ok {
+			m.Pos = Map(m.Pos, w.Self, v)
+			v.Mouse(m)
+			delete(w.mouser, m.Button)
+		}
+	}
+}
+
+// dragging reports whether a button pressed over v is held.
+func (w *Window) dragging(v View) bool {
+	for _, u := range w.mouser {
+		if View(u) == v {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *Window) mapToWindow(p Point) Point {
@@ -242,12 +393,31 @@ func (w *Window) Do(f func()) {
 }
 
 func (w *Window) Close() {
+	if w.w == nil {
+		go w.Do(func() { w.close = true })
+		return
+	}
 	go doMain(func() {
 		closeWindow(w)
 	})
 }
 
-func (w *Window) SetTitle(s string) { w.w.SetTitle(s) }
+func (w *Window) SetTitle(s string) {
+	if w.w != nil {
+		w.w.SetTitle(s)
+	}
+}
+
+// Advance moves the clock of a headless Window forward by d, running any
+// periodic work that falls due, such as blinking a Text cursor.  It must not be
+// called from the Window's goroutine.
+func (w *Window) Advance(d time.Duration) {
+	c, ok := w.clock.(*virtualClock)
+	if !ok {
+		panic("gui.Advance called on a Window that is not headless")
+	}
+	w.Do(func() { c.advance(d) })
+}
 
 func (w *Window) win() *Window { return w }
 
@@ -292,7 +462,18 @@ func (w *Window) KeyPress(k KeyEvent) {
 	}
 }
 
-func (w *Window) repaint() {
+// damage adds r, in window coordinates, to the area to repaint in the next
+// frame.
+func (w *Window) damage(r Rectangle) {
+	r = r.Intersect(Rectangle{ZP, w.size})
+	if r.Empty() {
+		return
+	}
+	if w.dirty.Empty() {
+		w.dirty = r
+	} else {
+		w.dirty = w.dirty.Union(r)
+	}
 	select {
 	case w.paint <- true:
 	default:
@@ -0,0 +1,194 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestHeadlessWindow(t *testing.T) {
+	var v *rectView
+	w := NewHeadlessWindow(nil, 60, 40, func(w *Window) {
+		w.Add(newRectView(blue, 0, 0, 60, 40))
+		v = newRectView(red, 10, 10, 20, 10)
+		w.Add(v)
+	})
+	defer w.Close()
+	if got := Pt(Size(w)); got != Pt(60, 40) {
+		t.Fatalf("size is %v; want (60, 40)", got)
+	}
+	img := w.Capture()
+	if got := img.Rect.Size(); got != image.Pt(60, 40) {
+		t.Fatalf("captured size is %v; want (60, 40)", got)
+	}
+	if got := pixelAt(img, Pt(20, 15), 40, 1); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("pixel in the view is %v; want red", got)
+	}
+
+	// Only the damaged area is repainted, yet the frame matches a full
+	// rendering.
+	w.Do(func() { v.Move(Pt(35, 25)) })
+	img = w.Capture()
+	for _, c := range []struct {
+		p    Point
+		want color.RGBA
+	}{
+		{Pt(20, 15), color.RGBA{0, 0, 255, 255}},
+		{Pt(45, 30), color.RGBA{255, 0, 0, 255}},
+	} {
+		if got := pixelAt(img, c.p, 40, 1); got != c.want {
+			t.Errorf("after moving, pixel at %v is %v; want %v", c.p, got, c.want)
+		}
+	}
+	var full *image.RGBA
+	w.Do(func() { full = RenderImage(w, 1) })
+	for i := range img.Pix {
+		if img.Pix[i] != full.Pix[i] {
+			t.Fatalf("partially repainted frame differs from a full rendering at byte %d", i)
+		}
+	}
+}
+
+func TestHeadlessWindowAdvance(t *testing.T) {
+	var txt *Text
+	w := NewHeadlessWindow(nil, 100, 50, func(w *Window) {
+		txt = NewText("hi")
+		w.SetCentralView(txt)
+	})
+	defer w.Close()
+	cursor := func() (c bool) {
+		w.Do(func() { c = txt.cursor })
+		return
+	}
+	if !cursor() {
+		t.Fatal("cursor hidden after taking key focus")
+	}
+	// The cursor blinks every half second of the Window's clock, however
+	// long the test takes.
+	for i, want := range []bool{true, true, false, false, true, false} {
+		if got := cursor(); got != want {
+			t.Fatalf("after %v, cursor is %v; want %v", time.Duration(i)*300*time.Millisecond, got, want)
+		}
+		w.Advance(300 * time.Millisecond)
+	}
+}
+
+// A dragView is a rectView that can be dragged with the mouse, and that
+// turns green while the Enter key is held.
+type dragView struct {
+	*rectView
+	*Mover
+	events []MouseEvent
+}
+
+func newDragView(x, y, w, h float64) *dragView {
+	v := &dragView{rectView: &rectView{c: red}}
+	v.ViewBase = NewView(v)
+	v.Mover = NewMover(v)
+	v.Resize(w, h)
+	v.Move(Pt(x, y))
+	return v
+}
+
+func (v *dragView) Mouse(m MouseEvent) {
+	v.events = append(v.events, m)
+	v.Mover.Mouse(m)
+}
+
+func (v *dragView) KeyPress(k KeyEvent) {
+	if k.Key == KeyEnter {
+		v.c = green
+		Repaint(v)
+	}
+}
+
+func (v *dragView) KeyRelease(k KeyEvent) {
+	if k.Key == KeyEnter {
+		v.c = red
+		Repaint(v)
+	}
+}
+
+func TestInjectMouse(t *testing.T) {
+	var v *dragView
+	w := NewHeadlessWindow(nil, 60, 40, func(w *Window) {
+		w.Add(newRectView(blue, 0, 0, 60, 40))
+		v = newDragView(10, 10, 10, 10)
+		w.Add(v)
+	})
+	defer w.Close()
+	w.InjectMouse(MouseEvent{Pos: Pt(15, 15), Move: true})
+	w.InjectMouse(MouseEvent{Pos: Pt(15, 15), Press: true})
+	w.InjectMouse(MouseEvent{Pos: Pt(30, 20), Move: true})
+	w.InjectMouse(MouseEvent{Pos: Pt(40, 25), Release: true})
+
+	var events []MouseEvent
+	w.Do(func() { events = v.events })
+	want := []MouseEvent{
+		{Pos: Pt(5, 5), Enter: true},
+		{Pos: Pt(5, 5), Press: true},
+		{Pos: Pt(20, 10), Leave: true},
+		{Pos: Pt(20, 10), Drag: true},
+		{Pos: Pt(15, 10), Release: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got events %v; want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d is %+v; want %+v", i, events[i], want[i])
+		}
+	}
+
+	img := w.Capture()
+	if got := pixelAt(img, Pt(15, 15), 40, 1); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("pixel where the view was is %v; want blue", got)
+	}
+	if got := pixelAt(img, Pt(40, 25), 40, 1); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("pixel where the view was dragged is %v; want red", got)
+	}
+}
+
+func TestInjectKey(t *testing.T) {
+	var v *dragView
+	w := NewHeadlessWindow(nil, 60, 40, func(w *Window) {
+		v = newDragView(10, 10, 20, 20)
+		w.Add(v)
+		SetKeyFocus(v)
+	})
+	defer w.Close()
+	pixel := func() color.RGBA { return pixelAt(w.Capture(), Pt(20, 20), 40, 1) }
+	if got := pixel(); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Fatalf("pixel is %v; want red", got)
+	}
+	w.InjectKeyPress(KeyEvent{Key: KeyEnter})
+	if got := pixel(); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("while Enter is pressed, pixel is %v; want green", got)
+	}
+	w.InjectKeyRelease(KeyEvent{Key: KeyEnter})
+	if got := pixel(); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("after Enter is released, pixel is %v; want red", got)
+	}
+}
+
+func TestInjectText(t *testing.T) {
+	var txt *Text
+	w := NewHeadlessWindow(nil, 100, 50, func(w *Window) {
+		txt = NewText("")
+		w.SetCentralView(txt)
+	})
+	defer w.Close()
+	w.InjectKeyPress(KeyEvent{Key: KeyH})
+	w.InjectChar('h')
+	w.InjectKeyRelease(KeyEvent{Key: KeyH})
+	w.InjectChar('i') // delivered as a press of its own
+	w.InjectKeyPress(KeyEvent{Key: KeyBackspace})
+	w.InjectKeyPress(KeyEvent{Key: KeyO})
+	w.InjectChar('o')
+	var s string
+	w.Do(func() { s = txt.Text() })
+	if s != "ho" {
+		t.Errorf("text is %q; want %q", s, "ho")
+	}
+}